@@ -0,0 +1,142 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * account.go
+ * Register accounts with the server, per draft/account-registration
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* accountRegCap is the capability which means the server has REGISTER */
+const accountRegCap = "draft/account-registration"
+
+// ErrNoAccountRegistration is returned by RegisterAccount and VerifyAccount if the server doesn't offer the draft/account-registration capability.
+var ErrNoAccountRegistration = errors.New("server doesn't support " +
+	"account registration")
+
+// ErrEmailRequired is returned by RegisterAccount if no email address was given but the server requires one.
+var ErrEmailRequired = errors.New("server requires an email address to " +
+	"register")
+
+// AccountError is returned when the server refuses to register or verify an account, e.g. with code ACCOUNT_EXISTS, WEAK_PASSWORD, or INVALID_CODE.
+type AccountError struct {
+	Command string /* REGISTER or VERIFY */
+	Code    string
+	Account string /* Not always given */
+	Text    string /* Reason from the server */
+}
+
+func (e *AccountError) Error() string {
+	return fmt.Sprintf("%v failed: %v (%v)", e.Command, e.Text, e.Code)
+}
+
+// Registration is the server's answer to RegisterAccount.
+type Registration struct {
+	Account string /* The account's name, as the server knows it */
+	Verify  bool   /* True if VerifyAccount needs to be called */
+	Text    string /* What the server said, e.g. where the code was sent */
+}
+
+// RegisterAccount asks the server to register an account named account, or named after our nick if account is "*" or the empty string, with password and email, which may be the empty string if the server doesn't need one (see CapValue).  If the server needs the account verified before it can be used, Verify is set in the returned Registration, and the code the server sends (e.g. by email) should be passed to VerifyAccount.  Otherwise, the server usually logs us in to the new account.  If the server refuses, an *AccountError is returned.
+func (i *IRC) RegisterAccount(
+	ctx context.Context,
+	account, email, password string,
+) (Registration, error) {
+	v, ok := i.CapValue(accountRegCap)
+	if !ok {
+		return Registration{}, ErrNoAccountRegistration
+	}
+	if "" == account {
+		account = "*"
+	}
+	if "" == email {
+		if capHas(v, "email-required") {
+			return Registration{}, ErrEmailRequired
+		}
+		email = "*"
+	}
+	i.audit(AuditAuth, "REGISTER %v", account)
+	m, err := i.accountRequest(ctx, "REGISTER", func() error {
+		return i.PrintfLine("REGISTER %v %v :%v", account, email,
+			password)
+	})
+	if nil != err {
+		return Registration{}, err
+	}
+	return Registration{
+		Account: m.Param(1),
+		Verify:  "VERIFICATION_REQUIRED" == m.Param(0),
+		Text:    m.Trailing(),
+	}, nil
+}
+
+// VerifyAccount completes the registration of an account for which RegisterAccount returned a Registration with Verify set, using the code the server sent.  If the code's wrong, an *AccountError is returned and VerifyAccount may be called again.
+func (i *IRC) VerifyAccount(ctx context.Context, account, code string) error {
+	if _, ok := i.CapValue(accountRegCap); !ok {
+		return ErrNoAccountRegistration
+	}
+	i.audit(AuditAuth, "VERIFY %v", account)
+	_, err := i.accountRequest(ctx, "VERIFY", func() error {
+		return i.PrintfLine("VERIFY %v :%v", account, code)
+	})
+	return err
+}
+
+// accountRequest sends a REGISTER or VERIFY with send and waits for the
+// server's answer, which is returned if it's not a FAIL
+func (i *IRC) accountRequest(
+	ctx context.Context,
+	cmd string,
+	send func() error,
+) (Message, error) {
+	ms, err := i.wait(ctx, send, func(m Message) (bool, bool) {
+		switch m.Command {
+		case cmd:
+			return true, true
+		case "FAIL":
+			return cmd == m.Param(0), cmd == m.Param(0)
+		case "421": /* ERR_UNKNOWNCOMMAND */
+			return cmd == m.Param(1), cmd == m.Param(1)
+		}
+		return false, false
+	})
+	if nil != err {
+		return Message{}, err
+	}
+	m := ms[len(ms)-1]
+	switch m.Command {
+	case "FAIL":
+		e := &AccountError{
+			Command: cmd,
+			Code:    m.Param(1),
+			Text:    m.Trailing(),
+		}
+		/* The account's there if there's context */
+		if 4 <= len(m.Params) {
+			e.Account = m.Param(2)
+		}
+		return Message{}, e
+	case "421":
+		return Message{}, numericError(m)
+	}
+	return m, nil
+}
+
+/* capHas returns true if the comma-separated cap value v contains key */
+func capHas(v, key string) bool {
+	for _, k := range strings.Split(v, ",") {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package minimalirc
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+ * audit.go
+ * Record what the connection did, without secrets
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// AuditKind says what an AuditEntry records.
+type AuditKind string
+
+// Kinds of AuditEntry.
+const (
+	AuditDial  AuditKind = "dial"  /* Connecting to a server */
+	AuditState AuditKind = "state" /* Connection state changed */
+	AuditAuth  AuditKind = "auth"  /* Logging in to services */
+	AuditSend  AuditKind = "send"  /* Line sent to the server */
+	AuditClose AuditKind = "close" /* Connection ended */
+)
+
+// AuditEntry is a line in the audit log written to i.Audit, if it's set.  Audit logs record connection lifecycle events and every line sent to the server, with passwords (i.IdPass, server and oper passwords, SASL payloads, channel keys, account registration passwords and codes, and services logins) replaced with <redacted>.  Unlike transcripts, nothing received from the server is recorded.
+type AuditEntry struct {
+	Time   time.Time
+	Kind   AuditKind
+	Detail string /* What happened, or the line sent */
+}
+
+// String returns e as it's written to i.Audit, the time in RFC3339 format with nanoseconds, a space, e.Kind, a space, and e.Detail.
+func (e AuditEntry) String() string {
+	return fmt.Sprintf("%v %v %v", e.Time.Format(time.RFC3339Nano), e.Kind,
+		e.Detail)
+}
+
+// audit writes an entry to the audit log, if there is one.  Errors writing
+// the log are ignored, as with logging.
+func (i *IRC) audit(kind AuditKind, f string, args ...interface{}) {
+	w := i.Audit
+	if nil == w {
+		return
+	}
+	e := AuditEntry{
+		Time:   time.Now(),
+		Kind:   kind,
+		Detail: fmt.Sprintf(f, args...),
+	}
+	if AuditSend == kind {
+		e.Detail = i.redactLine(e.Detail)
+	}
+	i.amu.Lock()
+	defer i.amu.Unlock()
+	io.WriteString(w, e.String()+"\n")
+}
@@ -0,0 +1,224 @@
+package minimalirc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * binary.go
+ * Send arbitrary bytes in PRIVMSGs
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* binaryPrefix marks a chunk of binary data */
+const binaryPrefix = "~bin "
+
+/* Defaults for Reassembler's limits */
+const (
+	defaultMaxChunks      = 1024
+	defaultMaxBytes       = 1 << 20
+	defaultMaxSenderBlobs = 4
+	defaultMaxBlobs       = 64
+)
+
+// SendBinary sends data to target (see Privmsg for the meaning of target) as a series of base64-encoded PRIVMSGs, each sized to fit PrivmsgSize.  Each chunk is of the form "~bin <id> <seq>/<total> <base64>", and the chunks may be put back together with a Reassembler.  The chunks are sent with PriorityBulk, as with PrivmsgBulk.
+func (i *IRC) SendBinary(target string, data []byte) error {
+	t := i.target(target)
+	if "" == t {
+		return nil
+	}
+	/* Random ID for this blob */
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); nil != err {
+		return err
+	}
+	id := hex.EncodeToString(b)
+	/* Work out how big chunks can be.  The header's worst case is with
+	seq and total as long as they'll get, which we guess generously. */
+	hlen := len(fmt.Sprintf("%v%v %v/%v ", binaryPrefix, id, len(data),
+		len(data)))
+	max := (i.PrivmsgSize(t) - hlen) / 4 * 3
+	if 0 >= max {
+		return errors.New("no room for binary data")
+	}
+	total := (len(data) + max - 1) / max
+	if 0 == total {
+		total = 1
+	}
+	for seq := 0; seq < total; seq++ {
+		end := (seq + 1) * max
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := i.PrivmsgBulk(fmt.Sprintf("%v%v %v/%v %v", binaryPrefix,
+			id, seq+1, total, base64.StdEncoding.EncodeToString(
+				data[seq*max:end])), t); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reassembler puts chunks sent by SendBinary back together.  It is safe for concurrent use.  Incomplete blobs are discarded after Timeout, if it's positive.  Since anybody can send chunks, the size of blobs and the number being put together at once are limited; chunks over the limits are rejected.
+type Reassembler struct {
+	Timeout        time.Duration
+	MaxChunks      int /* Chunks per blob, 1024 if 0 */
+	MaxBytes       int /* Decoded bytes per blob, 1MB if 0 */
+	MaxSenderBlobs int /* Incomplete blobs per sender, 4 if 0 */
+	MaxBlobs       int /* Incomplete blobs in total, 64 if 0 */
+
+	mu    sync.Mutex
+	blobs map[string]*blob
+}
+
+/* blob is a partly-received SendBinary */
+type blob struct {
+	sender string
+	chunks [][]byte
+	have   int
+	size   int /* Bytes in chunks */
+	start  time.Time
+}
+
+// NewReassembler returns a Reassembler which discards incomplete blobs after five minutes.
+func NewReassembler() *Reassembler {
+	return &Reassembler{Timeout: 5 * time.Minute}
+}
+
+// IsBinary returns true if body, the text of a PRIVMSG, looks like a chunk sent by SendBinary.
+func IsBinary(body string) bool {
+	return strings.HasPrefix(body, binaryPrefix)
+}
+
+// Add adds a chunk from sender (usually a nick!user@host), which is the text of a PRIVMSG.  Once all of a blob's chunks have been added, the blob is returned with done set to true.
+func (r *Reassembler) Add(sender, body string) (data []byte, done bool, err error) {
+	/* Unpack the chunk */
+	f := strings.Fields(strings.TrimPrefix(body, binaryPrefix))
+	if !IsBinary(body) || 2 > len(f) {
+		return nil, false, errors.New("not a binary chunk")
+	}
+	/* Empty blobs have no data */
+	if 2 == len(f) {
+		f = append(f, "")
+	}
+	ns := strings.SplitN(f[1], "/", 2)
+	if 2 != len(ns) {
+		return nil, false, errors.New("bad chunk sequence")
+	}
+	seq, err := strconv.Atoi(ns[0])
+	if nil != err {
+		return nil, false, err
+	}
+	total, err := strconv.Atoi(ns[1])
+	if nil != err {
+		return nil, false, err
+	}
+	if 1 > total || 1 > seq || seq > total {
+		return nil, false, errors.New("bad chunk sequence")
+	}
+	/* Don't let the sender make us allocate whatever they like */
+	if total > limit(r.MaxChunks, defaultMaxChunks) {
+		return nil, false, errors.New("too many chunks")
+	}
+	maxBytes := limit(r.MaxBytes, defaultMaxBytes)
+	if base64.StdEncoding.DecodedLen(len(f[2])) > maxBytes {
+		return nil, false, errors.New("blob too big")
+	}
+	c, err := base64.StdEncoding.DecodeString(f[2])
+	if nil != err {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if nil == r.blobs {
+		r.blobs = make(map[string]*blob)
+	}
+	r.expire()
+	/* Add it to the blob */
+	k := sender + " " + f[0]
+	b, ok := r.blobs[k]
+	if !ok {
+		if err := r.roomFor(sender); nil != err {
+			return nil, false, err
+		}
+		b = &blob{
+			sender: sender,
+			chunks: make([][]byte, total),
+			start:  time.Now(),
+		}
+		r.blobs[k] = b
+	}
+	if total != len(b.chunks) {
+		delete(r.blobs, k)
+		return nil, false, errors.New("chunk count changed")
+	}
+	size := b.size - len(b.chunks[seq-1]) + len(c)
+	if size > maxBytes {
+		delete(r.blobs, k)
+		return nil, false, errors.New("blob too big")
+	}
+	if nil == b.chunks[seq-1] {
+		b.have++
+	}
+	b.chunks[seq-1] = c
+	b.size = size
+	if b.have != total {
+		return nil, false, nil
+	}
+	/* All there */
+	delete(r.blobs, k)
+	for _, c := range b.chunks {
+		data = append(data, c...)
+	}
+	return data, true, nil
+}
+
+// roomFor returns an error if another incomplete blob from sender would be
+// too many.  r.mu must be held.
+func (r *Reassembler) roomFor(sender string) error {
+	if len(r.blobs) >= limit(r.MaxBlobs, defaultMaxBlobs) {
+		return errors.New("too many incomplete blobs")
+	}
+	n := 0
+	for _, b := range r.blobs {
+		if b.sender == sender {
+			n++
+		}
+	}
+	if n >= limit(r.MaxSenderBlobs, defaultMaxSenderBlobs) {
+		return errors.New("too many incomplete blobs from sender")
+	}
+	return nil
+}
+
+/* limit returns n, or def if n isn't positive */
+func limit(n, def int) int {
+	if 0 >= n {
+		return def
+	}
+	return n
+}
+
+/* expire removes blobs older than r.Timeout.  r.mu must be held. */
+func (r *Reassembler) expire() {
+	if 0 >= r.Timeout {
+		return
+	}
+	for k, b := range r.blobs {
+		if time.Since(b.start) > r.Timeout {
+			delete(r.blobs, k)
+		}
+	}
+}
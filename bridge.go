@@ -0,0 +1,115 @@
+package minimalirc
+
+import (
+	"context"
+	"time"
+)
+
+/*
+ * bridge.go
+ * Protocol-neutral view of messages, for bridges
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// BridgeKind says what sort of thing a BridgeEvent represents.
+type BridgeKind string
+
+// Kinds of BridgeEvent.
+const (
+	BridgeMessage BridgeKind = "message" /* PRIVMSG */
+	BridgeAction  BridgeKind = "action"  /* CTCP ACTION, i.e. /me */
+	BridgeNotice  BridgeKind = "notice"  /* NOTICE */
+	BridgeJoin    BridgeKind = "join"    /* Sender joined Target */
+	BridgePart    BridgeKind = "part"    /* Sender left Target */
+	BridgeKick    BridgeKind = "kick"    /* Sender kicked Body from Target */
+	BridgeQuit    BridgeKind = "quit"    /* Sender left IRC */
+	BridgeNick    BridgeKind = "nick"    /* Sender is now known as Body */
+	BridgeTopic   BridgeKind = "topic"   /* Sender set Target's topic */
+	BridgeEdit    BridgeKind = "edit"    /* Body replaces message Refers */
+	BridgeDelete  BridgeKind = "delete"  /* Message Refers was redacted */
+)
+
+// BridgeEvent is a normalized form of a message, for relaying to other chat systems.  Its fields are stable, and its JSON encoding is suitable for passing to non-Go programs.  Fields which don't apply to a Kind, or which the server didn't send, are empty.
+type BridgeEvent struct {
+	Kind    BridgeKind `json:"kind"`
+	Sender  string     `json:"sender"`            /* Nick */
+	Account string     `json:"account,omitempty"` /* Services account */
+	Target  string     `json:"target,omitempty"`  /* Channel or our nick */
+	Private bool       `json:"private,omitempty"` /* Target isn't a channel */
+	Body    string     `json:"body,omitempty"`    /* Text, reason, or nick */
+	Time    time.Time  `json:"timestamp"`         /* Server time, if sent */
+	MsgID   string     `json:"msgid,omitempty"`   /* Message's ID */
+	ReplyTo string     `json:"reply_to,omitempty"`
+	Refers  string     `json:"refers,omitempty"` /* Edited/deleted msgid */
+}
+
+// Normalize turns m into a BridgeEvent.  It returns false if m isn't something a bridge would relay.  The account comes from the account tag (the account-tag capability), times from the time tag (server-time), IDs from the msgid tag (message-tags), replies from the +draft/reply tag, and edits from the +draft/edit tag.  Deletions are REDACT messages (draft/message-redaction).  If the server doesn't send a time, the current time is used.
+func Normalize(m Message) (BridgeEvent, bool) {
+	e := BridgeEvent{
+		Sender:  m.Nick,
+		Account: m.Tags["account"],
+		Target:  m.Param(0),
+		MsgID:   m.Tags["msgid"],
+		ReplyTo: m.Tags["+draft/reply"],
+	}
+	if t, ok := m.Time(); ok {
+		e.Time = t
+	} else {
+		e.Time = time.Now()
+	}
+	if "*" == e.Account {
+		e.Account = ""
+	}
+	switch m.Command {
+	case "PRIVMSG":
+		e.Kind, e.Body = BridgeMessage, m.Trailing()
+		if cmd, args, ok := ParseCTCP(e.Body); ok {
+			if "ACTION" != cmd {
+				return BridgeEvent{}, false
+			}
+			e.Kind, e.Body = BridgeAction, args
+		}
+		if id, ok := m.Tags["+draft/edit"]; ok {
+			e.Kind, e.Refers = BridgeEdit, id
+		}
+	case "NOTICE":
+		/* Notices from servers aren't chat */
+		if "" == m.User {
+			return BridgeEvent{}, false
+		}
+		e.Kind, e.Body = BridgeNotice, m.Trailing()
+	case "JOIN":
+		e.Kind = BridgeJoin
+	case "PART":
+		e.Kind, e.Body = BridgePart, m.Param(1)
+	case "KICK":
+		e.Kind, e.Body = BridgeKick, m.Param(1)
+	case "QUIT":
+		e.Kind, e.Target, e.Body = BridgeQuit, "", m.Param(0)
+	case "NICK":
+		e.Kind, e.Target, e.Body = BridgeNick, "", m.Param(0)
+	case "TOPIC":
+		e.Kind, e.Body = BridgeTopic, m.Param(1)
+	case "REDACT":
+		e.Kind, e.Refers, e.Body = BridgeDelete, m.Param(1), m.Param(2)
+	default:
+		return BridgeEvent{}, false
+	}
+	/* We don't know the server's STATUSMSG, but @#channel and the like
+	aren't private either way */
+	_, c := splitStatus(e.Target, memberPrefixes)
+	e.Private = "" != e.Target && !isChannel(c)
+	return e, true
+}
+
+// Bridge registers f to be called with the normalized form of every message which Normalize can handle.  Use it with json.Marshal to relay messages elsewhere.
+func (i *IRC) Bridge(f func(e BridgeEvent)) {
+	i.Handle(func(_ context.Context, m Message) {
+		if e, ok := Normalize(m); ok {
+			f(e)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * caps.go
+ * IRCv3 capability negotiation
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* maxCapReq is the longest list of capabilities put in one CAP REQ */
+const maxCapReq = 400
+
+// CapEvent is sent when the server ACKs capabilities we've requested, or, after registration, offers new capabilities (NEW) or withdraws them (DEL), per cap-notify.  Withdrawn capabilities are no longer enabled.  Capabilities in i.Caps which the server offers later are requested automatically.
+type CapEvent struct {
+	Command string   /* ACK, NEW, or DEL */
+	Caps    []string /* Capability names, without values */
+}
+
+func (CapEvent) event() {}
+
+// HasCap returns true if the server has ACKed the capability named cap.  Capabilities are requested by listing them in i.Caps before calling Connect.  sasl is requested if i.SASL is set.
+func (i *IRC) HasCap(cap string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.caps[cap]
+}
+
+// CapValue returns the value the server gave for the capability named cap when it listed it, e.g. PLAIN,EXTERNAL for sasl, and whether the server offers cap at all.  Capabilities without values have the value "".
+func (i *IRC) CapValue(cap string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	v, ok := i.offered[cap]
+	return v, ok
+}
+
+// ServerCaps returns the capabilities the server offers, mapped to their values.
+func (i *IRC) ServerCaps() map[string]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	cs := make(map[string]string, len(i.offered))
+	for k, v := range i.offered {
+		cs[k] = v
+	}
+	return cs
+}
+
+/* parseCaps parses a list of capabilities, some with values */
+func parseCaps(s string) map[string]string {
+	cs := make(map[string]string)
+	for _, c := range strings.Fields(s) {
+		parts := strings.SplitN(c, "=", 2)
+		if 2 == len(parts) {
+			cs[parts[0]] = parts[1]
+		} else {
+			cs[parts[0]] = ""
+		}
+	}
+	return cs
+}
+
+// trackCaps handles the server's side of capability negotiation, per CAP LS
+// 302: the LS may span lines, capabilities may have values, and the server
+// may offer and withdraw capabilities after registration
+func (i *IRC) trackCaps(m Message) {
+	if "CAP" != m.Command {
+		return
+	}
+	var err error
+	switch strings.ToUpper(m.Param(1)) {
+	case "LS":
+		/* A * means there's more to come */
+		i.mu.Lock()
+		if nil == i.capLS {
+			i.capLS = make(map[string]string)
+		}
+		for k, v := range parseCaps(m.Trailing()) {
+			i.capLS[k] = v
+		}
+		if 4 <= len(m.Params) && "*" == m.Param(2) {
+			i.mu.Unlock()
+			return
+		}
+		i.offered = i.capLS
+		i.capLS = nil
+		i.mu.Unlock()
+		/* Request the ones we want the server has */
+		if err = i.requestCaps(i.wantedCaps()); nil == err {
+			err = i.maybeEndCaps()
+		}
+	case "NEW":
+		cs := parseCaps(m.Trailing())
+		i.mu.Lock()
+		if nil == i.offered {
+			i.offered = make(map[string]string)
+		}
+		var names []string
+		for k, v := range cs {
+			i.offered[k] = v
+			names = append(names, k)
+		}
+		i.mu.Unlock()
+		i.event(CapEvent{Command: "NEW", Caps: names})
+		/* Only ask for what we want and haven't got */
+		var want []string
+		for _, c := range i.wantedCaps() {
+			if _, ok := cs[c]; ok && !i.HasCap(c) {
+				want = append(want, c)
+			}
+		}
+		err = i.requestCaps(want)
+	case "DEL":
+		names := strings.Fields(m.Trailing())
+		i.mu.Lock()
+		for _, c := range names {
+			delete(i.offered, c)
+			delete(i.caps, c)
+		}
+		i.mu.Unlock()
+		i.event(CapEvent{Command: "DEL", Caps: names})
+	case "ACK":
+		var names []string
+		i.mu.Lock()
+		if nil == i.caps {
+			i.caps = make(map[string]bool)
+		}
+		for _, c := range strings.Fields(m.Trailing()) {
+			if strings.HasPrefix(c, "-") {
+				delete(i.caps, c[1:])
+				continue
+			}
+			i.caps[c] = true
+			names = append(names, c)
+		}
+		if 0 < i.capReqs {
+			i.capReqs--
+		}
+		i.mu.Unlock()
+		i.event(CapEvent{Command: "ACK", Caps: names})
+		/* SASL has to happen before CAP END */
+		for _, c := range names {
+			if "sasl" == c {
+				err = i.startSASL()
+			}
+		}
+		if nil == err {
+			err = i.maybeEndCaps()
+		}
+	case "NAK":
+		i.mu.Lock()
+		if 0 < i.capReqs {
+			i.capReqs--
+		}
+		i.mu.Unlock()
+		err = i.maybeEndCaps()
+	}
+	if nil != err {
+		i.event(ErrorEvent{Err: err})
+	}
+}
+
+// requestCaps sends CAP REQs for the capabilities in want which the server
+// offers, in as many lines as it takes, and notes how many replies to expect.
+// Nothing is sent in passive mode.
+func (i *IRC) requestCaps(want []string) error {
+	if i.PassiveMode {
+		return nil
+	}
+	var (
+		reqs []string
+		cur  string
+	)
+	i.mu.Lock()
+	for _, c := range want {
+		if _, ok := i.offered[c]; !ok {
+			continue
+		}
+		if "" != cur && maxCapReq < len(cur)+1+len(c) {
+			reqs = append(reqs, cur)
+			cur = ""
+		}
+		if "" != cur {
+			cur += " "
+		}
+		cur += c
+	}
+	if "" != cur {
+		reqs = append(reqs, cur)
+	}
+	i.capReqs += len(reqs)
+	i.mu.Unlock()
+	for _, r := range reqs {
+		if err := i.PrintfLine("CAP REQ :%v", r); nil != err {
+			return errors.New(fmt.Sprintf("error requesting "+
+				"capabilities %v: %v", r, err))
+		}
+	}
+	return nil
+}
+
+// maybeEndCaps ends capability negotiation if we're registering, the
+// server's answered all of our requests, and we're not logging in with SASL
+func (i *IRC) maybeEndCaps() error {
+	if i.PassiveMode || Registered == i.State() {
+		return nil
+	}
+	i.mu.Lock()
+	waiting := 0 != i.capReqs || nil != i.sasl
+	i.mu.Unlock()
+	if waiting {
+		return nil
+	}
+	return i.PrintfLine("CAP END")
+}
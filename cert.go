@@ -0,0 +1,73 @@
+package minimalirc
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+)
+
+/*
+ * cert.go
+ * Keep an eye on the server's certificate
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* defaultCertWarning is used if i.CertWarning is 0 */
+const defaultCertWarning = 14 * 24 * time.Hour
+
+// CertEvent is sent after connecting with TLS if the server's certificate differs from the one it sent on the last connection, which may mean the connection's being intercepted (or just that the certificate's been renewed, or that Host names several servers), or if a certificate in its chain expires within i.CertWarning (two weeks if 0).
+type CertEvent struct {
+	Chain    []*x509.Certificate /* The server's chain, leaf first */
+	Previous []*x509.Certificate /* Last connection's chain, if any */
+	Changed  bool                /* The leaf differs from Previous's */
+	Expiring bool                /* Expires is within i.CertWarning */
+	Expires  time.Time           /* When the first certificate expires */
+}
+
+func (CertEvent) event() {}
+
+// ServerCerts returns the certificate chain the server sent on the last TLS connection, leaf first, or nil if there's not been one.
+func (i *IRC) ServerCerts() []*x509.Certificate {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]*x509.Certificate(nil), i.certs...)
+}
+
+// checkCerts records the certificate chain sent by the server over c, if it's
+// a TLS connection, and sends a CertEvent if it's changed or expires soon
+func (i *IRC) checkCerts(c net.Conn) {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		return
+	}
+	chain := tc.ConnectionState().PeerCertificates
+	if 0 == len(chain) {
+		return
+	}
+	i.mu.Lock()
+	prev := i.certs
+	i.certs = chain
+	i.mu.Unlock()
+
+	/* Work out when the chain stops being any good */
+	e := CertEvent{Chain: chain, Previous: prev}
+	for _, cert := range chain {
+		if e.Expires.IsZero() || cert.NotAfter.Before(e.Expires) {
+			e.Expires = cert.NotAfter
+		}
+	}
+	warn := i.CertWarning
+	if 0 == warn {
+		warn = defaultCertWarning
+	}
+	e.Expiring = time.Until(e.Expires) < warn
+	e.Changed = 0 != len(prev) && !bytes.Equal(prev[0].Raw, chain[0].Raw)
+	if e.Changed || e.Expiring {
+		i.event(e)
+	}
+}
@@ -0,0 +1,220 @@
+package minimalirc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * channels.go
+ * Keep track of the channels we're in
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// NotInChannelError is returned by Privmsg when i.GuardChannels is true and the target is a channel we're not in.
+type NotInChannelError struct {
+	Channel string
+}
+
+func (e *NotInChannelError) Error() string {
+	return fmt.Sprintf("not in channel %v", e.Channel)
+}
+
+// CannotSendEvent is sent when the server refuses a message to a channel (numerics 404 and 442).
+type CannotSendEvent struct {
+	Channel string /* Channel to which the message was sent */
+	Reason  string /* Reason from the server */
+}
+
+func (CannotSendEvent) event() {}
+
+/* channel holds what we know about a channel we're in */
+type channel struct {
+	name    string            /* Name as the server sent it */
+	members map[string]string /* Nicks in the channel, keyed by fold(nick) */
+	said    time.Time         /* When something was last said, or we joined */
+}
+
+/* memberPrefixes are the channel status prefixes on nicks in NAMES replies */
+const memberPrefixes = "~&@%+"
+
+// InChannel returns true if the server has confirmed we've JOINed channel, and we've not since PARTed or been KICKed.
+func (i *IRC) InChannel(channel string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.channels[fold(channel)]
+	return ok
+}
+
+// Channels returns the names of the channels we're in, sorted.
+func (i *IRC) Channels() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	cs := make([]string, 0, len(i.channels))
+	for _, c := range i.channels {
+		cs = append(cs, c.name)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// Members returns the nicks in channel, sorted, or nil if we're not in channel.  Members are learned from NAMES replies (sent by the server on JOIN), JOINs, PARTs, KICKs, QUITs, and NICKs.
+func (i *IRC) Members(channel string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	c, ok := i.channels[fold(channel)]
+	if !ok {
+		return nil
+	}
+	ns := make([]string, 0, len(c.members))
+	for _, n := range c.members {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// CommonChannels returns the channels we're in which nick is also in, sorted.
+func (i *IRC) CommonChannels(nick string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var cs []string
+	for _, c := range i.channels {
+		if _, ok := c.members[fold(nick)]; ok {
+			cs = append(cs, c.name)
+		}
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+/* isMe returns true if nick is the server's idea of our nick */
+func (i *IRC) isMe(nick string) bool {
+	return "" != nick && fold(nick) == fold(i.SNick())
+}
+
+/* isChannel returns true if target looks like a channel */
+func isChannel(target string) bool {
+	return "" != target && strings.ContainsRune("#&+!", rune(target[0]))
+}
+
+/* trackChannels updates the list of channels we're in and who's in them */
+func (i *IRC) trackChannels(m Message) {
+	switch m.Command {
+	case "JOIN":
+		if i.isMe(m.Nick) {
+			i.mu.Lock()
+			if nil == i.channels {
+				i.channels = make(map[string]*channel)
+			}
+			i.channels[fold(m.Param(0))] = &channel{
+				name:    m.Param(0),
+				members: map[string]string{fold(m.Nick): m.Nick},
+				said:    time.Now(),
+			}
+			i.mu.Unlock()
+			return
+		}
+		i.addMember(m.Param(0), m.Nick)
+	case "353": /* RPL_NAMREPLY */
+		for _, n := range strings.Fields(m.Trailing()) {
+			n, _, _ = SplitPrefix(strings.TrimLeft(n, memberPrefixes))
+			i.addMember(m.Param(2), n)
+		}
+	case "PART":
+		if i.isMe(m.Nick) {
+			i.leftChannel(m.Param(0))
+			return
+		}
+		i.removeMember(m.Param(0), m.Nick)
+	case "KICK":
+		if i.isMe(m.Param(1)) {
+			i.leftChannel(m.Param(0))
+			return
+		}
+		i.removeMember(m.Param(0), m.Param(1))
+	case "QUIT":
+		i.mu.Lock()
+		for _, c := range i.channels {
+			delete(c.members, fold(m.Nick))
+		}
+		i.mu.Unlock()
+	case "NICK":
+		i.mu.Lock()
+		for _, c := range i.channels {
+			if _, ok := c.members[fold(m.Nick)]; ok {
+				delete(c.members, fold(m.Nick))
+				c.members[fold(m.Param(0))] = m.Param(0)
+			}
+		}
+		i.mu.Unlock()
+	case "PRIVMSG", "NOTICE":
+		/* Note when the channel was last active, for OnChannelIdle */
+		i.mu.Lock()
+		if c, ok := i.channels[fold(m.Param(0))]; ok {
+			c.said = time.Now()
+		}
+		i.mu.Unlock()
+	case "404", "442": /* ERR_CANNOTSENDTOCHAN, ERR_NOTONCHANNEL */
+		if "442" == m.Command {
+			i.leftChannel(m.Param(1))
+		}
+		i.event(CannotSendEvent{Channel: m.Param(1), Reason: m.Trailing()})
+	}
+}
+
+/* addMember notes that nick is in channel, if we're in channel */
+func (i *IRC) addMember(channel, nick string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if c, ok := i.channels[fold(channel)]; ok && "" != nick {
+		c.members[fold(nick)] = nick
+	}
+}
+
+/* removeMember notes that nick has left channel */
+func (i *IRC) removeMember(channel, nick string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if c, ok := i.channels[fold(channel)]; ok {
+		delete(c.members, fold(nick))
+	}
+}
+
+/* leftChannel removes channel from the list of channels we're in */
+func (i *IRC) leftChannel(channel string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.channels, fold(channel))
+}
+
+// guard checks that we're in target before sending to it, if it's a channel,
+// with or without a STATUSMSG prefix.  Depending on i.AutoRejoin and
+// i.GuardChannels, it either tries to JOIN the channel or returns an error.
+// Prefixes the server doesn't support are always an error.
+func (i *IRC) guard(target string) error {
+	if err := i.checkStatus(target); nil != err {
+		return err
+	}
+	_, target = i.SplitStatus(target)
+	if !isChannel(target) || i.InChannel(target) {
+		return nil
+	}
+	/* Try to get back in */
+	if i.AutoRejoin {
+		pass := ""
+		if fold(target) == fold(i.Channel) {
+			pass = i.Chanpass
+		}
+		return i.Join(target, pass)
+	}
+	if i.GuardChannels {
+		return &NotInChannelError{Channel: target}
+	}
+	return nil
+}
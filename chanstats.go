@@ -0,0 +1,237 @@
+package minimalirc
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * chanstats.go
+ * Count what's said in channels
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* defaultActivityWindow is the longest window kept if i.ActivityWindow is 0 */
+const defaultActivityWindow = time.Hour
+
+// ChannelActivity describes what's been said in a channel, if i.TrackActivity is set.  Only PRIVMSGs (including CTCP ACTIONs) from others are counted.  The Recent counts and WordsPerMinute cover the Window before the ChannelActivity was returned.
+type ChannelActivity struct {
+	Channel  string
+	Since    time.Time /* When counting started */
+	Messages uint64    /* Messages since Since */
+	Words    uint64    /* Words since Since */
+	Speakers []Speaker /* Who's spoken since Since, most talkative first */
+
+	Window         time.Duration
+	RecentMessages int     /* Messages in the last Window */
+	RecentWords    int     /* Words in the last Window */
+	RecentSpeakers int     /* Nicks which spoke in the last Window */
+	WordsPerMinute float64 /* RecentWords, per minute of Window */
+}
+
+// Speaker is someone who's spoken in a channel, for ChannelActivity.
+type Speaker struct {
+	Nick     string
+	Messages uint64
+	Last     time.Time /* When they last spoke */
+}
+
+/* activity counts what's said in a channel */
+type activity struct {
+	name     string /* Channel name, as last seen */
+	since    time.Time
+	messages uint64
+	words    uint64
+	speakers map[string]*Speaker /* By fold(nick) */
+	recent   []said              /* Within i.ActivityWindow, oldest first */
+}
+
+/* said is a message counted by activity */
+type said struct {
+	at    time.Time
+	nick  string /* Folded */
+	words int
+}
+
+/* activityWindow returns the longest window for which messages are kept */
+func (i *IRC) activityWindow() time.Duration {
+	if 0 >= i.ActivityWindow {
+		return defaultActivityWindow
+	}
+	return i.ActivityWindow
+}
+
+/* prune forgets recent messages older than window */
+func (a *activity) prune(now time.Time, window time.Duration) {
+	n := sort.Search(len(a.recent), func(j int) bool {
+		return now.Sub(a.recent[j].at) <= window
+	})
+	a.recent = append(a.recent[:0], a.recent[n:]...)
+}
+
+// trackActivity counts channel messages for ChannelActivity, and moves
+// speakers' counts along with their nicks
+func (i *IRC) trackActivity(m Message) {
+	if !i.TrackActivity {
+		return
+	}
+	switch m.Command {
+	case "PRIVMSG":
+		/* Messages to some of the members (@#channel) count too */
+		_, c := i.SplitStatus(m.Param(0))
+		if !isChannel(c) || "" == m.Nick || i.isMe(m.Nick) {
+			return
+		}
+		text := m.Trailing()
+		if cmd, args, ok := ParseCTCP(text); ok {
+			if "ACTION" != cmd {
+				return
+			}
+			text = args
+		}
+		i.countActivity(c, m.Nick, len(strings.Fields(text)))
+	case "NICK":
+		from, to := fold(m.Nick), fold(m.Param(0))
+		if from == to {
+			return
+		}
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		for _, a := range i.activity {
+			s, ok := a.speakers[from]
+			if !ok {
+				continue
+			}
+			/* Someone already using the nick keeps their counts */
+			if _, ok := a.speakers[to]; ok {
+				continue
+			}
+			delete(a.speakers, from)
+			s.Nick = m.Param(0)
+			a.speakers[to] = s
+			for n := range a.recent {
+				if from == a.recent[n].nick {
+					a.recent[n].nick = to
+				}
+			}
+		}
+	}
+}
+
+/* countActivity counts a message of words words from nick in channel */
+func (i *IRC) countActivity(channel, nick string, words int) {
+	now := time.Now()
+	fn := fold(nick)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.activity {
+		i.activity = make(map[string]*activity)
+	}
+	a, ok := i.activity[fold(channel)]
+	if !ok {
+		a = &activity{since: now, speakers: make(map[string]*Speaker)}
+		i.activity[fold(channel)] = a
+	}
+	a.name = channel
+	a.messages++
+	a.words += uint64(words)
+	s, ok := a.speakers[fn]
+	if !ok {
+		s = &Speaker{}
+		a.speakers[fn] = s
+	}
+	s.Nick = nick
+	s.Messages++
+	s.Last = now
+	a.prune(now, i.activityWindow())
+	a.recent = append(a.recent, said{at: now, nick: fn, words: words})
+}
+
+// ChannelActivity returns what's been said in channel, with the Recent counts covering the last window.  If window is 0 or longer than i.ActivityWindow (an hour if 0), i.ActivityWindow is used.  Counts are kept across reconnects, and are only kept if i.TrackActivity is set.  If nothing's been counted in channel, ok is false.
+func (i *IRC) ChannelActivity(
+	channel string,
+	window time.Duration,
+) (ca ChannelActivity, ok bool) {
+	if max := i.activityWindow(); 0 >= window || window > max {
+		window = max
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	a, ok := i.activity[fold(channel)]
+	if !ok {
+		return ChannelActivity{}, false
+	}
+	return a.report(time.Now(), window, i.activityWindow()), true
+}
+
+// AllChannelActivity is like ChannelActivity, but returns what's been said in every channel in which anything's been counted, busiest in the last window first.
+func (i *IRC) AllChannelActivity(window time.Duration) []ChannelActivity {
+	if max := i.activityWindow(); 0 >= window || window > max {
+		window = max
+	}
+	now := time.Now()
+	i.mu.Lock()
+	cas := make([]ChannelActivity, 0, len(i.activity))
+	for _, a := range i.activity {
+		cas = append(cas, a.report(now, window, i.activityWindow()))
+	}
+	i.mu.Unlock()
+	sort.Slice(cas, func(j, k int) bool {
+		if cas[j].RecentMessages != cas[k].RecentMessages {
+			return cas[j].RecentMessages > cas[k].RecentMessages
+		}
+		return cas[j].Channel < cas[k].Channel
+	})
+	return cas
+}
+
+// ResetActivity forgets what's been said in channel, so counting starts again.
+func (i *IRC) ResetActivity(channel string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.activity, fold(channel))
+}
+
+// report makes a ChannelActivity for the last window.  keep is the longest
+// window kept.  i.mu must be held.
+func (a *activity) report(
+	now time.Time,
+	window time.Duration,
+	keep time.Duration,
+) ChannelActivity {
+	a.prune(now, keep)
+	ca := ChannelActivity{
+		Channel:  a.name,
+		Since:    a.since,
+		Messages: a.messages,
+		Words:    a.words,
+		Speakers: make([]Speaker, 0, len(a.speakers)),
+		Window:   window,
+	}
+	for _, s := range a.speakers {
+		ca.Speakers = append(ca.Speakers, *s)
+	}
+	sort.Slice(ca.Speakers, func(j, k int) bool {
+		sj, sk := ca.Speakers[j], ca.Speakers[k]
+		if sj.Messages != sk.Messages {
+			return sj.Messages > sk.Messages
+		}
+		return sj.Last.After(sk.Last)
+	})
+	nicks := make(map[string]bool)
+	for _, s := range a.recent {
+		if now.Sub(s.at) > window {
+			continue
+		}
+		ca.RecentMessages++
+		ca.RecentWords += s.words
+		nicks[s.nick] = true
+	}
+	ca.RecentSpeakers = len(nicks)
+	ca.WordsPerMinute = float64(ca.RecentWords) / window.Minutes()
+	return ca
+}
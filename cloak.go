@@ -0,0 +1,90 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ * cloak.go
+ * Ask for a cloaked host after registration
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* cloakTimeout is how long to wait for the server to set the cloak mode */
+const cloakTimeout = 30 * time.Second
+
+// CloakEvent is sent when the server confirms it's set the user mode in i.CloakMode.  If the server also tells us our new host, a HostmaskEvent is sent as well.  It, or an ErrorEvent if the mode isn't set in time, comes from the goroutine waiting for the server's confirmation, but OnEvent still gets it one event at a time.
+type CloakEvent struct {
+	Mode string /* The mode set */
+}
+
+func (CloakEvent) event() {}
+
+// Cloaked returns true if the server has confirmed it set i.CloakMode on this connection.
+func (i *IRC) Cloaked() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.cloaked
+}
+
+// requestCloak asks for i.CloakMode and waits for the server to confirm
+// it.  It's called after registration, and doesn't block.
+func (i *IRC) requestCloak() {
+	mode := i.CloakMode
+	if "" == mode {
+		return
+	}
+	nick := i.SNick()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			cloakTimeout)
+		defer cancel()
+		m, err := i.WaitForReply(ctx, fmt.Sprintf("MODE %v +%v", nick,
+			mode), func(m Message) bool {
+			switch m.Command {
+			case "MODE":
+				return i.isMe(m.Param(0)) &&
+					modeAdded(m.Param(1), mode[0])
+			case "501": /* ERR_UMODEUNKNOWNFLAG */
+				return true
+			}
+			return false
+		})
+		if nil == err && "501" == m.Command {
+			err = numericError(m)
+		}
+		if nil != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error requesting user mode +%v: %v", mode, err))})
+			return
+		}
+		i.mu.Lock()
+		i.cloaked = true
+		i.mu.Unlock()
+		i.event(CloakEvent{Mode: mode})
+	}()
+}
+
+/* modeAdded returns true if modes (e.g. +ix-w) adds mode c */
+func modeAdded(modes string, c byte) bool {
+	adding := true
+	for n := 0; n < len(modes); n++ {
+		switch modes[n] {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		case c:
+			if adding {
+				return true
+			}
+		}
+	}
+	return false
+}
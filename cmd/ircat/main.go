@@ -0,0 +1,117 @@
+// Ircat is netcat for IRC.  It connects to an IRC server and copies lines from stdin to the server and lines from the server to stdout, leaving registration, PINGs, and reconnecting to the library.  Lines on stdin are sent as-is, so are raw IRC protocol messages, e.g. PRIVMSG #channel :hello.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * main.go
+ * Raw IRC on stdin and stdout
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../../minimalirc.go for license details.
+ */
+
+func main() {
+	var (
+		host     = flag.String("host", "irc.libera.chat", "IRC server")
+		port     = flag.Uint("port", 6697, "IRC server `port`")
+		ssl      = flag.Bool("ssl", true, "Use TLS")
+		nick     = flag.String("nick", "ircat", "IRC `nick`")
+		username = flag.String("user", "ircat", "IRC `username`")
+		realname = flag.String("name", "minimalirc ircat", "IRC real `name`")
+		channel  = flag.String("channel", "", "Optional IRC `channel` to join")
+		idPass   = flag.String("idpass", "", "Optional NickServ `password`")
+		retry    = flag.Duration("retry", 10*time.Second,
+			"Reconnect `interval`")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %v [options]
+
+Connects to an IRC server, sends lines read from stdin to the server, and
+prints lines from the server to stdout.  PINGs are answered and the
+connection is re-established automatically.  On EOF on stdin, QUITs.
+
+Options:
+`, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	i := minimalirc.New(*host, uint16(*port), *ssl, "", *nick, *username,
+		*realname)
+	i.Channel = *channel
+	i.IdNick = *nick
+	i.IdPass = *idPass
+	i.Pongs = true
+	i.QuitLinger = 5 * time.Second
+	i.OnEvent = func(e minimalirc.Event) {
+		if e, ok := e.(minimalirc.ErrorEvent); ok {
+			log.Printf("Error: %v", e.Err)
+		}
+	}
+
+	/* Read stdin for as long as we're running */
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		s := bufio.NewScanner(os.Stdin)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+		if err := s.Err(); nil != err {
+			log.Printf("Error reading stdin: %v", err)
+		}
+	}()
+
+	/* Connect, and reconnect */
+	for {
+		if err := i.Connect(); nil != err {
+			log.Printf("Unable to connect: %v", err)
+			time.Sleep(*retry)
+			continue
+		}
+		if !pump(i, lines) {
+			return
+		}
+		log.Printf("Disconnected: %v", <-i.E)
+		time.Sleep(*retry)
+	}
+}
+
+// pump copies lines to the server and from the server to stdout until the
+// connection ends, in which case it returns true, or lines is closed, in which
+// case it QUITs and returns false.
+func pump(i *minimalirc.IRC, lines <-chan string) bool {
+	for {
+		select {
+		case l, ok := <-i.C:
+			if !ok {
+				return true
+			}
+			fmt.Println(l)
+		case l, ok := <-lines:
+			if !ok {
+				if err := i.Quit(""); nil != err {
+					log.Printf("Error quitting: %v", err)
+				}
+				for l := range i.C {
+					fmt.Println(l)
+				}
+				return false
+			}
+			if err := i.PrintfLine("%s", l); nil != err {
+				log.Printf("Error sending %q: %v", l, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package minimalirc
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+ * cmdargs.go
+ * Split bot commands' arguments into words, flags, and subcommands
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ErrUnterminatedQuote is returned by SplitArgs when a quote isn't closed.
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// SplitArgs splits s into words, like a shell.  Words are separated by spaces.  Text in double or single quotes is part of one word, spaces and all.  Outside single quotes, a backslash makes the next character literal.
+func SplitArgs(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		in    bool /* In a word, even an empty quoted one */
+		quote rune /* Quote we're in, or 0 */
+		esc   bool /* Last character was a backslash */
+	)
+	for _, c := range s {
+		switch {
+		case esc:
+			word.WriteRune(c)
+			esc = false
+		case '\\' == c && '\'' != quote:
+			esc, in = true, true
+		case 0 != quote && c == quote:
+			quote = 0
+		case 0 != quote:
+			word.WriteRune(c)
+		case '"' == c || '\'' == c:
+			quote, in = c, true
+		case ' ' == c || '\t' == c:
+			if in {
+				words = append(words, word.String())
+				word.Reset()
+				in = false
+			}
+		default:
+			word.WriteRune(c)
+			in = true
+		}
+	}
+	if 0 != quote {
+		return nil, ErrUnterminatedQuote
+	}
+	/* A trailing backslash is just a backslash */
+	if esc {
+		word.WriteByte('\\')
+	}
+	if in {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parseArgs splits c.Args into c.Words and c.Flags.  Words starting with -
+// or -- are flags, with values after an =, until a word which is just --.
+// Negative numbers aren't flags.
+func (c *Command) parseArgs() {
+	words, err := SplitArgs(c.Args)
+	if nil != err {
+		c.ArgsError = err
+		return
+	}
+	c.Words = []string{}
+	c.Flags = make(map[string]string)
+	for n, w := range words {
+		if "--" == w {
+			c.Words = append(c.Words, words[n+1:]...)
+			return
+		}
+		if !strings.HasPrefix(w, "-") || "-" == w {
+			c.Words = append(c.Words, w)
+			continue
+		}
+		if _, err := strconv.ParseFloat(w, 64); nil == err {
+			c.Words = append(c.Words, w)
+			continue
+		}
+		name, value := strings.TrimLeft(w, "-"), ""
+		if n := strings.IndexByte(name, '='); -1 != n {
+			name, value = name[:n], name[n+1:]
+		}
+		c.Flags[name] = value
+	}
+}
+
+// Word returns the nth word of c's arguments, counting from 0, not counting flags, or the empty string if there aren't that many.
+func (c *Command) Word(n int) string {
+	if 0 > n || len(c.Words) <= n {
+		return ""
+	}
+	return c.Words[n]
+}
+
+// Flag returns the value of the flag name (e.g. "v" for -v, or "count" for --count=3) in c's arguments, and whether it was given.  Flags given without an = have the empty string as their value.
+func (c *Command) Flag(name string) (string, bool) {
+	v, ok := c.Flags[name]
+	return v, ok
+}
+
+// HandleSubcommand registers f to be called for the command name when its first argument is sub, e.g. "!config set nick bot" for name "config" and sub "set".  Subcommand names are case-insensitive.  The Command passed to f has Subcommand set, and its Args and Words don't include sub.  If the first argument isn't a registered subcommand, the function registered with HandleCommand, if any, is called.  RequireRole may be given "name sub" to require a Role for only the subcommand.  Registering a nil f removes the subcommand.
+func (i *IRC) HandleSubcommand(name, sub string, f CommandFunc) {
+	name, sub = strings.ToLower(name), strings.ToLower(sub)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == f {
+		delete(i.subcmds, name+" "+sub)
+		return
+	}
+	if nil == i.subcmds {
+		i.subcmds = make(map[string]CommandFunc)
+	}
+	i.subcmds[name+" "+sub] = f
+}
+
+// hasSubcommands returns true if any subcommands of the command name have
+// been registered.  i.mu must be held.
+func (i *IRC) hasSubcommands(name string) bool {
+	for k := range i.subcmds {
+		if strings.HasPrefix(k, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// commandFunc returns the function to call for c, taking the subcommand, if
+// there is one, off c.Args
+func (i *IRC) commandFunc(c *Command) (CommandFunc, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	sub, rest := splitSpace(c.Args)
+	if f, ok := i.subcmds[c.Name+" "+strings.ToLower(sub)]; ok {
+		c.Subcommand = strings.ToLower(sub)
+		c.Args = rest
+		return f, true
+	}
+	f, ok := i.commands[c.Name]
+	return f, ok
+}
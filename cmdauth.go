@@ -0,0 +1,105 @@
+package minimalirc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * cmdauth.go
+ * Authenticate bot commands with an HMAC
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* defaultCommandWindow is used if i.CommandWindow isn't set */
+const defaultCommandWindow = 5 * time.Minute
+
+// RejectedCommandEvent is sent when a command fails authentication with i.CommandKey.
+type RejectedCommandEvent struct {
+	From   string /* nick!user@host of the sender */
+	Line   string /* Text of the PRIVMSG */
+	Reason error  /* Why it was rejected */
+}
+
+func (RejectedCommandEvent) event() {}
+
+// SignCommand appends an authentication tag to line, a command as it would be sent in a PRIVMSG (e.g. "!op alice"), for use when i.CommandKey is set on the receiving end.  The tag is a final word of the form <unix time>:<hex HMAC-SHA256>, where the HMAC is of the Unix time, a colon, and line.  The returned line may be sent only once, within the receiver's i.CommandWindow of t.
+func SignCommand(key []byte, line string, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return line + " " + ts + ":" + commandMAC(key, ts, line)
+}
+
+/* commandMAC returns the hex HMAC of ts:line */
+func commandMAC(key []byte, ts, line string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(ts + ":" + line))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifyCommand checks the tag on the end of c, and returns c.Args without
+// the tag.
+func (i *IRC) verifyCommand(c *Command) (string, error) {
+	text := c.Message.Trailing()
+	/* Split off the tag */
+	n := strings.LastIndexByte(text, ' ')
+	if -1 == n {
+		return "", errors.New("no authentication tag")
+	}
+	line, tag := text[:n], text[n+1:]
+	parts := strings.SplitN(tag, ":", 2)
+	if 2 != len(parts) {
+		return "", errors.New("malformed authentication tag")
+	}
+	if !hmac.Equal([]byte(parts[1]),
+		[]byte(commandMAC(i.CommandKey, parts[0], line))) {
+		return "", errors.New("incorrect HMAC")
+	}
+	/* Make sure it's fresh */
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if nil != err {
+		return "", errors.New("malformed timestamp")
+	}
+	w := i.CommandWindow
+	if 0 >= w {
+		w = defaultCommandWindow
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age > w || age < -w {
+		return "", errors.New("timestamp outside window")
+	}
+	/* Make sure it's not a replay */
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.seenTags {
+		i.seenTags = make(map[string]time.Time)
+	}
+	for k, v := range i.seenTags {
+		if time.Since(v) > 2*w {
+			delete(i.seenTags, k)
+		}
+	}
+	if _, ok := i.seenTags[tag]; ok {
+		return "", errors.New("replayed command")
+	}
+	i.seenTags[tag] = time.Now()
+	/* Args without the tag */
+	_, args := splitSpace(line)
+	return args, nil
+}
+
+/* rejectCommand reports a command which failed authentication */
+func (i *IRC) rejectCommand(c *Command, err error) {
+	i.event(RejectedCommandEvent{
+		From:   c.Message.Prefix,
+		Line:   c.Message.Trailing(),
+		Reason: err,
+	})
+}
@@ -0,0 +1,158 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ * cmdlimit.go
+ * Stop one sender keeping the bot busy
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// CommandLimit limits how often one sender may run bot commands.  A sender may run Burst commands at once, and then another each Every, but no more than one per Cooldown.  Burst and Every are only used if both are positive, and Cooldown only if it's positive, so the zero CommandLimit doesn't limit anything.  Commands over the limit are dropped.  If Quiet isn't empty, it's sent to the sender in a NOTICE the first time they're over the limit, until they're allowed to run a command again.
+type CommandLimit struct {
+	Burst    int           /* Commands allowed at once */
+	Every    time.Duration /* Time for Burst to allow another */
+	Cooldown time.Duration /* Minimum time between commands */
+	Quiet    string        /* Sent when over the limit, if not empty */
+}
+
+// ThrottledCommandEvent is sent when a command is dropped because its sender is over i.CommandLimit or the command's limit set with LimitCommand.
+type ThrottledCommandEvent struct {
+	From    string        /* nick!user@host of the sender */
+	Command string        /* Name of the command */
+	Wait    time.Duration /* Time until they may run it */
+}
+
+func (ThrottledCommandEvent) event() {}
+
+/* cmdBucketKey identifies a sender's use of a command, or all commands */
+type cmdBucketKey struct {
+	command string /* Command with its own limit, or "" */
+	sender  string /* Folded host, or nick if there's no host */
+}
+
+/* cmdBucket tracks a sender's commands */
+type cmdBucket struct {
+	tokens float64   /* Commands the Burst allows now */
+	last   time.Time /* When tokens was worked out */
+	ran    time.Time /* When the last command was allowed */
+	warned bool      /* Quiet has been sent */
+}
+
+// LimitCommand gives the command name its own limit, in place of i.CommandLimit.  Each sender's use of the command is counted separately from their other commands.  The zero CommandLimit lets the command be run as often as anybody likes.  Calling LimitCommand with a nil l goes back to using i.CommandLimit.
+func (i *IRC) LimitCommand(name string, l *CommandLimit) {
+	name = strings.ToLower(name)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == l {
+		delete(i.cmdLimits, name)
+		return
+	}
+	if nil == i.cmdLimits {
+		i.cmdLimits = make(map[string]CommandLimit)
+	}
+	i.cmdLimits[name] = *l
+}
+
+// allowCommand returns true if c's sender may run it now.  If not, it sends
+// a ThrottledCommandEvent and, if the limit says to, tells them to be quiet.
+func (i *IRC) allowCommand(c *Command) bool {
+	now := time.Now()
+	sender := c.Message.Host
+	if "" == sender {
+		sender = c.Message.Nick
+	}
+	i.mu.Lock()
+	key := cmdBucketKey{sender: fold(sender)}
+	l, ok := i.cmdLimits[c.Name]
+	if ok {
+		key.command = c.Name
+	} else {
+		l = i.CommandLimit
+	}
+	useBurst := 0 < l.Burst && 0 < l.Every
+	if !useBurst && 0 >= l.Cooldown {
+		i.mu.Unlock()
+		return true
+	}
+	if nil == i.cmdBuckets {
+		i.cmdBuckets = make(map[cmdBucketKey]*cmdBucket)
+	}
+	i.pruneCmdBuckets(now)
+	b, ok := i.cmdBuckets[key]
+	if !ok {
+		b = &cmdBucket{tokens: float64(l.Burst), last: now}
+		i.cmdBuckets[key] = b
+	}
+
+	/* Work out how long they've got to wait */
+	var wait time.Duration
+	if useBurst {
+		b.tokens += float64(now.Sub(b.last)) / float64(l.Every)
+		if b.tokens > float64(l.Burst) {
+			b.tokens = float64(l.Burst)
+		}
+		b.last = now
+		if 1 > b.tokens {
+			wait = time.Duration((1 - b.tokens) * float64(l.Every))
+		}
+	}
+	if 0 < l.Cooldown && !b.ran.IsZero() {
+		if w := l.Cooldown - now.Sub(b.ran); w > wait {
+			wait = w
+		}
+	}
+	if 0 >= wait {
+		if useBurst {
+			b.tokens--
+		}
+		b.ran = now
+		b.warned = false
+		i.mu.Unlock()
+		return true
+	}
+	warn := !b.warned && "" != l.Quiet
+	b.warned = true
+	i.mu.Unlock()
+
+	/* Too soon */
+	i.event(ThrottledCommandEvent{
+		From:    c.Message.Prefix,
+		Command: c.Name,
+		Wait:    wait,
+	})
+	if !warn {
+		return false
+	}
+	if err := i.Notice(l.Quiet, c.Message.Nick); nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error telling %v to slow down: %v", c.Message.Nick, err))})
+	}
+	return false
+}
+
+// pruneCmdBuckets forgets senders who've not run a command for long enough
+// that their limits would have reset anyway.  i.mu must be held.
+func (i *IRC) pruneCmdBuckets(now time.Time) {
+	for k, b := range i.cmdBuckets {
+		l, ok := i.cmdLimits[k.command]
+		if "" == k.command || !ok {
+			l = i.CommandLimit
+		}
+		full := time.Duration(l.Burst) * l.Every
+		if l.Cooldown > full {
+			full = l.Cooldown
+		}
+		if now.Sub(b.ran) > full && now.Sub(b.last) > full {
+			delete(i.cmdBuckets, k)
+		}
+	}
+}
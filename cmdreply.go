@@ -0,0 +1,86 @@
+package minimalirc
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * cmdreply.go
+ * Answer bot commands
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Reply answers c with the text made by fmt.Sprintf(f, args...), sent where ReplyTo would send it: to the channel if c was sent to one, to the same members of the channel if c was sent to some of them with a STATUSMSG prefix (e.g. @#channel), or to the sender if c was sent to us.  If i.TranslateReply is set, f is passed through it first.  Text is sent a line at a time, with long lines split to fit PrivmsgSize, between words if possible.  Empty lines aren't sent.  The first error sending a line is returned, and the rest of the lines aren't sent.
+func (c *Command) Reply(f string, args ...interface{}) error {
+	t := c.IRC.replyTarget(c.Message)
+	return c.send(t, f, args, func(line string) error {
+		return c.IRC.ReplyTo(c.Message, line)
+	})
+}
+
+// ReplyPrivately is like Reply, but always sends the text to the sender, in PRIVMSGs.
+func (c *Command) ReplyPrivately(f string, args ...interface{}) error {
+	return c.send(c.Message.Nick, f, args, func(line string) error {
+		return c.IRC.Privmsg(line, c.Message.Nick)
+	})
+}
+
+// ReplyNotice is like Reply, but sends the text to the sender in NOTICEs, which clients usually show less obtrusively and bots shouldn't answer.
+func (c *Command) ReplyNotice(f string, args ...interface{}) error {
+	return c.send(c.Message.Nick, f, args, func(line string) error {
+		return c.IRC.Notice(line, c.Message.Nick)
+	})
+}
+
+// send formats and splits the reply to c for target, and sends each line
+// with send
+func (c *Command) send(
+	target string,
+	f string,
+	args []interface{},
+	send func(line string) error,
+) error {
+	if nil != c.IRC.TranslateReply {
+		f = c.IRC.TranslateReply(c, f)
+	}
+	n := c.IRC.PrivmsgSize(target)
+	for _, l := range strings.Split(fmt.Sprintf(f, args...), "\n") {
+		for _, p := range splitWords(strings.TrimRight(l, "\r"), n) {
+			if err := send(p); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitWords splits s into pieces of at most n bytes, at spaces if it can.
+// The spaces at which s is split are dropped.  Empty pieces aren't returned.
+func splitWords(s string, n int) []string {
+	var ps []string
+	if 0 >= n {
+		n = len(s)
+	}
+	for len(s) > n {
+		c := strings.LastIndexByte(s[:n+1], ' ')
+		if 0 >= c {
+			/* One long word */
+			p := splitRunes(s, n)[0]
+			ps = append(ps, p)
+			s = s[len(p):]
+			continue
+		}
+		if p := strings.TrimRight(s[:c], " "); "" != p {
+			ps = append(ps, p)
+		}
+		s = strings.TrimLeft(s[c:], " ")
+	}
+	if "" != strings.TrimSpace(s) {
+		ps = append(ps, s)
+	}
+	return ps
+}
@@ -0,0 +1,114 @@
+package minimalirc
+
+import (
+	"context"
+	"strings"
+)
+
+/*
+ * commands.go
+ * Bot commands
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// CommandFunc is a function which handles a bot command.
+type CommandFunc func(c *Command)
+
+// Command is a bot command sent to us in a PRIVMSG, as passed to a CommandFunc.
+type Command struct {
+	IRC     *IRC            /* Connection on which it was received */
+	Context context.Context /* As would be passed to a Handler */
+	Message Message         /* The PRIVMSG */
+	Name    string          /* Command name, lower-case, sans prefix */
+	Args    string          /* Everything after the name */
+	Account string          /* Sender's account, if known */
+	Role    Role            /* Sender's Role */
+
+	Subcommand string            /* Set by HandleSubcommand, lower-case */
+	Words      []string          /* Args, split by SplitArgs, sans flags */
+	Flags      map[string]string /* Flags from Args, see Flag */
+	ArgsError  error             /* Why Args couldn't be split, if not */
+}
+
+// HandleCommand registers f to be called when a PRIVMSG starting with i.CommandPrefix (or "!" if it's unset) followed by name is received.  In private messages to us the prefix is optional.  Names are case-insensitive.  Args are split into Words and Flags as described for SplitArgs; words starting with - or -- (e.g. -v or --count=3) are flags until a word which is just --.  Commands are run after handlers, from the goroutine reading from the server.  How often each sender may run commands is limited by i.CommandLimit, or the command's own limit set with LimitCommand.  Commands may be limited to senders with a Role with RequireRole.  Registering a nil f removes the command.
+func (i *IRC) HandleCommand(name string, f CommandFunc) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.commands {
+		i.commands = make(map[string]CommandFunc)
+	}
+	if nil == f {
+		delete(i.commands, strings.ToLower(name))
+		return
+	}
+	i.commands[strings.ToLower(name)] = f
+}
+
+/* parseCommand works out whether m is a command, and if so returns it */
+func (i *IRC) parseCommand(ctx context.Context, m Message) (*Command, bool) {
+	if "PRIVMSG" != m.Command {
+		return nil, false
+	}
+	prefix := i.CommandPrefix
+	if "" == prefix {
+		prefix = "!"
+	}
+	/* Prefix is only needed in channels */
+	text := m.Trailing()
+	if strings.HasPrefix(text, prefix) {
+		text = text[len(prefix):]
+	} else if !i.isMe(m.Param(0)) {
+		return nil, false
+	}
+	name, args := splitSpace(text)
+	if "" == name {
+		return nil, false
+	}
+	return &Command{
+		IRC:     i,
+		Context: ctx,
+		Message: m,
+		Name:    strings.ToLower(name),
+		Args:    args,
+	}, true
+}
+
+/* runCommand runs the command in m, if it's a command we know */
+func (i *IRC) runCommand(ctx context.Context, m Message) {
+	c, ok := i.parseCommand(ctx, m)
+	if !ok {
+		return
+	}
+	i.mu.Lock()
+	_, ok = i.commands[c.Name]
+	subs := i.hasSubcommands(c.Name)
+	i.mu.Unlock()
+	if !ok && !subs {
+		return
+	}
+	/* Make sure it's from someone who knows the key */
+	if 0 != len(i.CommandKey) {
+		args, err := i.verifyCommand(c)
+		if nil != err {
+			i.rejectCommand(c, err)
+			return
+		}
+		c.Args = args
+	}
+	f, ok := i.commandFunc(c)
+	if !ok {
+		return
+	}
+	c.parseArgs()
+	/* Make sure they're allowed, and not too often */
+	if !i.permitCommand(c) {
+		return
+	}
+	if !i.allowCommand(c) {
+		return
+	}
+	f(c)
+}
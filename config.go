@@ -0,0 +1,141 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ * config.go
+ * Change settings on a live connection
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Config holds the settings which may be changed on a live connection with Apply.
+type Config struct {
+	Nick       string            /* Nick to use */
+	IdNick     string            /* To auth to NickServ */
+	IdPass     string            /* To auth to NickServ */
+	Channels   map[string]string /* Channels to be in, mapped to keys */
+	Txp        string            /* Prefix for logging sent messages */
+	Rxp        string            /* Prefix for logging received messages */
+	LogSecrets bool              /* Don't redact passwords in logs */
+
+	MaxBytesPerSec int           /* Throttle sent bytes per second, if >0 */
+	JitterMin      time.Duration /* Minimum random delay before sending */
+	JitterMax      time.Duration /* Maximum random delay, none if 0 */
+	Limiter        *Limiter      /* Limits lines sent, if not nil */
+}
+
+// Config returns the current settings.  Channels holds the channels we're in, with empty keys.
+func (i *IRC) Config() Config {
+	chs := i.Channels()
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+	c := Config{
+		Nick:           i.Nick,
+		IdNick:         i.IdNick,
+		IdPass:         i.IdPass,
+		Channels:       make(map[string]string),
+		Txp:            i.Txp,
+		Rxp:            i.Rxp,
+		LogSecrets:     i.LogSecrets,
+		MaxBytesPerSec: i.MaxBytesPerSec,
+		JitterMin:      i.JitterMin,
+		JitterMax:      i.JitterMax,
+		Limiter:        i.Limiter,
+	}
+	for _, ch := range chs {
+		c.Channels[ch] = ""
+	}
+	return c
+}
+
+// Apply changes i's settings to c without reconnecting.  Unlike setting i's fields directly, it's safe to call while connected.  The logging prefixes and rate limits are changed together, between sent lines.  Channels in c.Channels we're not in are JOINed, and channels we're in which aren't in c.Channels are PARTed.  If c.Nick differs from i.Nick, the nick is changed, and if the NickServ credentials changed, we identify again.  An empty c.Nick leaves the nick as it is, and a nil c.Channels leaves the channels as they are; to leave every channel, use an empty map.  Channels aren't remembered across reconnects; i.Channel is still used for that.  The easiest way to change some settings is to change those in what Config returns.  The first error encountered is returned, after trying the rest of the changes.
+func (i *IRC) Apply(c Config) error {
+	/* Settings which affect sending, all at once */
+	i.wmu.Lock()
+	i.cmu.Lock()
+	i.Txp = c.Txp
+	i.Rxp = c.Rxp
+	i.LogSecrets = c.LogSecrets
+	i.MaxBytesPerSec = c.MaxBytesPerSec
+	i.JitterMin = c.JitterMin
+	i.JitterMax = c.JitterMax
+	i.Limiter = c.Limiter
+	nickChanged := "" != c.Nick && c.Nick != i.Nick
+	credsChanged := c.IdNick != i.IdNick || c.IdPass != i.IdPass
+	if nickChanged {
+		i.Nick = c.Nick
+	}
+	i.IdNick = c.IdNick
+	i.IdPass = c.IdPass
+	i.cmu.Unlock()
+	i.wmu.Unlock()
+
+	/* Nothing else makes sense without a connection */
+	if Disconnected == i.State() {
+		return nil
+	}
+	var first error
+	note := func(err error) {
+		if nil != err && nil == first {
+			first = err
+		}
+	}
+	if nickChanged {
+		note(i.PrintfLine("NICK :%v", i.fitNick(c.Nick)))
+	}
+	if credsChanged {
+		note(i.Auth())
+	}
+
+	/* Work out which channels to leave and join */
+	if nil == c.Channels {
+		return first
+	}
+	want := make(map[string]bool, len(c.Channels))
+	for ch, key := range c.Channels {
+		want[fold(ch)] = true
+		if i.InChannel(ch) {
+			continue
+		}
+		note(i.Join(ch, key))
+	}
+	for _, ch := range i.Channels() {
+		if want[fold(ch)] {
+			continue
+		}
+		if err := i.PrintfLine("PART %v", ch); nil != err {
+			note(errors.New(fmt.Sprintf("error leaving %v: %v",
+				ch, err)))
+		}
+	}
+	return first
+}
+
+// logSettings returns i.Txp, i.Rxp, and i.LogSecrets, which Apply may be
+// changing
+func (i *IRC) logSettings() (txp, rxp string, secrets bool) {
+	i.cmu.Lock()
+	defer i.cmu.Unlock()
+	return i.Txp, i.Rxp, i.LogSecrets
+}
+
+/* creds returns i.IdNick and i.IdPass, which Apply may be changing */
+func (i *IRC) creds() (nick, pass string) {
+	i.cmu.Lock()
+	defer i.cmu.Unlock()
+	return i.IdNick, i.IdPass
+}
+
+/* configNick returns i.Nick, which Apply may be changing */
+func (i *IRC) configNick() string {
+	i.cmu.Lock()
+	defer i.cmu.Unlock()
+	return i.Nick
+}
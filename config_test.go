@@ -0,0 +1,74 @@
+package minimalirc
+
+import (
+	"testing"
+)
+
+/*
+ * config_test.go
+ * Test changing settings on a live connection
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* newConfigTest returns an IRC struct registered and in #a and #b */
+func newConfigTest(t *testing.T) (*IRC, *sentLines) {
+	i := New("irc.example.com", 6697, true, "", "nick", "u", "r")
+	sent := &sentLines{}
+	i.use(sent)
+	i.Feed(":irc.example.com 001 nick :Welcome")
+	i.Feed(":nick!u@h JOIN #a")
+	i.Feed(":nick!u@h JOIN #b")
+	sent.take()
+	return i, sent
+}
+
+func TestApplyEmpty(t *testing.T) {
+	i, sent := newConfigTest(t)
+	if err := i.Apply(Config{}); nil != err {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := sent.take(); 0 != len(got) {
+		t.Errorf("sent %q", got)
+	}
+	if "nick" != i.Nick {
+		t.Errorf("nick changed to %q", i.Nick)
+	}
+	if got := i.Channels(); 2 != len(got) {
+		t.Errorf("in %q", got)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	i, sent := newConfigTest(t)
+	c := i.Config()
+	c.Nick = "other"
+	c.Channels = map[string]string{"#b": "", "#c": "key"}
+	if err := i.Apply(c); nil != err {
+		t.Fatalf("Apply: %v", err)
+	}
+	want := []string{"NICK :other", "JOIN #c key", "PART #a"}
+	got := sent.take()
+	if len(got) != len(want) {
+		t.Fatalf("sent %q, not %q", got, want)
+	}
+	for n := range got {
+		if got[n] != want[n] {
+			t.Fatalf("sent %q, not %q", got, want)
+		}
+	}
+	if "other" != i.Nick {
+		t.Errorf("nick is %q", i.Nick)
+	}
+
+	/* An empty map leaves every channel we're still in */
+	c.Channels = map[string]string{}
+	if err := i.Apply(c); nil != err {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := sent.take(); 2 != len(got) {
+		t.Errorf("sent %q, not two PARTs", got)
+	}
+}
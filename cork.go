@@ -0,0 +1,67 @@
+package minimalirc
+
+import (
+	"time"
+)
+
+/*
+ * cork.go
+ * Send several lines at once
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Cork stops lines sent with PrintfLine (and everything which uses it) from being sent to the server until Uncork or Flush is called, so that a burst of lines can be sent together.  Lines are still sent if the buffer fills.  Calls to Cork nest; the lines are only sent when Uncork has been called as many times as Cork.  Lines are logged (see i.Txp) when they're buffered, not when they're sent.
+func (i *IRC) Cork() {
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+	i.corks++
+}
+
+// Uncork undoes a call to Cork, and sends buffered lines to the server if it was the last.
+func (i *IRC) Uncork() error {
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+	if 0 < i.corks {
+		i.corks--
+	}
+	if 0 != i.corks {
+		return nil
+	}
+	return i.flush()
+}
+
+// Flush sends any lines buffered by Cork to the server, without uncorking.
+func (i *IRC) Flush() error {
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+	return i.flush()
+}
+
+// writeLine writes line to the server, flushing it unless we're corked.
+// at is when the line was given to PrintfLine.  i.wmu must be held.
+func (i *IRC) writeLine(line string, at time.Time) error {
+	if _, err := i.w.W.WriteString(line + "\r\n"); nil != err {
+		return err
+	}
+	if i.TrackLatency {
+		i.queued = append(i.queued, at)
+	}
+	if 0 != i.corks {
+		return nil
+	}
+	return i.flush()
+}
+
+// flush flushes the writer, and notes how long the flushed lines took to
+// send.  i.wmu must be held.
+func (i *IRC) flush() error {
+	err := i.w.W.Flush()
+	if 0 != len(i.queued) {
+		i.countLatency(i.queued, time.Now())
+		i.queued = i.queued[:0]
+	}
+	return err
+}
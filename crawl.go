@@ -0,0 +1,171 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * crawl.go
+ * List the channels on a server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+const (
+	crawlTries      = 5                /* LISTs to try before giving up */
+	crawlBackoffMin = 10 * time.Second /* First delay after 263 */
+	crawlMinUsers   = 2                /* First filter if LIST's too big */
+)
+
+// ChannelInfo is a channel as listed by the server in reply to LIST.
+type ChannelInfo struct {
+	Name  string
+	Users int
+	Topic string
+}
+
+// CrawlOptions control Crawl.
+type CrawlOptions struct {
+	MinUsers int /* Only list channels with more users than this */
+	Join     int /* Join this many of the biggest channels afterwards */
+}
+
+// Crawl asks the server for its channels with LIST, and calls f with each one as it's received.  If o.MinUsers is set and the server's ISUPPORT ELIST has U, the server's asked to filter by user count (LIST >n), otherwise filtering happens here.  If the server's busy (RPL_TRYAGAIN, 263), we back off and try again.  If the server says the list is too big (ERR_TOOMANYMATCHES, 416), it's asked right away for ever-bigger channels.  Channels listed again on a retry aren't passed to f a second time.  If o.Join is positive, the biggest o.Join channels are joined with JoinThrottled once the list is done, and Crawl returns after they've all been tried, with the first error joining, if any.
+func (i *IRC) Crawl(ctx context.Context, o CrawlOptions,
+	f func(c ChannelInfo)) error {
+	var (
+		min     = o.MinUsers
+		backoff = crawlBackoffMin
+		biggest []ChannelInfo
+		seen    = make(map[string]bool)
+		err     error
+	)
+	for try := 0; try < crawlTries; try++ {
+		var retry bool
+		last := min
+		retry, min, err = i.crawl(ctx, min, func(c ChannelInfo) {
+			/* Retries list some channels again */
+			if c.Users <= o.MinUsers || seen[fold(c.Name)] {
+				return
+			}
+			seen[fold(c.Name)] = true
+			biggest = keepBiggest(biggest, c, o.Join)
+			f(c)
+		})
+		if nil != err || !retry {
+			break
+		}
+		/* A bigger filter can be tried right away */
+		if min != last {
+			continue
+		}
+		/* Wait a bit if the server asked */
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	if nil != err || 0 >= o.Join {
+		return err
+	}
+
+	/* Join the biggest */
+	chs := make([]string, len(biggest))
+	for n, c := range biggest {
+		chs[n] = c.Name
+	}
+	for r := range i.JoinThrottled(ctx, chs, nil) {
+		if nil != r.Err && nil == err {
+			err = errors.New(fmt.Sprintf("error joining %v: %v",
+				r.Channel, r.Err))
+		}
+	}
+	return err
+}
+
+// crawl sends a single LIST, filtered for channels with more than min users
+// if min is positive.  It returns whether to try again, and the filter to
+// use if so.
+func (i *IRC) crawl(ctx context.Context, min int,
+	f func(c ChannelInfo)) (bool, int, error) {
+	cmd := "LIST"
+	if v, _ := i.ISupport("ELIST"); 0 < min &&
+		strings.ContainsAny(v, "Uu") {
+		cmd = fmt.Sprintf("LIST >%d", min)
+	}
+	var (
+		tryAgain bool
+		tooBig   bool
+		nerr     *NumericError
+	)
+	err := i.stream(ctx, func() error {
+		return i.PrintfLine("%s", cmd)
+	}, func(m Message) (bool, bool) {
+		switch m.Command {
+		case "322": /* RPL_LIST */
+			return true, false
+		case "323": /* RPL_LISTEND */
+			return false, true
+		case "263": /* RPL_TRYAGAIN */
+			if "LIST" == strings.ToUpper(m.Param(1)) {
+				tryAgain = true
+				return false, true
+			}
+		case "416": /* ERR_TOOMANYMATCHES */
+			tooBig = true
+			nerr = numericError(m)
+			return false, true
+		}
+		return false, false
+	}, func(m Message) {
+		n, _ := strconv.Atoi(m.Param(2))
+		f(ChannelInfo{Name: m.Param(1), Users: n, Topic: m.Param(3)})
+	})
+	switch {
+	case nil != err:
+		return false, min, err
+	case tryAgain:
+		return true, min, nil
+	case tooBig:
+		/* Can't filter if the server won't */
+		if v, _ := i.ISupport("ELIST"); !strings.ContainsAny(v, "Uu") {
+			return false, min, nerr
+		}
+		if min < crawlMinUsers {
+			return true, crawlMinUsers, nil
+		}
+		return true, min * 2, nil
+	}
+	return false, min, nil
+}
+
+// keepBiggest adds c to cs, which is sorted biggest first, keeping at most
+// n channels
+func keepBiggest(cs []ChannelInfo, c ChannelInfo, n int) []ChannelInfo {
+	if 0 >= n {
+		return cs
+	}
+	at := sort.Search(len(cs), func(j int) bool {
+		return cs[j].Users < c.Users
+	})
+	if at >= n {
+		return cs
+	}
+	cs = append(cs, ChannelInfo{})
+	copy(cs[at+1:], cs[at:])
+	cs[at] = c
+	if len(cs) > n {
+		cs = cs[:n]
+	}
+	return cs
+}
@@ -0,0 +1,73 @@
+package minimalirc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+ * crawl_test.go
+ * Test listing the channels on a server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* waitSent waits up to a second for the next line sent, which should be l */
+func waitSent(t *testing.T, sent *sentLines, l string) {
+	for end := time.Now().Add(time.Second); time.Now().Before(end); {
+		if got := sent.take(); 0 != len(got) {
+			if 1 != len(got) || l != got[0] {
+				t.Fatalf("sent %q, not %q", got, l)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%q not sent", l)
+}
+
+func TestCrawlTooBig(t *testing.T) {
+	i, sent := newTargetsTest(t, "ELIST=U")
+	var (
+		mu   sync.Mutex
+		got  []string
+		done = make(chan error, 1)
+	)
+	go func() {
+		done <- i.Crawl(context.Background(), CrawlOptions{},
+			func(c ChannelInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, c.Name)
+			})
+	}()
+
+	/* First try's too big, but a channel sneaks in first */
+	waitSent(t, sent, "LIST")
+	i.Feed(":irc.example.com 322 nick #a 5 :topic")
+	i.Feed(":irc.example.com 416 nick LIST :Too many matches")
+
+	/* Second try should be right away, with a filter */
+	waitSent(t, sent, "LIST >2")
+	i.Feed(":irc.example.com 322 nick #A 5 :topic")
+	i.Feed(":irc.example.com 322 nick #b 3 :topic")
+	i.Feed(":irc.example.com 323 nick :End of /LIST")
+
+	select {
+	case err := <-done:
+		if nil != err {
+			t.Fatalf("Crawl: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Crawl didn't finish")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if 2 != len(got) || "#a" != got[0] || "#b" != got[1] {
+		t.Errorf("got %q", got)
+	}
+}
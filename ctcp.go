@@ -0,0 +1,148 @@
+package minimalirc
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+ * ctcp.go
+ * Client-to-client protocol
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* Defaults for CTCP flood protection */
+const (
+	defaultCTCPFloodCount  = 3
+	defaultCTCPFloodWindow = 15 * time.Second
+	defaultCTCPFloodTotal  = 10
+	defaultCTCPVersion     = "minimalirc"
+)
+
+// CTCPEvent is sent when a CTCP request (other than ACTION) is received.  Dropped is true if the request wasn't answered due to flood protection.
+type CTCPEvent struct {
+	From    string /* nick!user@host of the sender */
+	Target  string /* Channel or our nick */
+	Command string /* CTCP command, upper-case */
+	Args    string /* Anything after the command */
+	Dropped bool   /* True if flood protection kicked in */
+}
+
+func (CTCPEvent) event() {}
+
+// ParseCTCP extracts the command and arguments from text, the body of a PRIVMSG or NOTICE, if it's a CTCP message.
+func ParseCTCP(text string) (command, args string, ok bool) {
+	if 2 > len(text) || '\x01' != text[0] {
+		return "", "", false
+	}
+	text = strings.TrimSuffix(text[1:], "\x01")
+	command, args = splitSpace(text)
+	return strings.ToUpper(command), args, true
+}
+
+// CTCP sends a CTCP request to target in a PRIVMSG.  If args is the empty string, only the command is sent.
+func (i *IRC) CTCP(target, command, args string) error {
+	return i.Privmsg(ctcpText(command, args), target)
+}
+
+// CTCPReply sends a CTCP reply to target in a NOTICE.
+func (i *IRC) CTCPReply(target, command, args string) error {
+	return i.Notice(ctcpText(command, args), target)
+}
+
+/* ctcpText wraps command and args in \x01s */
+func ctcpText(command, args string) string {
+	if "" == args {
+		return "\x01" + command + "\x01"
+	}
+	return "\x01" + command + " " + args + "\x01"
+}
+
+// handleCTCP answers CTCP requests, if i.CTCPReplies is set, unless the
+// sender's host or everybody together have sent too many recently
+func (i *IRC) handleCTCP(m Message) {
+	if "PRIVMSG" != m.Command {
+		return
+	}
+	cmd, args, ok := ParseCTCP(m.Trailing())
+	if !ok || "ACTION" == cmd {
+		return
+	}
+	e := CTCPEvent{
+		From:    m.Prefix,
+		Target:  m.Param(0),
+		Command: cmd,
+		Args:    args,
+		Dropped: i.ctcpFlooding(m.Host),
+	}
+	i.event(e)
+	if !i.CTCPReplies || e.Dropped || i.PassiveMode {
+		return
+	}
+	/* Work out the reply */
+	var reply string
+	switch cmd {
+	case "VERSION":
+		reply = i.CTCPVersion
+		if "" == reply {
+			reply = defaultCTCPVersion
+		}
+	case "PING":
+		reply = args
+	case "TIME":
+		reply = time.Now().Format(time.RFC1123Z)
+	case "CLIENTINFO":
+		reply = i.CTCPClientInfo
+		if "" == reply {
+			reply = defaultCTCPClientInfo
+		}
+	default:
+		return
+	}
+	if err := i.CTCPReply(m.Nick, cmd, reply); nil != err {
+		i.event(ErrorEvent{Err: err})
+	}
+}
+
+// ctcpFlooding notes a CTCP from host and returns true if host has sent more
+// than i.CTCPFloodCount in the last i.CTCPFloodWindow, or all hosts together
+// have sent more than i.CTCPFloodTotal, which stops floods from many hosts.
+// Either limit is disabled if negative.
+func (i *IRC) ctcpFlooding(host string) bool {
+	max, total := i.CTCPFloodCount, i.CTCPFloodTotal
+	window := i.CTCPFloodWindow
+	if 0 == max {
+		max = defaultCTCPFloodCount
+	}
+	if 0 == total {
+		total = defaultCTCPFloodTotal
+	}
+	if 0 >= window {
+		window = defaultCTCPFloodWindow
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.ctcpSeen {
+		i.ctcpSeen = make(map[string][]time.Time)
+	}
+	/* Forget old CTCPs, counting the rest */
+	now := time.Now()
+	n := 1
+	for h, ts := range i.ctcpSeen {
+		for 0 != len(ts) && now.Sub(ts[0]) > window {
+			ts = ts[1:]
+		}
+		if 0 == len(ts) {
+			delete(i.ctcpSeen, h)
+			continue
+		}
+		i.ctcpSeen[h] = ts
+		n += len(ts)
+	}
+	i.ctcpSeen[host] = append(i.ctcpSeen[host], now)
+	return (0 < max && len(i.ctcpSeen[host]) > max) ||
+		(0 < total && n > total)
+}
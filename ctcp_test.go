@@ -0,0 +1,46 @@
+package minimalirc
+
+import (
+	"fmt"
+	"testing"
+)
+
+/*
+ * ctcp_test.go
+ * Test answering CTCPs
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* ctcpReplies returns the number of replies sent to n VERSIONs from hosts */
+func ctcpReplies(t *testing.T, n int, host func(int) string) int {
+	i := New("irc.example.com", 6697, true, "", "nick", "u", "r")
+	i.CTCPReplies = true
+	sent := &sentLines{}
+	i.use(sent)
+	i.Feed(":irc.example.com 001 nick :Welcome")
+	sent.take()
+	for j := 0; j < n; j++ {
+		i.Feed(fmt.Sprintf(":n%v!u@%v PRIVMSG nick :\x01VERSION\x01",
+			j, host(j)))
+	}
+	return len(sent.take())
+}
+
+func TestCTCPFloodPerHost(t *testing.T) {
+	got := ctcpReplies(t, 5, func(int) string { return "h" })
+	if defaultCTCPFloodCount != got {
+		t.Errorf("%v replies, not %v", got, defaultCTCPFloodCount)
+	}
+}
+
+func TestCTCPFloodTotal(t *testing.T) {
+	got := ctcpReplies(t, 20, func(j int) string {
+		return fmt.Sprintf("h%v", j)
+	})
+	if defaultCTCPFloodTotal != got {
+		t.Errorf("%v replies, not %v", got, defaultCTCPFloodTotal)
+	}
+}
@@ -0,0 +1,120 @@
+package minimalirc
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * dial.go
+ * Connect to the server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* defaultDialTimeout is how long dial tries if i.DialTimeout is 0 */
+const defaultDialTimeout = time.Minute
+
+// dialServers connects to the first server in i.servers which will have us,
+// and returns an error listing why each failed if none will
+func (i *IRC) dialServers() (net.Conn, error) {
+	var errs []string
+	for _, s := range i.servers() {
+		i.audit(AuditDial, "%v (TLS: %v)", s, i.Ssl)
+		c, err := i.dial(s)
+		if nil == err {
+			return c, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if 1 == len(errs) {
+		return nil, errors.New(errs[0])
+	}
+	return nil, errors.New(fmt.Sprintf("unable to connect to any "+
+		"server: %v", strings.Join(errs, "; ")))
+}
+
+// servers returns the addresses of the servers to try, in order: those
+// named by SRV records for i.Host if i.LookupSRV is set, then i.Host and
+// i.Port
+func (i *IRC) servers() []string {
+	fallback := net.JoinHostPort(i.Host, fmt.Sprintf("%v", i.Port))
+	if !i.LookupSRV {
+		return []string{fallback}
+	}
+	service := "irc"
+	if i.Ssl {
+		service = "ircs"
+	}
+	/* LookupSRV sorts by priority and shuffles by weight */
+	_, srvs, err := net.LookupSRV(service, "tcp", i.Host)
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf("unable to "+
+			"look up SRV records for %v: %v", i.Host, err))})
+		return []string{fallback}
+	}
+	var ss []string
+	for _, s := range srvs {
+		/* A target of . means there's no such service */
+		t := strings.TrimSuffix(s.Target, ".")
+		if "" == t {
+			continue
+		}
+		ss = append(ss, net.JoinHostPort(t,
+			strconv.Itoa(int(s.Port))))
+	}
+	return append(ss, fallback)
+}
+
+// dial connects to the server at addr, from i.LocalAddr if it's set, using TLS
+// if i.Ssl is set, with i.ClientCert if it's set.  Every address to which addr
+// resolves is tried.  IPv6 and IPv4 addresses are raced Happy Eyeballs-style
+// (RFC 6555), with i.FallbackDelay's head start for the first family, and the
+// time allowed for each family's addresses is shared between them, so one
+// broken route can't use it all.
+func (i *IRC) dial(addr string) (net.Conn, error) {
+	network := i.Network
+	if "" == network {
+		network = "tcp"
+	}
+	timeout := i.DialTimeout
+	if 0 == timeout {
+		timeout = defaultDialTimeout
+	}
+	d := &net.Dialer{Timeout: timeout, FallbackDelay: i.FallbackDelay}
+	if "" != i.LocalAddr {
+		la, err := net.ResolveTCPAddr(network,
+			net.JoinHostPort(i.LocalAddr, "0"))
+		if nil != err {
+			return nil, errors.New(fmt.Sprintf("bad local address "+
+				"%v: %v", i.LocalAddr, err))
+		}
+		d.LocalAddr = la
+	}
+	if i.Ssl { /* SSL requested */
+		conf := &tls.Config{ServerName: i.Hostname}
+		if nil != i.ClientCert {
+			conf.Certificates = []tls.Certificate{*i.ClientCert}
+		}
+		c, err := tls.DialWithDialer(d, network, addr, conf)
+		if nil != err {
+			return nil, errors.New(fmt.Sprintf("unable to make ssl "+
+				"connection to %v: %v", addr, err))
+		}
+		return c, nil
+	}
+	/* Plaintext connection */
+	c, err := d.Dial(network, addr)
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf("unable to make plaintext "+
+			"connection to %v: %v", addr, err))
+	}
+	return c, nil
+}
@@ -0,0 +1,132 @@
+package minimalirc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+ * envelope.go
+ * Encrypted PRIVMSGs
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* envelopePrefix marks an encrypted PRIVMSG */
+const envelopePrefix = "~enc "
+
+// EnvelopeEvent is sent when an encrypted PRIVMSG is received and decrypted with i.EnvelopeKey.
+type EnvelopeEvent struct {
+	From   string /* nick!user@host of the sender */
+	Target string /* Channel or our nick */
+	Text   string /* Decrypted message */
+}
+
+func (EnvelopeEvent) event() {}
+
+// PrivmsgEncrypted encrypts msg with AES-GCM using i.EnvelopeKey, which must be 16, 24, or 32 bytes long, and sends it base64-encoded in one or more PRIVMSGs to target (see Privmsg for the meaning of target).  The message is split into as many PRIVMSGs as needed to fit in PrivmsgSize, each of which can be decrypted on its own.
+func (i *IRC) PrivmsgEncrypted(msg, target string) error {
+	aead, err := i.envelopeAEAD()
+	if nil != err {
+		return err
+	}
+	t := i.target(target)
+	if "" == t {
+		return nil
+	}
+	/* Work out how much plaintext fits in a message */
+	max := (i.PrivmsgSize(t)-len(envelopePrefix))/4*3 -
+		aead.NonceSize() - aead.Overhead()
+	if 0 >= max {
+		return errors.New("no room for an encrypted message")
+	}
+	for _, p := range splitRunes(msg, max) {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); nil != err {
+			return err
+		}
+		c := aead.Seal(nonce, nonce, []byte(p), nil)
+		if err := i.Privmsg(envelopePrefix+
+			base64.StdEncoding.EncodeToString(c), t); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecryptEnvelope decrypts the body of a PRIVMSG sent with PrivmsgEncrypted.  This is done automatically for received PRIVMSGs if i.EnvelopeKey is set, and the result sent as an EnvelopeEvent.
+func (i *IRC) DecryptEnvelope(body string) (string, error) {
+	if !strings.HasPrefix(body, envelopePrefix) {
+		return "", errors.New("not an encrypted message")
+	}
+	aead, err := i.envelopeAEAD()
+	if nil != err {
+		return "", err
+	}
+	c, err := base64.StdEncoding.DecodeString(body[len(envelopePrefix):])
+	if nil != err {
+		return "", err
+	}
+	if len(c) < aead.NonceSize() {
+		return "", errors.New("encrypted message too short")
+	}
+	p, err := aead.Open(nil, c[:aead.NonceSize()], c[aead.NonceSize():],
+		nil)
+	if nil != err {
+		return "", err
+	}
+	return string(p), nil
+}
+
+/* envelopeAEAD makes an AEAD from i.EnvelopeKey */
+func (i *IRC) envelopeAEAD() (cipher.AEAD, error) {
+	if 0 == len(i.EnvelopeKey) {
+		return nil, errors.New("no envelope key")
+	}
+	b, err := aes.NewCipher(i.EnvelopeKey)
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf("bad envelope key: %v", err))
+	}
+	return cipher.NewGCM(b)
+}
+
+/* handleEnvelope decrypts encrypted PRIVMSGs, if we have a key */
+func (i *IRC) handleEnvelope(m Message) {
+	if "PRIVMSG" != m.Command || 0 == len(i.EnvelopeKey) ||
+		!strings.HasPrefix(m.Trailing(), envelopePrefix) {
+		return
+	}
+	p, err := i.DecryptEnvelope(m.Trailing())
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"unable to decrypt message from %v: %v", m.Prefix, err))})
+		return
+	}
+	i.event(EnvelopeEvent{From: m.Prefix, Target: m.Param(0), Text: p})
+}
+
+// splitRunes splits s into pieces of at most n bytes, without splitting
+// runes
+func splitRunes(s string, n int) []string {
+	var ps []string
+	for len(s) > n {
+		c := n
+		for 0 < c && !utf8.RuneStart(s[c]) {
+			c--
+		}
+		if 0 == c {
+			c = n
+		}
+		ps = append(ps, s[:c])
+		s = s[c:]
+	}
+	return append(ps, s)
+}
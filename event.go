@@ -0,0 +1,70 @@
+package minimalirc
+
+/*
+ * event.go
+ * Notifications generated from server traffic
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Event is implemented by the notifications the library derives from server traffic.  Events are passed to i.OnEvent, if it's set, one at a time and in the order they're sent.  Use a type switch to tell them apart.
+type Event interface {
+	event()
+}
+
+// SendEvent passes e to i.OnEvent as the library does with its own events, so OnEvent is still only called with one event at a time.  It's meant for packages which add to an IRC struct, like gateway.
+func (i *IRC) SendEvent(e Event) {
+	i.event(e)
+}
+
+// event passes e to i.OnEvent, if it's set.  Errors in ErrorEvents are
+// wrapped with i.Name.  Events can come from timers and handler workers as
+// well as the goroutine reading from the server, so they're queued, and
+// whichever goroutine finds nobody delivering them delivers them, in order,
+// until the queue's empty.  Events sent by OnEvent itself are delivered after
+// it returns.
+func (i *IRC) event(e Event) {
+	if nil == i.OnEvent {
+		return
+	}
+	if ee, ok := e.(ErrorEvent); ok {
+		ee.Err = i.named(ee.Err)
+		e = ee
+	}
+	i.emu.Lock()
+	i.evq = append(i.evq, e)
+	if i.evbusy {
+		i.emu.Unlock()
+		return
+	}
+	i.evbusy = true
+	/* Let someone else deliver if OnEvent panics */
+	defer func() {
+		if r := recover(); nil != r {
+			i.emu.Lock()
+			i.evbusy = false
+			i.emu.Unlock()
+			panic(r)
+		}
+	}()
+	for 0 != len(i.evq) {
+		e := i.evq[0]
+		i.evq = i.evq[1:]
+		i.emu.Unlock()
+		if f := i.OnEvent; nil != f {
+			f(e)
+		}
+		i.emu.Lock()
+	}
+	i.evbusy = false
+	i.emu.Unlock()
+}
+
+// ErrorEvent is sent when something the library does on its own, like responding to a message from the server, fails.  Errors which end the connection are sent on i.E instead.
+type ErrorEvent struct {
+	Err error
+}
+
+func (ErrorEvent) event() {}
@@ -0,0 +1,74 @@
+package minimalirc
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+/*
+ * event_test.go
+ * Test event delivery
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+func TestEventsOneAtATime(t *testing.T) {
+	i := New("irc.example.com", 6697, true, "", "nick", "u", "r")
+	var (
+		in   int32
+		got  int32
+		wg   sync.WaitGroup
+		fail int32
+	)
+	i.OnEvent = func(e Event) {
+		if 1 != atomic.AddInt32(&in, 1) {
+			atomic.StoreInt32(&fail, 1)
+		}
+		atomic.AddInt32(&got, 1)
+		atomic.AddInt32(&in, -1)
+	}
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 1000; n++ {
+				i.event(ErrorEvent{Err: errors.New("test")})
+			}
+		}()
+	}
+	wg.Wait()
+	if 0 != fail {
+		t.Errorf("OnEvent called concurrently")
+	}
+	if 8000 != got {
+		t.Errorf("got %v events, not 8000", got)
+	}
+}
+
+func TestEventsFromOnEvent(t *testing.T) {
+	i := New("irc.example.com", 6697, true, "", "nick", "u", "r")
+	var got []string
+	i.OnEvent = func(e Event) {
+		c := e.(CapEvent).Command
+		got = append(got, c+" start")
+		if "first" == c {
+			i.event(CapEvent{Command: "second"})
+		}
+		got = append(got, c+" end")
+	}
+	i.event(CapEvent{Command: "first"})
+	want := []string{"first start", "first end", "second start",
+		"second end"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, not %q", got, want)
+	}
+	for n := range got {
+		if got[n] != want[n] {
+			t.Fatalf("got %q, not %q", got, want)
+		}
+	}
+}
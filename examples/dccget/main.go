@@ -0,0 +1,152 @@
+// Dccget is an example program which accepts files sent with DCC SEND from allowed users.
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * main.go
+ * Example DCC SEND receiver
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../../minimalirc.go for license details.
+ */
+
+func main() {
+	var (
+		host  = flag.String("host", "irc.libera.chat", "IRC server")
+		port  = flag.Uint("port", 6697, "IRC server `port`")
+		ssl   = flag.Bool("ssl", true, "Use TLS")
+		nick  = flag.String("nick", "dccget", "IRC `nick`")
+		allow = flag.String("allow", "", "Accept files from `mask`")
+		dir   = flag.String("dir", ".", "Save files in `directory`")
+		max   = flag.Int64("max", 100<<20, "Maximum file `size`")
+	)
+	flag.Parse()
+	if "" == *allow {
+		log.Fatalf("Need a mask (-allow) from which to accept files")
+	}
+
+	i := minimalirc.New(*host, uint16(*port), *ssl, "", *nick, *nick,
+		"minimalirc DCC receiver")
+	i.Pongs = true
+	i.OnEvent = func(e minimalirc.Event) {
+		c, ok := e.(minimalirc.CTCPEvent)
+		if !ok || "DCC" != c.Command || c.Dropped {
+			return
+		}
+		if !minimalirc.MatchMask(*allow, c.From) {
+			log.Printf("Ignoring DCC from %v", c.From)
+			return
+		}
+		go func() {
+			n, err := receive(c.Args, *dir, *max)
+			if nil != err {
+				log.Printf("Error receiving from %v: %v", c.From, err)
+				return
+			}
+			log.Printf("Received %v from %v", n, c.From)
+		}()
+	}
+
+	for {
+		if err := i.Connect(); nil != err {
+			log.Printf("Unable to connect: %v", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		for range i.C {
+		}
+		log.Printf("Disconnected: %v", <-i.E)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// receive handles a DCC SEND offer, the arguments of which are in args.  It
+// returns the name of the file written.
+func receive(args, dir string, max int64) (string, error) {
+	/* SEND filename address port size */
+	f := strings.Fields(args)
+	if 5 > len(f) || "SEND" != strings.ToUpper(f[0]) {
+		return "", errors.New(fmt.Sprintf("unsupported DCC %q", args))
+	}
+	name := filepath.Base(strings.Trim(strings.Join(f[1:len(f)-3], " "),
+		`"`))
+	addr, port, ssize := f[len(f)-3], f[len(f)-2], f[len(f)-1]
+	size, err := strconv.ParseInt(ssize, 10, 64)
+	if nil != err || 0 > size {
+		return "", errors.New(fmt.Sprintf("bad size %q", ssize))
+	}
+	if size > max {
+		return "", errors.New(fmt.Sprintf("%v is too big (%v bytes)",
+			name, size))
+	}
+	/* Addresses are usually IPv4 addresses as a number */
+	if n, err := strconv.ParseUint(addr, 10, 32); nil == err {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(n))
+		addr = ip.String()
+	}
+	if "0" == port {
+		return "", errors.New("passive DCC not supported")
+	}
+
+	/* Get the file */
+	c, err := net.DialTimeout("tcp", net.JoinHostPort(addr, port),
+		time.Minute)
+	if nil != err {
+		return "", err
+	}
+	defer c.Close()
+	out, err := os.OpenFile(filepath.Join(dir, name),
+		os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if nil != err {
+		return "", err
+	}
+	defer out.Close()
+	var (
+		buf  = make([]byte, 32*1024)
+		got  int64
+		ack  [4]byte
+		rerr error
+	)
+	for got < size && nil == rerr {
+		c.SetReadDeadline(time.Now().Add(time.Minute))
+		var n int
+		n, rerr = c.Read(buf)
+		if int64(n) > size-got {
+			n = int(size - got)
+		}
+		if _, err := out.Write(buf[:n]); nil != err {
+			return "", err
+		}
+		got += int64(n)
+		/* Senders wait for us to say how much we have */
+		binary.BigEndian.PutUint32(ack[:], uint32(got))
+		if _, err := c.Write(ack[:]); nil != err {
+			return "", err
+		}
+	}
+	if got < size {
+		if io.EOF == rerr {
+			rerr = io.ErrUnexpectedEOF
+		}
+		return "", rerr
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,76 @@
+// Echobot is an example bot which repeats what it's told with !echo, and reconnects when it loses its connection.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * main.go
+ * Example bot which echos things back
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../../minimalirc.go for license details.
+ */
+
+func main() {
+	var (
+		host    = flag.String("host", "irc.libera.chat", "IRC server")
+		port    = flag.Uint("port", 6697, "IRC server `port`")
+		ssl     = flag.Bool("ssl", true, "Use TLS")
+		nick    = flag.String("nick", "echobot", "IRC `nick`")
+		channel = flag.String("channel", "#minimalirc", "IRC `channel`")
+	)
+	flag.Parse()
+
+	i := minimalirc.New(*host, uint16(*port), *ssl, "", *nick, *nick,
+		"minimalirc echo bot")
+	i.Channel = *channel
+	i.Pongs = true
+	i.RandomNumbers = true
+	i.CTCPReplies = true
+
+	/* !echo says it back */
+	i.HandleCommand("echo", func(c *minimalirc.Command) {
+		if err := i.Privmsg(c.Args, replyTo(c)); nil != err {
+			log.Printf("Error echoing: %v", err)
+		}
+	})
+
+	/* Log what happens */
+	i.OnEvent = func(e minimalirc.Event) {
+		switch e := e.(type) {
+		case minimalirc.StateEvent:
+			log.Printf("%v -> %v", e.Old, e.New)
+		case minimalirc.ErrorEvent:
+			log.Printf("Error: %v", e.Err)
+		}
+	}
+
+	/* Connect, and reconnect */
+	for {
+		if err := i.Connect(); nil != err {
+			log.Printf("Unable to connect: %v", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		/* Handlers do the work, just keep the lines flowing */
+		for range i.C {
+		}
+		log.Printf("Disconnected: %v", <-i.E)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+/* replyTo works out where to send a reply to c */
+func replyTo(c *minimalirc.Command) string {
+	if t := c.Message.Param(0); "" != t && t != c.IRC.SNick() {
+		return t
+	}
+	return c.Message.Nick
+}
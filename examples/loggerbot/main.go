@@ -0,0 +1,73 @@
+// Loggerbot is an example bot which logs channels to per-day files using package irclog.
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+	"github.com/kd5pbo/minimalirc/irclog"
+)
+
+/*
+ * main.go
+ * Example bot which logs channels
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../../minimalirc.go for license details.
+ */
+
+func main() {
+	var (
+		host     = flag.String("host", "irc.libera.chat", "IRC server")
+		port     = flag.Uint("port", 6697, "IRC server `port`")
+		ssl      = flag.Bool("ssl", true, "Use TLS")
+		nick     = flag.String("nick", "loggerbot", "IRC `nick`")
+		channels = flag.String("channels", "#minimalirc",
+			"Comma-separated `list` of channels to log")
+		dir = flag.String("dir", "logs", "Log `directory`")
+	)
+	flag.Parse()
+
+	i := minimalirc.New(*host, uint16(*port), *ssl, "", *nick, *nick,
+		"minimalirc logger bot")
+	i.Pongs = true
+	i.RandomNumbers = true
+
+	/* Log everything said */
+	l := irclog.New(*dir)
+	l.Attach(i)
+	defer l.Close()
+
+	/* Join the channels once we're in */
+	i.OnEvent = func(e minimalirc.Event) {
+		switch e := e.(type) {
+		case minimalirc.StateEvent:
+			if minimalirc.Registered != e.New {
+				return
+			}
+			if err := i.JoinMany(strings.Split(*channels, ","),
+				nil); nil != err {
+				log.Printf("Error joining channels: %v", err)
+			}
+		case minimalirc.ErrorEvent:
+			log.Printf("Error: %v", e.Err)
+		}
+	}
+
+	/* Connect, and reconnect */
+	for {
+		if err := i.Connect(); nil != err {
+			log.Printf("Unable to connect: %v", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		for range i.C {
+		}
+		log.Printf("Disconnected: %v", <-i.E)
+		time.Sleep(10 * time.Second)
+	}
+}
@@ -0,0 +1,90 @@
+// Relay is an example program which relays messages between channels on two networks.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * main.go
+ * Example relay between two networks
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../../minimalirc.go for license details.
+ */
+
+func main() {
+	var (
+		nick  = flag.String("nick", "relay", "IRC `nick` on both networks")
+		aNet  = flag.String("a", "libera", "First network's `profile`")
+		aChan = flag.String("a-channel", "#minimalirc",
+			"First network's `channel`")
+		bNet  = flag.String("b", "oftc", "Second network's `profile`")
+		bChan = flag.String("b-channel", "#minimalirc",
+			"Second network's `channel`")
+	)
+	flag.Parse()
+
+	a := connect(*aNet, *nick, *aChan)
+	b := connect(*bNet, *nick, *bChan)
+	relay(a, b, *aNet, *bChan)
+	relay(b, a, *bNet, *aChan)
+	select {}
+}
+
+/* connect makes a connection to a network, and keeps it connected */
+func connect(network, nick, channel string) *minimalirc.IRC {
+	i, err := minimalirc.NewProfile(network, nick, nick,
+		"minimalirc relay")
+	if nil != err {
+		log.Fatalf("Error setting up %v: %v", network, err)
+	}
+	i.Channel = channel
+	i.RandomNumbers = true
+	go func() {
+		for {
+			if err := i.Connect(); nil != err {
+				log.Printf("Unable to connect to %v: %v",
+					network, err)
+				time.Sleep(time.Minute)
+				continue
+			}
+			for range i.C {
+			}
+			log.Printf("Disconnected from %v: %v", network, <-i.E)
+			time.Sleep(10 * time.Second)
+		}
+	}()
+	return i
+}
+
+// relay sends what's said in from's channel to the channel on to.  name is
+// from's network's name.
+func relay(from, to *minimalirc.IRC, name, channel string) {
+	from.Bridge(func(e minimalirc.BridgeEvent) {
+		if e.Private || e.Target != from.Channel {
+			return
+		}
+		var text string
+		switch e.Kind {
+		case minimalirc.BridgeMessage:
+			text = fmt.Sprintf("<%v@%v> %v", e.Sender, name, e.Body)
+		case minimalirc.BridgeAction:
+			text = fmt.Sprintf("* %v@%v %v", e.Sender, name, e.Body)
+		default:
+			return
+		}
+		if minimalirc.Registered != to.State() {
+			return
+		}
+		if err := to.Privmsg(text, channel); nil != err {
+			log.Printf("Error relaying to %v: %v", channel, err)
+		}
+	})
+}
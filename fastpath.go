@@ -0,0 +1,80 @@
+package minimalirc
+
+import (
+	"strings"
+)
+
+/*
+ * fastpath.go
+ * Skip work for lines nobody cares about
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// uninteresting returns true if line is a plain PRIVMSG or NOTICE which
+// would be sent on i.C without anything in the library looking at it, so
+// needn't be parsed.  On busy channels, this is most lines.
+func (i *IRC) uninteresting(line string) bool {
+	/* Tags (e.g. msgid), CTCPs, and envelopes need looking at */
+	if i.Strict || i.HighlightNick || i.TrackActivity ||
+		0 != len(i.EnvelopeKey) ||
+		strings.HasPrefix(line, "@") ||
+		-1 != strings.IndexByte(line, '\x01') {
+		return false
+	}
+	c := peekCommand(line)
+	if !strings.EqualFold(c, "PRIVMSG") && !strings.EqualFold(c, "NOTICE") {
+		return false
+	}
+	/* Services' notices tell us how identifying went */
+	if strings.EqualFold(c, "NOTICE") &&
+		fold(peekNick(line)) == fold(i.services().Bot()) {
+		return false
+	}
+	/* Anything which might want to see it */
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return 0 == len(i.handlers) &&
+		0 == len(i.commands) &&
+		0 == len(i.subcmds) &&
+		0 == len(i.waiters) &&
+		0 == len(i.intercepts) &&
+		0 == len(i.ignMasks) &&
+		0 == len(i.ignREs) &&
+		0 == len(i.highlights) &&
+		0 == len(i.responses) &&
+		0 == len(i.idles) &&
+		0 == len(i.routes) &&
+		0 == len(i.queries) &&
+		0 == len(i.unechoed) &&
+		0 == len(i.offline)
+}
+
+/* peekNick returns the nick in line's prefix, without parsing the rest */
+func peekNick(line string) string {
+	if !strings.HasPrefix(line, ":") {
+		return ""
+	}
+	if n := strings.IndexAny(line, "! "); -1 != n {
+		return line[1:n]
+	}
+	return line[1:]
+}
+
+// peekCommand returns the command in line, which mustn't have tags, without
+// parsing the rest of it
+func peekCommand(line string) string {
+	if strings.HasPrefix(line, ":") {
+		n := strings.IndexByte(line, ' ')
+		if -1 == n {
+			return ""
+		}
+		line = strings.TrimLeft(line[n+1:], " ")
+	}
+	if n := strings.IndexByte(line, ' '); -1 != n {
+		return line[:n]
+	}
+	return line
+}
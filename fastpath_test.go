@@ -0,0 +1,47 @@
+package minimalirc
+
+import (
+	"testing"
+)
+
+/*
+ * fastpath_test.go
+ * Benchmark handling lines with and without the fast path
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+func BenchmarkHandleLine(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		line string
+		fast bool /* Whether the line should take the fast path */
+	}{{
+		/* Plain chatter, which needn't be parsed */
+		"FastPath",
+		":nick!user@host PRIVMSG #channel :hello, world",
+		true,
+	}, {
+		/* Tags mean the line has to be parsed and looked at */
+		"FullParse",
+		"@msgid=abc :nick!user@host PRIVMSG #channel :hello, world",
+		false,
+	}} {
+		b.Run(c.name, func(b *testing.B) {
+			i := New("irc.example.com", 6697, true, "", "me",
+				"user", "Real")
+			i.Feed(":irc.example.com 001 me :Welcome")
+			i.Feed(":me!user@host JOIN #channel")
+			if c.fast != i.uninteresting(c.line) {
+				b.Fatalf("fast path not %v for %q", c.fast, c.line)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				i.handleLine(c.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package minimalirc
+
+import (
+	"bufio"
+	"io"
+)
+
+/*
+ * feed.go
+ * Process lines without a server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Feed processes line as if it had been received from the server, without needing a connection.  State is tracked, events are sent, and handlers and commands are called as usual, which makes it possible to analyze logs or lines extracted from packet captures with the same code as a live bot.  It returns the line as it would have been sent on i.C (an Interceptor may change it), and false if it would have been dropped (e.g. it was ignored).  Nothing is sent on i.C.  If i isn't connected, anything the library or a handler sends is discarded; set i.PassiveMode to stop the library trying.  Feed shouldn't be called concurrently with itself or on a live connection.
+func (i *IRC) Feed(line string) (string, bool) {
+	if nil == i.rw {
+		i.use(feedConn{})
+	}
+	i.countLine(false)
+	/* A discarding connection can't fail */
+	line, ok, _ := i.handleLine(line)
+	return line, ok
+}
+
+// FeedFrom calls Feed with each line read from r, which should be raw IRC lines as received from the server, until r is exhausted.  Lines may end in CRLF or just LF.  Any error reading r other than io.EOF is returned.
+func (i *IRC) FeedFrom(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		l := s.Text()
+		if 0 != len(l) && '\r' == l[len(l)-1] {
+			l = l[:len(l)-1]
+		}
+		if "" == l {
+			continue
+		}
+		i.Feed(l)
+	}
+	return s.Err()
+}
+
+/* feedConn is the connection used by Feed, which never says anything */
+type feedConn struct{}
+
+/* Read returns io.EOF */
+func (feedConn) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+/* Write discards p */
+func (feedConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,54 @@
+//go:build go1.18
+// +build go1.18
+
+package minimalirc
+
+import (
+	"testing"
+)
+
+/*
+ * feed_test.go
+ * Fuzz the handling of lines from the server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+func FuzzHandleLine(f *testing.F) {
+	for _, l := range fuzzLines {
+		f.Add(l, false)
+		f.Add(l, true)
+	}
+	/* Lines which poke at the state tracking */
+	for _, l := range []string{
+		":irc.example.com 353 nick = #channel :@nick +other ~third",
+		":irc.example.com 354 nick 694 #channel user host nick account",
+		":irc.example.com CAP nick LS :sasl account-tag extended-join",
+		":irc.example.com CAP nick ACK :account-tag",
+		":nick!user@host NICK :other",
+		":other!user@host KICK #channel nick :bye",
+		":other!user@host INVITE nick :#channel",
+		":other!user@host ACCOUNT account",
+		":NickServ!s@services NOTICE nick :You are now identified",
+		"AUTHENTICATE +",
+		":irc.example.com 433 * nick :Nickname is already in use",
+	} {
+		f.Add(l, false)
+	}
+	i := New("irc.example.com", 6697, true, "", "nick", "user", "Real")
+	i.Pongs = true
+	i.TrackActivity = true
+	i.HighlightNick = true
+	go func() {
+		for range i.C {
+		}
+	}()
+	i.Feed(":irc.example.com 001 nick :Welcome")
+	i.Feed(":nick!user@host JOIN #channel")
+	f.Fuzz(func(t *testing.T, line string, strict bool) {
+		i.Strict = strict
+		i.Feed(line)
+	})
+}
@@ -0,0 +1,95 @@
+package minimalirc
+
+/*
+ * fingerprint.go
+ * Control what identifies the client software
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* defaultCTCPClientInfo is the default CTCP CLIENTINFO reply */
+const defaultCTCPClientInfo = "ACTION CLIENTINFO PING TIME VERSION"
+
+// Fingerprint holds the values sent at registration and in CTCP replies which can be used to tell which client software is in use.  Empty fields are left as they are when a Fingerprint is applied.
+type Fingerprint struct {
+	Username   string /* For USER */
+	Realname   string /* For USER */
+	UserMode   string /* For USER, RFC2812 mode bits */
+	UserUnused string /* For USER, unused parameter */
+	Version    string /* CTCP VERSION reply */
+	ClientInfo string /* CTCP CLIENTINFO reply */
+}
+
+// CommonFingerprints resemble the registrations and CTCP replies of some common clients.  Usernames and realnames are left to the caller.  It may be passed to RandomFingerprint or put in i.Fingerprints.
+var CommonFingerprints = []Fingerprint{{
+	UserMode:   "0",
+	UserUnused: "*",
+	Version:    "irssi v1.4.5 - running on Linux x86_64",
+	ClientInfo: "ACTION CLIENTINFO DCC PING TIME USERINFO VERSION",
+}, {
+	UserMode:   "0",
+	UserUnused: "*",
+	Version:    "WeeChat 4.1.1",
+	ClientInfo: "ACTION CLIENTINFO DCC PING SOURCE TIME USERINFO " +
+		"VERSION",
+}, {
+	UserMode:   "0",
+	UserUnused: "*",
+	Version:    "HexChat 2.16.1 [x64] / Windows 10 [3.60GHz]",
+	ClientInfo: "ACTION CLIENTINFO DCC PING SOURCE TIME USERINFO " +
+		"VERSION",
+}}
+
+// Fingerprint returns the values i will send at registration and in CTCP replies.
+func (i *IRC) Fingerprint() Fingerprint {
+	f := Fingerprint{
+		Username:   i.Username,
+		Realname:   i.Realname,
+		UserMode:   i.UserMode,
+		UserUnused: i.UserUnused,
+		Version:    i.CTCPVersion,
+		ClientInfo: i.CTCPClientInfo,
+	}
+	if "" == f.UserMode {
+		f.UserMode = "x"
+	}
+	if "" == f.UserUnused {
+		f.UserUnused = "x"
+	}
+	if "" == f.Version {
+		f.Version = defaultCTCPVersion
+	}
+	if "" == f.ClientInfo {
+		f.ClientInfo = defaultCTCPClientInfo
+	}
+	return f
+}
+
+// SetFingerprint sets i's fields from the non-empty fields in f.  The USER values take effect the next time ID is called, and the CTCP replies immediately.
+func (i *IRC) SetFingerprint(f Fingerprint) {
+	set := func(d *string, s string) {
+		if "" != s {
+			*d = s
+		}
+	}
+	set(&i.Username, f.Username)
+	set(&i.Realname, f.Realname)
+	set(&i.UserMode, f.UserMode)
+	set(&i.UserUnused, f.UserUnused)
+	set(&i.CTCPVersion, f.Version)
+	set(&i.CTCPClientInfo, f.ClientInfo)
+}
+
+// RandomFingerprint chooses one of fs at random, passes it to SetFingerprint, and returns it.  If fs is empty, nothing is changed and the zero Fingerprint is returned.  If i.Fingerprints isn't empty, ID calls RandomFingerprint with it before registering.
+func (i *IRC) RandomFingerprint(fs []Fingerprint) Fingerprint {
+	if 0 == len(fs) {
+		return Fingerprint{}
+	}
+	i.rngmu.Lock()
+	f := fs[i.rng.Intn(len(fs))]
+	i.rngmu.Unlock()
+	i.SetFingerprint(f)
+	return f
+}
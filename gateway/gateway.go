@@ -0,0 +1,434 @@
+// Package gateway lets a local IRC client use an established minimalirc connection, making a minimal single-user bouncer.
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * gateway.go
+ * Proxy a local client through an existing connection
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../minimalirc.go for license details.
+ */
+
+// clientBuffer is the number of lines which may be queued for a client
+// before it's considered too slow and disconnected
+const clientBuffer = 1024
+
+/* defaultHistory is the default size of the playback buffer */
+const defaultHistory = 500
+
+// Gateway accepts connections from IRC clients and proxies them through an IRC struct's connection.  Registration toward the client is handled by the Gateway, which then tells the client which channels it's in and plays back recent messages.  Lines from the client other than PASS, USER, CAP, PING, AWAY, and QUIT are sent to the server as-is, and lines from the server (other than PINGs) are sent to the client.  Any number of clients may be attached at once; messages sent by one are shown to the others.
+//
+// Each client may mark itself AWAY.  We're marked away upstream only when every attached client is away, with the most recent client's message, or with DetachedAway when no clients are attached.
+type Gateway struct {
+	IRC          *minimalirc.IRC /* Upstream connection */
+	Pass         string          /* Password clients must send with PASS */
+	History      int             /* Lines to play back, 500 if 0 */
+	DetachedAway string          /* Away message with no clients attached */
+
+	mu      sync.Mutex
+	clients map[*client]bool
+	history []remembered /* Ring buffer of recent messages */
+	hnext   int          /* Next slot in history */
+	away    string       /* Upstream away message, "" if not away */
+
+	amu  sync.Mutex /* Serializes sending AWAY */
+	sent string     /* Away message last sent upstream */
+}
+
+/* client is an attached local client */
+type client struct {
+	conn net.Conn
+	out  chan string   /* Lines to send to the client */
+	done chan struct{} /* Closed when the client's gone */
+	once sync.Once
+	away string /* Client's away message, "" if not away */
+}
+
+// New returns a Gateway for i, and registers a handler on i to pass lines to attached clients.
+func New(i *minimalirc.IRC) *Gateway {
+	g := &Gateway{IRC: i, clients: make(map[*client]bool)}
+	i.Handle(g.handle)
+	return g
+}
+
+// ListenAndServe listens on addr and calls Serve.
+func (g *Gateway) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if nil != err {
+		return err
+	}
+	defer l.Close()
+	return g.Serve(l)
+}
+
+// Serve accepts clients from l until it returns an error.  If g.Pass is empty, Serve refuses to start unless l only accepts local connections, i.e. it's listening on a loopback address or a Unix socket.
+func (g *Gateway) Serve(l net.Listener) error {
+	if "" == g.Pass && !isLocal(l.Addr()) {
+		return errors.New(fmt.Sprintf("refusing to serve on %v without "+
+			"a password", l.Addr()))
+	}
+	for {
+		c, err := l.Accept()
+		if nil != err {
+			return err
+		}
+		go g.serveClient(c)
+	}
+}
+
+/* isLocal returns true if only this host can connect to a */
+func isLocal(a net.Addr) bool {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		return a.IP.IsLoopback()
+	case *net.UnixAddr:
+		return true
+	}
+	return false
+}
+
+// handle is a minimalirc.Handler which passes lines from the server to the
+// attached client
+func (g *Gateway) handle(_ context.Context, m minimalirc.Message) {
+	/* The library handles pings */
+	if "PING" == m.Command {
+		return
+	}
+	g.broadcast(m, nil)
+}
+
+// broadcast sends m to every attached client but except, and saves it for
+// playback if it's a message.  Clients don't negotiate capabilities with the
+// gateway, so tags are removed.
+func (g *Gateway) broadcast(m minimalirc.Message, except *client) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch m.Command {
+	case "PRIVMSG", "NOTICE", "TOPIC", "KICK":
+		g.remember(m)
+	}
+	m.Tags = nil
+	line := m.String()
+	for c := range g.clients {
+		if c != except {
+			c.send(line)
+		}
+	}
+}
+
+/* remembered is a message in the playback buffer */
+type remembered struct {
+	m minimalirc.Message
+	t time.Time
+}
+
+/* remember saves m in the playback buffer.  g.mu must be held. */
+func (g *Gateway) remember(m minimalirc.Message) {
+	n := g.History
+	if 0 >= n {
+		n = defaultHistory
+	}
+	if len(g.history) != n {
+		g.history = make([]remembered, n)
+		g.hnext = 0
+	}
+	t, ok := m.Time()
+	if !ok {
+		t = time.Now()
+	}
+	m.Tags = nil
+	g.history[g.hnext] = remembered{m: m, t: t}
+	g.hnext = (g.hnext + 1) % n
+}
+
+// playback sends the playback buffer to c, with the time each message was
+// received prepended to its text.  g.mu must be held.
+func (g *Gateway) playback(c *client) {
+	for n := range g.history {
+		r := g.history[(g.hnext+n)%len(g.history)]
+		if "" == r.m.Command {
+			continue
+		}
+		m := r.m
+		m.Params = append([]string(nil), m.Params...)
+		if l := len(m.Params); 0 != l {
+			/* Keep ACTIONs as ACTIONs */
+			a, text := "", m.Params[l-1]
+			if strings.HasPrefix(text, "\x01ACTION ") {
+				a, text = "\x01ACTION ", text[len("\x01ACTION "):]
+			}
+			m.Params[l-1] = fmt.Sprintf("%v[%v] %v", a,
+				r.t.Local().Format("2006-01-02 15:04"), text)
+		}
+		c.send(m.String())
+	}
+}
+
+/* send queues line for the client, dropping the client if it's too slow */
+func (c *client) send(line string) {
+	select {
+	case c.out <- line:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+/* close disconnects the client */
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// serveClient registers a client, attaches it, and proxies lines from it
+// to the server
+func (g *Gateway) serveClient(conn net.Conn) {
+	c := &client{
+		conn: conn,
+		out:  make(chan string, clientBuffer),
+		done: make(chan struct{}),
+	}
+	defer c.close()
+	r := textproto.NewReader(bufio.NewReader(conn))
+	w := textproto.NewWriter(bufio.NewWriter(conn))
+	/* Write queued lines to the client */
+	go func() {
+		for {
+			select {
+			case l := <-c.out:
+				if err := w.PrintfLine("%s", l); nil != err {
+					c.close()
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	if err := g.register(c, r); nil != err {
+		c.send(fmt.Sprintf("ERROR :%v", err))
+		return
+	}
+	g.attach(c)
+	defer g.detach(c)
+
+	/* Proxy lines from the client */
+	for {
+		line, err := r.ReadLine()
+		if nil != err {
+			return
+		}
+		m := minimalirc.ParseMessage(line)
+		switch m.Command {
+		case "PASS", "USER", "CAP":
+			continue
+		case "PING":
+			c.send(g.reply("PONG", g.IRC.Host, m.Param(0)))
+			continue
+		case "AWAY":
+			g.setAway(c, m.Param(0))
+			continue
+		case "QUIT":
+			return
+		}
+		/* Clients sometimes send a prefix; the server won't want it */
+		m.Prefix = ""
+		m.Tags = nil
+		if err := g.IRC.PrintfLine("%s", m.String()); nil != err {
+			c.send(fmt.Sprintf("ERROR :%v", err))
+			return
+		}
+		/* Let the other clients see what this one said */
+		if "PRIVMSG" == m.Command || "NOTICE" == m.Command {
+			m.Prefix = g.IRC.Hostmask()
+			if "" == m.Prefix {
+				m.Prefix = g.IRC.SNick()
+			}
+			g.broadcast(m, c)
+		}
+	}
+}
+
+// register waits for the client to register, and sends it the welcome and
+// the channels we're in
+func (g *Gateway) register(c *client, r *textproto.Reader) error {
+	var pass, nick, user bool
+	for !(nick && user) {
+		line, err := r.ReadLine()
+		if nil != err {
+			return err
+		}
+		m := minimalirc.ParseMessage(line)
+		switch m.Command {
+		case "CAP":
+			/* We don't do capabilities */
+			if "LS" == strings.ToUpper(m.Param(0)) {
+				c.send(g.reply("CAP", "*", "LS", ""))
+			}
+		case "PASS":
+			pass = 1 == subtle.ConstantTimeCompare(
+				[]byte(m.Param(0)),
+				[]byte(g.Pass),
+			)
+		case "NICK":
+			nick = true
+		case "USER":
+			user = true
+		case "QUIT":
+			return errors.New("client quit")
+		}
+	}
+	if "" != g.Pass && !pass {
+		c.send(g.reply("464", "*", "Password incorrect"))
+		return errors.New("incorrect password")
+	}
+
+	/* Tell the client who it is */
+	me := g.IRC.SNick()
+	if "" == me {
+		me = g.IRC.Nick
+	}
+	for _, l := range []string{
+		g.reply("001", me, "Welcome to IRC via minimalirc, "+me),
+		g.reply("002", me, "Your host is "+g.IRC.Host),
+		g.reply("003", me, "This gateway proxies an existing connection"),
+		g.reply("422", me, "MOTD File is missing"),
+	} {
+		c.send(l)
+	}
+	/* And where it is */
+	hm := g.IRC.Hostmask()
+	if "" == hm {
+		hm = me
+	}
+	for _, ch := range g.IRC.Channels() {
+		c.send(fmt.Sprintf(":%v JOIN %v", hm, ch))
+		c.send(g.reply("353", me, "=", ch, strings.Join(
+			g.IRC.Members(ch), " ")))
+		c.send(g.reply("366", me, ch, "End of /NAMES list"))
+	}
+	return nil
+}
+
+/* attach attaches c and plays back recent messages */
+func (g *Gateway) attach(c *client) {
+	g.mu.Lock()
+	if nil == g.clients {
+		g.clients = make(map[*client]bool)
+	}
+	g.playback(c)
+	g.clients[c] = true
+	changed := g.updateAway("")
+	g.mu.Unlock()
+	if changed {
+		g.sendAway()
+	}
+}
+
+/* detach detaches c */
+func (g *Gateway) detach(c *client) {
+	g.mu.Lock()
+	delete(g.clients, c)
+	changed := g.updateAway("")
+	g.mu.Unlock()
+	if changed {
+		g.sendAway()
+	}
+}
+
+/* setAway sets c's away message, or marks it back if msg is empty */
+func (g *Gateway) setAway(c *client, msg string) {
+	g.mu.Lock()
+	me := g.IRC.SNick()
+	c.away = msg
+	if "" == msg {
+		c.send(g.reply("305", me, "You are no longer marked as "+
+			"being away"))
+	} else {
+		c.send(g.reply("306", me, "You have been marked as being "+
+			"away"))
+	}
+	changed := g.updateAway(msg)
+	g.mu.Unlock()
+	if changed {
+		g.sendAway()
+	}
+}
+
+// updateAway works out whether we should be away upstream, and returns true
+// if that's changed.  latest is the most recently-set client away message.
+// g.mu must be held.  The caller tells the server, with sendAway, after
+// releasing g.mu.
+func (g *Gateway) updateAway(latest string) bool {
+	/* Away only if all the clients are */
+	msg := g.DetachedAway
+	for c := range g.clients {
+		if "" == c.away {
+			msg = ""
+			break
+		}
+		if "" == latest {
+			latest = c.away
+		}
+		msg = latest
+	}
+	if msg == g.away {
+		return false
+	}
+	g.away = msg
+	return true
+}
+
+// sendAway tells the server whether we're away, if it's changed since we last
+// told it.  The latest state is sent, so concurrent changes can't be sent out
+// of order.
+func (g *Gateway) sendAway() {
+	g.amu.Lock()
+	defer g.amu.Unlock()
+	g.mu.Lock()
+	msg := g.away
+	g.mu.Unlock()
+	if msg == g.sent {
+		return
+	}
+	var err error
+	if "" == msg {
+		err = g.IRC.PrintfLine("AWAY")
+	} else {
+		err = g.IRC.PrintfLine("AWAY :%v", msg)
+	}
+	if nil != err {
+		g.IRC.SendEvent(minimalirc.ErrorEvent{Err: err})
+		return
+	}
+	g.sent = msg
+}
+
+// reply makes a line from the gateway with the given command and
+// parameters
+func (g *Gateway) reply(command string, params ...string) string {
+	return minimalirc.Message{
+		Prefix:  g.IRC.Host,
+		Command: command,
+		Params:  params,
+	}.String()
+}
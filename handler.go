@@ -0,0 +1,109 @@
+package minimalirc
+
+import (
+	"context"
+	"time"
+)
+
+/*
+ * handler.go
+ * Callbacks for messages from the server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Handler is a function which handles a message from the server.  The context is cancelled when the connection is closed, and carries the IRC struct and the message's time, retrievable with FromContext and TimeFromContext.
+type Handler func(ctx context.Context, m Message)
+
+/* handler is a registered Handler */
+type handler struct {
+	h       Handler
+	ordered bool /* Registered with HandleOrdered */
+}
+
+/* ctxKey is the type of the keys for values stored in handler contexts */
+type ctxKey int
+
+const (
+	ircKey ctxKey = iota
+	timeKey
+	channelsKey
+	messengerKey
+)
+
+// Handle registers h to be called for every message from the server.  Handlers are called in the order in which they were registered, from the goroutine reading from the server, after the library has processed the message but before the line is sent on i.C.  If i.HandlerWorkers is set, handlers are instead run by that many goroutines, so a slow handler doesn't hold up reading, and may run concurrently and out of order.  Calls wait in a queue of up to i.HandlerQueue for a free worker; if it's full, i.HandlerOverflow says whether reading waits or the call's dropped with a DroppedEvent.  A panic in a handler run by a worker is sent as an ErrorEvent with a *PanicError, rather than ending the connection; like other events, it may be passed to i.OnEvent from the worker's goroutine, but never while OnEvent is handling another event.  Calls still queued when the connection ends are dropped.  Handlers which need to see messages in order should be registered with HandleOrdered.
+func (i *IRC) Handle(h Handler) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers = append(i.handlers, handler{h: h})
+}
+
+// HandleOrdered is like Handle, but if i.HandlerWorkers is set, h is called with every message in the order in which they arrived, one at a time, by a single goroutine shared by all handlers registered with HandleOrdered, while other handlers run concurrently.  This suits handlers which keep track of state, alongside slower handlers which do things.  Calls to ordered handlers wait in their own queue of up to i.HandlerQueue, subject to i.HandlerOverflow.
+func (i *IRC) HandleOrdered(h Handler) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers = append(i.handlers, handler{h: h, ordered: true})
+}
+
+// FromContext returns the IRC struct which received the message passed to a Handler with ctx.
+func FromContext(ctx context.Context) (*IRC, bool) {
+	i, ok := ctx.Value(ircKey).(*IRC)
+	return i, ok
+}
+
+// TimeFromContext returns the time the message passed to a Handler with ctx was sent, per the server-time tag, or the time it was received if the server didn't say.
+func TimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(timeKey).(time.Time)
+	return t, ok
+}
+
+// ChannelsFromContext returns, for QUIT and NICK messages passed to a Handler with ctx, the channels we shared with the nick before the message was received.  By the time handlers are called, the nick's no longer a member of those channels.
+func ChannelsFromContext(ctx context.Context) []string {
+	cs, _ := ctx.Value(channelsKey).([]string)
+	return cs
+}
+
+// messageContext makes the context passed to handlers with m.  channels are
+// the channels affected by m, if it's a QUIT or NICK.
+func (i *IRC) messageContext(m Message, channels []string) context.Context {
+	i.mu.Lock()
+	ctx := i.ctx
+	i.mu.Unlock()
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	/* Work out when the message was sent */
+	t, ok := m.Time()
+	if !ok {
+		t = time.Now()
+	}
+	ctx = context.WithValue(ctx, ircKey, i)
+	ctx = context.WithValue(ctx, timeKey, t)
+	if nil != channels {
+		ctx = context.WithValue(ctx, channelsKey, channels)
+	}
+	return ctx
+}
+
+/* dispatch calls the handlers with m */
+func (i *IRC) dispatch(ctx context.Context, m Message) {
+	i.mu.Lock()
+	hs := i.handlers
+	i.mu.Unlock()
+	for _, h := range hs {
+		if 0 < i.HandlerWorkers {
+			i.queueHandler(ctx, h, m)
+			continue
+		}
+		h.h(ctx, m)
+	}
+}
+
+/* listening returns true if there are any handlers or commands */
+func (i *IRC) listening() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return 0 != len(i.handlers) || 0 != len(i.commands)
+}
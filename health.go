@@ -0,0 +1,62 @@
+package minimalirc
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+)
+
+/*
+ * health.go
+ * Expose the connection's health
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Health is a snapshot of the connection's state and statistics, as served by HealthHandler and published by PublishExpvar.
+type Health struct {
+	Name       string   `json:"name,omitempty"` /* i.Name */
+	Healthy    bool     `json:"healthy"`        /* Registered with the server */
+	State      string   `json:"state"`
+	Server     string   `json:"server"`
+	Nick       string   `json:"nick"` /* The server's idea of our nick */
+	Identified bool     `json:"identified"`
+	Channels   []string `json:"channels"`
+	Stats      Stats    `json:"stats"`
+}
+
+// Health returns a snapshot of the connection's health.  The connection's healthy if it's registered with the server.
+func (i *IRC) Health() Health {
+	s := i.State()
+	return Health{
+		Name:       i.Name,
+		Healthy:    Registered == s,
+		State:      s.String(),
+		Server:     i.Host,
+		Nick:       i.SNick(),
+		Identified: i.Identified(),
+		Channels:   i.Channels(),
+		Stats:      i.Stats(),
+	}
+}
+
+// HealthHandler returns an http.Handler which serves the connection's Health as JSON, with a 200 status if the connection's healthy and 503 if not, suitable for liveness checks.
+func (i *IRC) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := i.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
+
+// PublishExpvar publishes the connection's Health as an expvar with the given name, which appears at /debug/vars when expvar's handler is served.  As with expvar.Publish, it panics if name is already in use.
+func (i *IRC) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return i.Health()
+	}))
+}
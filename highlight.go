@@ -0,0 +1,173 @@
+package minimalirc
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+ * highlight.go
+ * Notice when interesting things are said
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// HighlightEvent is sent when a PRIVMSG or NOTICE from someone else contains a highlight added with AddHighlight or AddHighlightPattern, or our nick if i.HighlightNick is true.
+type HighlightEvent struct {
+	Message Message /* The message */
+	Match   string  /* The text which matched */
+}
+
+func (HighlightEvent) event() {}
+
+/* highlight is a registered highlight */
+type highlight struct {
+	src string /* As passed to AddHighlight or AddHighlightPattern */
+	re  *regexp.Regexp
+}
+
+// nickChars are the characters other than letters and digits which may be
+// in a nick, used to find the edges of nicks in text
+const nickChars = "[]\\`_^{|}-"
+
+// AddHighlight adds a word which, when said by someone else, causes a HighlightEvent.  Words are matched case-insensitively, and only as whole words.
+func (i *IRC) AddHighlight(word string) {
+	re := regexp.MustCompile(`(?i)(?:^|\W)(` + regexp.QuoteMeta(word) +
+		`)(?:\W|$)`)
+	i.addHighlight(highlight{src: word, re: re})
+}
+
+// AddHighlightPattern adds a regular expression which, when matched by something said by someone else, causes a HighlightEvent.
+func (i *IRC) AddHighlightPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if nil != err {
+		return err
+	}
+	i.addHighlight(highlight{src: pattern, re: re})
+	return nil
+}
+
+/* addHighlight adds h to the list of highlights, if it's not there */
+func (i *IRC) addHighlight(h highlight) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, o := range i.highlights {
+		if o.src == h.src {
+			return
+		}
+	}
+	i.highlights = append(i.highlights, h)
+}
+
+// RemoveHighlight removes a word or pattern added with AddHighlight or AddHighlightPattern.  It returns false if there was no such highlight.
+func (i *IRC) RemoveHighlight(s string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n, h := range i.highlights {
+		if h.src == s {
+			i.highlights = append(i.highlights[:n:n],
+				i.highlights[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Highlights returns the words and patterns added with AddHighlight and AddHighlightPattern.
+func (i *IRC) Highlights() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	hs := make([]string, len(i.highlights))
+	for n, h := range i.highlights {
+		hs[n] = h.src
+	}
+	return hs
+}
+
+/* handleHighlight sends a HighlightEvent if m is highlighted */
+func (i *IRC) handleHighlight(m Message) {
+	if ("PRIVMSG" != m.Command && "NOTICE" != m.Command) ||
+		"" == m.User || i.isMe(m.Nick) {
+		return
+	}
+	text := m.Trailing()
+	/* Our nick */
+	if i.HighlightNick {
+		if n := findNick(text, i.SNick()); "" != n {
+			i.event(HighlightEvent{Message: m, Match: n})
+			return
+		}
+	}
+	/* Other highlights */
+	i.mu.Lock()
+	hs := i.highlights
+	i.mu.Unlock()
+	for _, h := range hs {
+		g := h.re.FindStringSubmatch(text)
+		if nil == g {
+			continue
+		}
+		match := g[0]
+		if 1 < len(g) {
+			match = g[1]
+		}
+		i.event(HighlightEvent{Message: m, Match: match})
+		return
+	}
+}
+
+// findNick finds nick in text, as a whole word, case-folded.  It returns the
+// nick as it appears in text, or the empty string if it's not there.
+func findNick(text, nick string) string {
+	if "" == nick {
+		return ""
+	}
+	for start := 0; start+len(nick) <= len(text); start++ {
+		end := start + len(nick)
+		if !equalFoldBytes(text[start:end], nick) {
+			continue
+		}
+		if (0 == start || !isNickByte(text[start-1])) &&
+			(len(text) == end || !isNickByte(text[end])) {
+			return text[start:end]
+		}
+	}
+	return ""
+}
+
+// equalFoldBytes compares a and b, which are the same length, with
+// RFC1459 case-folding
+func equalFoldBytes(a, b string) bool {
+	for n := 0; n < len(a); n++ {
+		if foldByte(a[n]) != foldByte(b[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+/* foldByte case-folds b per RFC1459 */
+func foldByte(b byte) byte {
+	switch b {
+	case '[':
+		return '{'
+	case ']':
+		return '}'
+	case '\\':
+		return '|'
+	case '~':
+		return '^'
+	}
+	if 'A' <= b && 'Z' >= b {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+/* isNickByte returns true if b may be part of a nick */
+func isNickByte(b byte) bool {
+	return ('a' <= b && 'z' >= b) || ('A' <= b && 'Z' >= b) ||
+		isDigit(b) || -1 != strings.IndexByte(nickChars, b)
+}
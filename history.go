@@ -0,0 +1,99 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ * history.go
+ * Remember where we were, to ask for what we missed
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* historyLimit is the number of messages requested with CHATHISTORY */
+const historyLimit = 100
+
+// HistoryMark records the last message seen from a target, as given by the msgid and server-time message tags.  Either may be unset if the server doesn't send it.
+type HistoryMark struct {
+	MsgID string
+	Time  time.Time
+}
+
+// HistoryMarks returns the last message seen from each channel or nick, keyed by name.  Marks are only kept when the server sends the msgid or time tags (i.e. the message-tags or server-time capabilities are in i.Caps).  The marks may be saved and passed to SetHistoryMarks on a new IRC struct after a restart.
+func (i *IRC) HistoryMarks() map[string]HistoryMark {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ms := make(map[string]HistoryMark, len(i.marks))
+	for k, v := range i.marks {
+		ms[k] = v
+	}
+	return ms
+}
+
+// SetHistoryMarks replaces the marks returned by HistoryMarks.
+func (i *IRC) SetHistoryMarks(marks map[string]HistoryMark) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.marks = make(map[string]HistoryMark, len(marks))
+	for k, v := range marks {
+		i.marks[fold(k)] = v
+	}
+}
+
+// RequestHistory asks the server, via CHATHISTORY, for messages to or from target sent after the last one we saw.  If there's no mark for the target, this is a no-op.  The replayed messages are delivered as normal.  The draft/chathistory capability must be in i.Caps.  If i.ResumeHistory is true, this is called for every channel we join.
+func (i *IRC) RequestHistory(target string) error {
+	i.mu.Lock()
+	mark, ok := i.marks[fold(target)]
+	i.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if !i.HasCap("draft/chathistory") && !i.HasCap("chathistory") {
+		return errors.New("server does not support chathistory")
+	}
+	/* msgids are exact, timestamps are close enough */
+	ref := "msgid=" + mark.MsgID
+	if "" == mark.MsgID {
+		ref = "timestamp=" + mark.Time.UTC().Format(serverTimeFormat)
+	}
+	return i.PrintfLine("CHATHISTORY AFTER %v %v %v", target, ref,
+		historyLimit)
+}
+
+// trackHistory updates the marks and requests history for newly-joined
+// channels
+func (i *IRC) trackHistory(m Message) {
+	switch m.Command {
+	case "PRIVMSG", "NOTICE":
+		/* Messages to us are marked with the sender */
+		t := m.Param(0)
+		if i.isMe(t) {
+			t = m.Nick
+		}
+		id := m.Tags["msgid"]
+		st, _ := m.Time()
+		if "" == t || ("" == id && st.IsZero()) {
+			return
+		}
+		i.mu.Lock()
+		if nil == i.marks {
+			i.marks = make(map[string]HistoryMark)
+		}
+		i.marks[fold(t)] = HistoryMark{MsgID: id, Time: st}
+		i.mu.Unlock()
+	case "JOIN":
+		if !i.ResumeHistory || i.PassiveMode || !i.isMe(m.Nick) {
+			return
+		}
+		if err := i.RequestHistory(m.Param(0)); nil != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"unable to request history for %v: %v",
+				m.Param(0), err))})
+		}
+	}
+}
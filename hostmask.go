@@ -0,0 +1,94 @@
+package minimalirc
+
+import (
+	"strings"
+)
+
+/*
+ * hostmask.go
+ * Work out how others see us
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// HostmaskEvent is sent when our visible username or host changes, e.g. when services apply a cloak.
+type HostmaskEvent struct {
+	Hostmask string /* New nick!user@host */
+}
+
+func (HostmaskEvent) event() {}
+
+// Hostmask returns our nick!user@host as others see it, or the empty string if it's not yet known.  The user and host are learned from numeric 396 (hidden host), CHGHOST, our own JOINs, the welcome message, and the reply to the USERHOST for our own nick sent after registration.
+func (i *IRC) Hostmask() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.hostmask()
+}
+
+/* hostmask returns our hostmask.  i.mu must be held. */
+func (i *IRC) hostmask() string {
+	if "" == i.myHost || "" == i.snick {
+		return ""
+	}
+	return i.snick + "!" + i.myUser + "@" + i.myHost
+}
+
+/* trackHostmask looks for our user and host in m */
+func (i *IRC) trackHostmask(m Message) {
+	switch m.Command {
+	case "001": /* RPL_WELCOME, often ends in our nick!user@host */
+		f := strings.Fields(m.Trailing())
+		if 0 == len(f) {
+			return
+		}
+		if n, u, h := SplitPrefix(f[len(f)-1]); i.isMe(n) && "" != h {
+			i.setHostmask(u, h)
+		}
+	case "396": /* RPL_HOSTHIDDEN, sometimes user@host */
+		u, h := "", m.Param(1)
+		if n := strings.IndexByte(h, '@'); -1 != n {
+			u, h = h[:n], h[n+1:]
+		}
+		i.setHostmask(u, h)
+	case "CHGHOST":
+		if i.isMe(m.Nick) {
+			i.setHostmask(m.Param(0), m.Param(1))
+		}
+	case "JOIN":
+		if i.isMe(m.Nick) && "" != m.Host {
+			i.setHostmask(m.User, m.Host)
+		}
+	case "302": /* RPL_USERHOST, nick[*]=[+-]user@host, space-separated */
+		for _, r := range strings.Fields(m.Trailing()) {
+			parts := strings.SplitN(r, "=", 2)
+			if 2 != len(parts) ||
+				!i.isMe(strings.TrimSuffix(parts[0], "*")) {
+				continue
+			}
+			uh := strings.TrimLeft(parts[1], "+-")
+			n := strings.IndexByte(uh, '@')
+			if -1 == n {
+				continue
+			}
+			i.setHostmask(uh[:n], uh[n+1:])
+		}
+	}
+}
+
+// setHostmask sets our username and host.  An empty user leaves the username
+// unchanged.  A HostmaskEvent is sent if anything changed.
+func (i *IRC) setHostmask(user, host string) {
+	i.mu.Lock()
+	old := i.hostmask()
+	if "" != user {
+		i.myUser = user
+	}
+	i.myHost = host
+	hm := i.hostmask()
+	i.mu.Unlock()
+	if old != hm {
+		i.event(HostmaskEvent{Hostmask: hm})
+	}
+}
@@ -0,0 +1,118 @@
+package minimalirc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * identd.go
+ * Answer the server's ident query
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+const (
+	/* identTimeout is how long the identd waits for the server */
+	identTimeout = time.Minute
+	/* identReadTimeout is how long the identd waits for a query */
+	identReadTimeout = 10 * time.Second
+)
+
+// identd is a minimal RFC1413 server which answers a single query about our
+// connection to the server
+type identd struct {
+	l     net.Listener
+	user  string
+	rport int /* Server's port */
+	mu    sync.Mutex
+	lport int /* Our port, 0 until we've connected */
+	once  sync.Once
+}
+
+// startIdentd starts an identd listening on i.Identd, which answers with
+// i.Username.  It stops when it's answered a query about our connection, when
+// stop is called, or after a minute.
+func (i *IRC) startIdentd() (*identd, error) {
+	l, err := net.Listen("tcp", i.Identd)
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf("unable to start identd on "+
+			"%v: %v", i.Identd, err))
+	}
+	d := &identd{l: l, user: i.Username, rport: int(i.Port)}
+	go d.serve()
+	time.AfterFunc(identTimeout, d.stop)
+	return d, nil
+}
+
+/* connected tells d the local address of our connection to the server */
+func (d *identd) connected(c net.Conn) {
+	a, ok := c.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lport = a.Port
+}
+
+/* stop stops d */
+func (d *identd) stop() {
+	d.once.Do(func() { d.l.Close() })
+}
+
+/* serve answers queries until d is stopped */
+func (d *identd) serve() {
+	for {
+		c, err := d.l.Accept()
+		if nil != err {
+			return
+		}
+		go func() {
+			if d.answer(c) {
+				d.stop()
+			}
+		}()
+	}
+}
+
+// answer answers a query on c, and returns true if it was about our
+// connection
+func (d *identd) answer(c net.Conn) bool {
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(identReadTimeout))
+	q, err := bufio.NewReader(c).ReadString('\n')
+	if nil != err {
+		return false
+	}
+	q = strings.TrimSpace(q)
+	/* Query is our-port , server-port */
+	ps := strings.Split(q, ",")
+	if 2 != len(ps) {
+		return false
+	}
+	lport, lerr := strconv.Atoi(strings.TrimSpace(ps[0]))
+	rport, rerr := strconv.Atoi(strings.TrimSpace(ps[1]))
+	if nil != lerr || nil != rerr {
+		fmt.Fprintf(c, "%v : ERROR : INVALID-PORT\r\n", q)
+		return false
+	}
+	d.mu.Lock()
+	ours := 0 != d.lport && lport == d.lport && rport == d.rport
+	d.mu.Unlock()
+	if !ours {
+		fmt.Fprintf(c, "%v , %v : ERROR : NO-USER\r\n", lport, rport)
+		return false
+	}
+	fmt.Fprintf(c, "%v , %v : USERID : UNIX : %v\r\n", lport, rport,
+		d.user)
+	return true
+}
@@ -0,0 +1,99 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ * identity.go
+ * Change who we look like
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* rotateTimeout is how long RotateNickEvery waits for a new nick */
+const rotateTimeout = 30 * time.Second
+
+// nickErrors are the numerics with which servers refuse a nick change,
+// including a few non-standard ones
+var nickErrors = map[string]bool{
+	"431": true, /* ERR_NONICKNAMEGIVEN */
+	"432": true, /* ERR_ERRONEUSNICKNAME */
+	"433": true, /* ERR_NICKNAMEINUSE */
+	"435": true, /* ERR_BANONCHAN */
+	"436": true, /* ERR_NICKCOLLISION */
+	"437": true, /* ERR_UNAVAILRESOURCE */
+	"438": true, /* ERR_NICKTOOFAST */
+	"447": true, /* ERR_NONICKCHANGE */
+}
+
+// ChangeNick asks the server to change our nick to nick, shortened to the server's NICKLEN, and waits for it to accept or refuse.  A refusal is returned as a *NumericError.  Channel membership and SNick follow the change once the server's accepted it.
+func (i *IRC) ChangeNick(ctx context.Context, nick string) error {
+	nick = i.fitNick(nick)
+	old := i.SNick()
+	ms, err := i.wait(ctx, func() error {
+		return i.PrintfLine("NICK %v", nick)
+	}, func(m Message) (bool, bool) {
+		/* By now, SNick's the new nick */
+		if "NICK" == m.Command && fold(old) == fold(m.Nick) {
+			return true, true
+		}
+		return nickErrors[m.Command], nickErrors[m.Command]
+	})
+	if nil != err {
+		return err
+	}
+	if m := ms[0]; "NICK" != m.Command {
+		return numericError(m)
+	}
+	return nil
+}
+
+// RotateNick changes our nick to a new one made from i.Nick, as ID does, and waits for the server to accept it, as ChangeNick.  If i.NickFunc isn't set, random numbers are appended whether or not i.RandomNumbers is set, so the nick is always new.  The new nick is returned.
+func (i *IRC) RotateNick(ctx context.Context) (string, error) {
+	nick := i.makeNick(true)
+	if err := i.ChangeNick(ctx, nick); nil != err {
+		return "", err
+	}
+	return i.SNick(), nil
+}
+
+// RotateNickEvery calls RotateNick every d while we're registered, as Every.  Errors are sent to i.OnEvent as ErrorEvents.  The returned function stops the rotation.
+func (i *IRC) RotateNickEvery(d time.Duration) func() {
+	return i.Every(d, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, rotateTimeout)
+		defer cancel()
+		if _, err := i.RotateNick(ctx); nil != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error rotating nick: %v", err))})
+		}
+	})
+}
+
+// Reregister sets i.Username to username, unless it's the empty string, and QUITs with msg, as Quit, so that the next Connect registers with the new username and a new nick.  The username can't be changed without reconnecting.  Reconnecting is left to the caller, as for any other disconnection.
+func (i *IRC) Reregister(username, msg string) error {
+	if "" != username {
+		i.Username = username
+	}
+	return i.Quit(msg)
+}
+
+// makeNick makes the nick to send to the server from i.Nick, with
+// i.NickFunc or by adding random numbers if i.RandomNumbers or random is
+// true, and shortens it to fit
+func (i *IRC) makeNick(random bool) string {
+	nick := i.configNick()
+	if nil != i.NickFunc {
+		nick = i.NickFunc(nick)
+	} else if i.RandomNumbers || random {
+		i.rngmu.Lock()
+		nick = fmt.Sprintf("%v-%v", nick, i.rng.Int63())
+		i.rngmu.Unlock()
+	}
+	return i.fitNick(nick)
+}
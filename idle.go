@@ -0,0 +1,113 @@
+package minimalirc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+ * idle.go
+ * Do things when it's gone quiet
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* idle is a function called when the connection or a channel is quiet */
+type idle struct {
+	channel string /* fold(channel), or "" for the whole connection */
+	d       time.Duration
+	f       func(ctx context.Context)
+	ctx     context.Context /* Connection for which it's running */
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// OnIdle calls f when nothing has been received from the server for d, and again every d for as long as it stays quiet.  Note that PINGs from the server count.  As with Every, f is only called while we're registered, and is passed a context which is cancelled when the connection ends.  The returned function stops f being called.  d must be positive.
+func (i *IRC) OnIdle(d time.Duration, f func(ctx context.Context)) func() {
+	return i.addIdle("", d, f)
+}
+
+// OnChannelIdle calls f when nothing has been said (with PRIVMSG or NOTICE) in channel for d, and again every d for as long as it stays quiet.  Our own messages don't count, unless the server echoes them.  We must be in the channel; the time is counted from when we join it.  Otherwise it's like OnIdle.
+func (i *IRC) OnChannelIdle(
+	channel string,
+	d time.Duration,
+	f func(ctx context.Context),
+) func() {
+	return i.addIdle(fold(channel), d, f)
+}
+
+/* addIdle adds and starts an idle function */
+func (i *IRC) addIdle(
+	channel string,
+	d time.Duration,
+	f func(ctx context.Context),
+) func() {
+	if 0 >= d {
+		panic("non-positive duration for OnIdle")
+	}
+	t := &idle{channel: channel, d: d, f: f, stop: make(chan struct{})}
+	i.mu.Lock()
+	i.idles = append(i.idles, t)
+	i.mu.Unlock()
+	i.startTasks()
+	return func() {
+		t.once.Do(func() { close(t.stop) })
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		for n, o := range i.idles {
+			if o == t {
+				i.idles = append(i.idles[:n], i.idles[n+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// lastActivity returns when something was last received from the server, or
+// said in channel if it's not "".  If we're not in channel, it returns the
+// current time.
+func (i *IRC) lastActivity(channel string) time.Time {
+	if "" == channel {
+		return i.Stats().LastIn
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	c, ok := i.channels[channel]
+	if !ok {
+		return time.Now()
+	}
+	return c.said
+}
+
+// run calls t.f when it's been quiet for t.d, until ctx is done or t is
+// stopped
+func (t *idle) run(ctx context.Context, i *IRC) {
+	/* Quiet is counted from when we start or last called f */
+	since := time.Now()
+	tm := time.NewTimer(t.d)
+	defer tm.Stop()
+	for {
+		select {
+		case <-tm.C:
+		case <-ctx.Done():
+			return
+		case <-t.stop:
+			return
+		}
+		last := i.lastActivity(t.channel)
+		if last.Before(since) {
+			last = since
+		}
+		/* Not quiet for long enough yet */
+		if w := t.d - time.Since(last); 0 < w {
+			tm.Reset(w)
+			continue
+		}
+		t.f(ctx)
+		since = time.Now()
+		tm.Reset(t.d)
+	}
+}
@@ -0,0 +1,108 @@
+package minimalirc
+
+import (
+	"regexp"
+)
+
+/*
+ * ignore.go
+ * Ignore people
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// AddIgnoreMask ignores messages from senders matching mask (e.g. *!*@bad.example.com, see MatchMask).  Ignored messages are still used to keep track of channels and the like, but are otherwise dropped: they're not answered, passed to handlers, or sent on i.C.
+func (i *IRC) AddIgnoreMask(mask string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, m := range i.ignMasks {
+		if m == mask {
+			return
+		}
+	}
+	i.ignMasks = append(i.ignMasks, mask)
+}
+
+// RemoveIgnoreMask stops ignoring senders matching mask.  It returns false if mask wasn't being ignored.
+func (i *IRC) RemoveIgnoreMask(mask string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n, m := range i.ignMasks {
+		if m == mask {
+			i.ignMasks = append(i.ignMasks[:n:n], i.ignMasks[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreMasks returns the masks added with AddIgnoreMask.
+func (i *IRC) IgnoreMasks() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]string(nil), i.ignMasks...)
+}
+
+// AddIgnorePattern ignores lines from the server which match the regular expression pattern.  Ignored lines are treated as for AddIgnoreMask.  Only lines with a sender (i.e. not from the server) are ignored.
+func (i *IRC) AddIgnorePattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if nil != err {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, r := range i.ignREs {
+		if r.String() == pattern {
+			return nil
+		}
+	}
+	i.ignREs = append(i.ignREs, re)
+	return nil
+}
+
+// RemoveIgnorePattern stops ignoring lines matching pattern.  It returns false if pattern wasn't being ignored.
+func (i *IRC) RemoveIgnorePattern(pattern string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n, r := range i.ignREs {
+		if r.String() == pattern {
+			i.ignREs = append(i.ignREs[:n:n], i.ignREs[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IgnorePatterns returns the patterns added with AddIgnorePattern.
+func (i *IRC) IgnorePatterns() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ps := make([]string, len(i.ignREs))
+	for n, r := range i.ignREs {
+		ps[n] = r.String()
+	}
+	return ps
+}
+
+/* ignored returns true if m is from a user who's ignored */
+func (i *IRC) ignored(m Message) bool {
+	/* Only users get ignored, not servers or us */
+	if "" == m.User || i.isMe(m.Nick) {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, mask := range i.ignMasks {
+		if MatchMask(mask, m.Prefix) {
+			return true
+		}
+	}
+	for _, re := range i.ignREs {
+		if re.MatchString(m.Raw) {
+			return true
+		}
+	}
+	return false
+}
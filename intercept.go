@@ -0,0 +1,86 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+ * intercept.go
+ * Per-conversation hooks for encryption and the like
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Interceptor transforms the text of PRIVMSGs in a conversation, e.g. to implement OTR or some other end-to-end encryption.  The text passed to and returned from both methods is the text of the PRIVMSG, without CTCP or any other processing.
+type Interceptor interface {
+	/* Outgoing is called with the text of a PRIVMSG to target before it's
+	sent, and returns the text to send.  If an error is returned, the
+	message isn't sent and the error is returned to the caller. */
+	Outgoing(target, text string) (string, error)
+	/* Incoming is called with the text of a PRIVMSG from the sender,
+	a nick!user@host, to target, either a channel or our nick.  It
+	returns the text to pass to the rest of the library, handlers, and
+	i.C.  If an error is returned, the message is passed on unchanged and
+	the error is sent as an ErrorEvent. */
+	Incoming(from, target, text string) (string, error)
+}
+
+// SetInterceptor sets the Interceptor for the conversation with target, which is either a channel or a nick.  Messages to and from the channel, or sent directly between us and the nick, are passed through x.  A nil x removes the Interceptor.
+func (i *IRC) SetInterceptor(target string, x Interceptor) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == x {
+		delete(i.intercepts, fold(target))
+		return
+	}
+	if nil == i.intercepts {
+		i.intercepts = make(map[string]Interceptor)
+	}
+	i.intercepts[fold(target)] = x
+}
+
+/* interceptor returns the Interceptor for the conversation with target */
+func (i *IRC) interceptor(target string) (Interceptor, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	x, ok := i.intercepts[fold(target)]
+	return x, ok
+}
+
+// interceptOut passes a PRIVMSG to target through its Interceptor, if it
+// has one
+func (i *IRC) interceptOut(target, text string) (string, error) {
+	x, ok := i.interceptor(target)
+	if !ok {
+		return text, nil
+	}
+	return x.Outgoing(target, text)
+}
+
+// intercept passes a received PRIVMSG through its Interceptor, if it has
+// one, and returns the modified message and true if it was changed
+func (i *IRC) intercept(m Message) (Message, bool) {
+	if "PRIVMSG" != m.Command || 2 != len(m.Params) {
+		return m, false
+	}
+	/* Conversations with nicks are keyed by their nick */
+	conv := m.Param(0)
+	if i.isMe(conv) {
+		conv = m.Nick
+	}
+	x, ok := i.interceptor(conv)
+	if !ok {
+		return m, false
+	}
+	text, err := x.Incoming(m.Prefix, m.Param(0), m.Param(1))
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"interceptor for %v failed: %v", conv, err))})
+		return m, false
+	}
+	m.Params = []string{m.Param(0), text}
+	return m, true
+}
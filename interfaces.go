@@ -0,0 +1,51 @@
+package minimalirc
+
+import "context"
+
+/*
+ * interfaces.go
+ * Small interfaces implemented by IRC, for mocking
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// LineSender sends raw lines to an IRC server.  It's implemented by *IRC.
+type LineSender interface {
+	PrintfLine(f string, args ...interface{}) error
+}
+
+// Messenger sends messages to channels and nicks.  It's implemented by *IRC, and is what handlers which only reply to messages need, so they can be tested with a fake.
+type Messenger interface {
+	LineSender
+	Privmsg(msg, target string) error
+	Notice(msg, target string) error
+}
+
+// Receiver passes messages from the server to handlers.  It's implemented by *IRC.
+type Receiver interface {
+	Handle(h Handler)
+}
+
+/* Make sure IRC implements the interfaces */
+var (
+	_ Messenger = (*IRC)(nil)
+	_ Receiver  = (*IRC)(nil)
+)
+
+// WithMessenger returns a copy of ctx carrying m, for passing to a Handler being tested without a connection.  MessengerFromContext returns m in preference to the IRC struct which received the message.
+func WithMessenger(ctx context.Context, m Messenger) context.Context {
+	return context.WithValue(ctx, messengerKey, m)
+}
+
+// MessengerFromContext returns the Messenger set with WithMessenger, or the IRC struct which received the message passed to a Handler with ctx.
+func MessengerFromContext(ctx context.Context) (Messenger, bool) {
+	if m, ok := ctx.Value(messengerKey).(Messenger); ok {
+		return m, true
+	}
+	if i, ok := FromContext(ctx); ok {
+		return i, true
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package minimalirc
+
+/*
+ * invite.go
+ * Join channels we're invited to
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// InviteEvent is sent when we're invited to a channel.  Joined is true if a JOIN was sent due to i.AutoJoinOnInvite.  Only invitations from someone matching one of the masks in i.InviteAllow are accepted; with no masks, none are.
+type InviteEvent struct {
+	From    string /* nick!user@host of the inviter */
+	Channel string /* Channel to which we're invited */
+	Joined  bool   /* True if we tried to join */
+}
+
+func (InviteEvent) event() {}
+
+/* handleInvite joins channels to which we're invited, if allowed */
+func (i *IRC) handleInvite(m Message) {
+	if "INVITE" != m.Command || !i.isMe(m.Param(0)) {
+		return
+	}
+	e := InviteEvent{From: m.Prefix, Channel: m.Param(1)}
+	if i.AutoJoinOnInvite && !i.PassiveMode &&
+		i.inviteAllowed(m.Prefix) {
+		if err := i.Join(e.Channel, ""); nil != err {
+			i.event(ErrorEvent{Err: err})
+		} else {
+			e.Joined = true
+		}
+	}
+	i.event(e)
+}
+
+// inviteAllowed returns true if the inviter matches one of the masks in
+// i.InviteAllow.  Nobody's allowed if it's empty; "*" allows everybody.
+func (i *IRC) inviteAllowed(from string) bool {
+	for _, m := range i.InviteAllow {
+		if MatchMask(m, from) {
+			return true
+		}
+	}
+	return false
+}
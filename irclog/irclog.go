@@ -0,0 +1,183 @@
+// Package irclog writes per-channel, per-day log files of what's said on IRC.
+package irclog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * irclog.go
+ * Per-channel, per-day logs
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../minimalirc.go for license details.
+ */
+
+/* Formats for log lines and file names */
+const (
+	lineTime = "2006-01-02 15:04:05"
+	fileDay  = "2006-01-02"
+)
+
+// Logger writes weechat-style logs, one file per channel per day, named like Dir/#channel/2006-01-02.log.  Timestamps come from the server-time tag if the server sends it (add "server-time" to i.Caps).  Our own messages are only logged if the server echoes them (add "echo-message" to i.Caps).
+type Logger struct {
+	Dir string /* Directory in which to put logs */
+
+	mu    sync.Mutex
+	files map[string]*logFile /* Open files, keyed by lower-case channel */
+}
+
+/* logFile is a day's log file for a channel */
+type logFile struct {
+	day string
+	f   *os.File
+}
+
+// New returns a Logger which logs to files in dir.
+func New(dir string) *Logger {
+	return &Logger{Dir: dir, files: make(map[string]*logFile)}
+}
+
+// Attach registers l as a handler on i, usually an *minimalirc.IRC.
+func (l *Logger) Attach(i minimalirc.Receiver) {
+	i.Handle(l.Handle)
+}
+
+// Handle is a minimalirc.Handler which logs m.  Errors writing logs are sent to i.OnEvent as minimalirc.ErrorEvents.
+func (l *Logger) Handle(ctx context.Context, m minimalirc.Message) {
+	t, ok := minimalirc.TimeFromContext(ctx)
+	if !ok {
+		t = time.Now()
+	}
+	/* Work out what to log and where */
+	var (
+		cs   []string
+		pfx  string
+		text string
+		who  = fmt.Sprintf("%v (%v@%v)", m.Nick, m.User, m.Host)
+	)
+	switch m.Command {
+	case "PRIVMSG":
+		cs = []string{m.Param(0)}
+		pfx, text = m.Nick, m.Trailing()
+		if a := strings.TrimPrefix(text, "\x01ACTION "); a != text {
+			pfx, text = " *", m.Nick+" "+strings.TrimSuffix(a, "\x01")
+		}
+	case "NOTICE":
+		cs = []string{m.Param(0)}
+		pfx, text = "--", fmt.Sprintf("Notice(%v): %v", m.Nick,
+			m.Trailing())
+	case "JOIN":
+		cs = []string{m.Param(0)}
+		pfx, text = "-->", fmt.Sprintf("%v has joined %v", who,
+			m.Param(0))
+	case "PART":
+		cs = []string{m.Param(0)}
+		pfx, text = "<--", fmt.Sprintf("%v has left %v (%v)", who,
+			m.Param(0), m.Param(1))
+	case "KICK":
+		cs = []string{m.Param(0)}
+		pfx, text = "<--", fmt.Sprintf("%v has kicked %v (%v)",
+			m.Nick, m.Param(1), m.Param(2))
+	case "QUIT":
+		cs = minimalirc.ChannelsFromContext(ctx)
+		pfx, text = "<--", fmt.Sprintf("%v has quit (%v)", who,
+			m.Param(0))
+	case "NICK":
+		cs = minimalirc.ChannelsFromContext(ctx)
+		pfx, text = "--", fmt.Sprintf("%v is now known as %v", m.Nick,
+			m.Param(0))
+	case "TOPIC":
+		cs = []string{m.Param(0)}
+		pfx, text = "--", fmt.Sprintf("%v has changed topic for %v "+
+			"to \"%v\"", m.Nick, m.Param(0), m.Param(1))
+	case "MODE":
+		if 2 > len(m.Params) {
+			return
+		}
+		cs = []string{m.Param(0)}
+		pfx, text = "--", fmt.Sprintf("Mode %v [%v] by %v", m.Param(0),
+			strings.Join(m.Params[1:], " "), m.Nick)
+	default:
+		return
+	}
+	line := fmt.Sprintf("%v\t%v\t%v\n", t.Local().Format(lineTime), pfx,
+		text)
+	for _, c := range cs {
+		if "" == c || !strings.ContainsAny(c[:1], "#&+!") {
+			continue
+		}
+		if err := l.write(c, t, line); nil != err {
+			if i, ok := minimalirc.FromContext(ctx); ok {
+				i.SendEvent(minimalirc.ErrorEvent{Err: err})
+			}
+		}
+	}
+}
+
+// Close closes all open log files.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var err error
+	for k, f := range l.files {
+		if e := f.f.Close(); nil != e && nil == err {
+			err = e
+		}
+		delete(l.files, k)
+	}
+	return err
+}
+
+// write writes line to channel's log for the day of t, opening a new file
+// if the day's changed
+func (l *Logger) write(channel string, t time.Time, line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if nil == l.files {
+		l.files = make(map[string]*logFile)
+	}
+	k := strings.ToLower(channel)
+	day := t.Local().Format(fileDay)
+	f, ok := l.files[k]
+	/* Rotate if it's a new day */
+	if ok && f.day != day {
+		f.f.Close()
+		delete(l.files, k)
+		ok = false
+	}
+	if !ok {
+		d := filepath.Join(l.Dir, safeName(k))
+		if err := os.MkdirAll(d, 0700); nil != err {
+			return err
+		}
+		o, err := os.OpenFile(filepath.Join(d, day+".log"),
+			os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if nil != err {
+			return err
+		}
+		f = &logFile{day: day, f: o}
+		l.files[k] = f
+	}
+	_, err := f.f.WriteString(line)
+	return err
+}
+
+/* safeName makes a channel name safe for use as a directory name */
+func safeName(channel string) string {
+	return strings.Map(func(r rune) rune {
+		if '/' == r || '\\' == r || 0 == r {
+			return '_'
+		}
+		return r
+	}, channel)
+}
@@ -0,0 +1,81 @@
+package minimalirc
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+ * isupport.go
+ * What the server says it supports
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ISupport returns the value of the token key from the server's ISUPPORT (005) replies, and whether the server sent it.  Tokens without values (e.g. EXCEPTS on some servers) have an empty value.
+func (i *IRC) ISupport(key string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	v, ok := i.isupport[key]
+	return v, ok
+}
+
+// isupportInt returns the integer value of the ISUPPORT token key, or def
+// if it's not sent or not a number
+func (i *IRC) isupportInt(key string, def int) int {
+	v, ok := i.ISupport(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if nil != err {
+		return def
+	}
+	return n
+}
+
+/* trackISupport records the tokens in 005 replies */
+func (i *IRC) trackISupport(m Message) {
+	/* The first parameter is our nick, the last is human-readable */
+	if "005" != m.Command || 3 > len(m.Params) {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.isupport {
+		i.isupport = make(map[string]string)
+	}
+	for _, t := range m.Params[1 : len(m.Params)-1] {
+		if strings.HasPrefix(t, "-") {
+			delete(i.isupport, t[1:])
+			continue
+		}
+		k, v := t, ""
+		if n := strings.IndexByte(t, '='); -1 != n {
+			k, v = t[:n], unescapeISupport(t[n+1:])
+		}
+		i.isupport[k] = v
+	}
+}
+
+/* unescapeISupport replaces \xHH escapes in an ISUPPORT value */
+func unescapeISupport(v string) string {
+	if !strings.Contains(v, `\x`) {
+		return v
+	}
+	var b strings.Builder
+	for n := 0; n < len(v); n++ {
+		if '\\' == v[n] && n+3 < len(v) && 'x' == v[n+1] {
+			if c, err := strconv.ParseUint(v[n+2:n+4], 16, 8); nil ==
+				err {
+				b.WriteByte(byte(c))
+				n += 3
+				continue
+			}
+		}
+		b.WriteByte(v[n])
+	}
+	return b.String()
+}
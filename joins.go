@@ -0,0 +1,226 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+/*
+ * joins.go
+ * Join lots of channels without upsetting the server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+const (
+	joinBatch      = 5                /* Channels per JOIN */
+	joinDelay      = 2 * time.Second  /* Delay between batches */
+	joinTimeout    = 30 * time.Second /* Time to wait for replies */
+	joinBackoffMin = 5 * time.Second  /* First delay after throttling */
+	joinBackoffMax = 2 * time.Minute  /* Longest delay after throttling */
+	joinTries      = 5                /* Attempts per channel */
+)
+
+// joinErrors are the numerics with which the server refuses a JOIN, other
+// than for joining too fast
+var joinErrors = map[string]bool{
+	"403": true, /* ERR_NOSUCHCHANNEL */
+	"405": true, /* ERR_TOOMANYCHANNELS */
+	"471": true, /* ERR_CHANNELISFULL */
+	"473": true, /* ERR_INVITEONLYCHAN */
+	"474": true, /* ERR_BANNEDFROMCHAN */
+	"475": true, /* ERR_BADCHANNELKEY */
+	"477": true, /* ERR_NEEDREGGEDNICK */
+	"489": true, /* ERR_SECUREONLYCHAN */
+}
+
+/* joinThrottles are the numerics which say we're joining too fast */
+var joinThrottles = map[string]bool{"480": true, "439": true, "263": true}
+
+// JoinResult is the result of joining a channel with JoinThrottled.  Err is nil if the channel was joined.  Errors from the server are *NumericErrors.
+type JoinResult struct {
+	Channel string
+	Err     error
+}
+
+/* joinReq is a channel to join */
+type joinReq struct {
+	channel string
+	key     string
+	tries   int
+}
+
+// JoinThrottled joins channels, with keys in the same order as the channels (missing keys are treated as empty), a few at a time.  If the server says we're joining too fast (numerics 480, 439, and 263), we back off and try again.  The result for each channel is sent on the returned channel, which is closed when every channel has a result.  If ctx is done, the remaining channels get ctx's error.
+func (i *IRC) JoinThrottled(
+	ctx context.Context,
+	channels []string,
+	keys []string,
+) <-chan JoinResult {
+	rc := make(chan JoinResult, len(channels))
+	var pending []*joinReq
+	for n, ch := range channels {
+		r := &joinReq{channel: ch}
+		if n < len(keys) {
+			r.key = keys[n]
+		}
+		pending = append(pending, r)
+	}
+	go func() {
+		defer close(rc)
+		backoff := joinBackoffMin
+		for 0 != len(pending) {
+			n := joinBatch
+			if n > len(pending) {
+				n = len(pending)
+			}
+			retry, err := i.joinBatch(ctx, pending[:n], rc)
+			if nil != err {
+				for _, r := range pending[n:] {
+					rc <- JoinResult{Channel: r.channel, Err: err}
+				}
+				return
+			}
+			pending = append(retry, pending[n:]...)
+			/* Slow down if we're going too fast */
+			wait := joinDelay
+			if 0 != len(retry) {
+				wait = backoff
+				if backoff *= 2; backoff > joinBackoffMax {
+					backoff = joinBackoffMax
+				}
+			} else {
+				backoff = joinBackoffMin
+			}
+			if 0 == len(pending) {
+				return
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return rc
+}
+
+// joinBatch joins the channels in rs, sending results on rc.  The channels
+// to try again are returned.  An error is returned if the remaining channels
+// shouldn't be tried.
+func (i *IRC) joinBatch(
+	ctx context.Context,
+	rs []*joinReq,
+	rc chan<- JoinResult,
+) ([]*joinReq, error) {
+	if err := ctx.Err(); nil != err {
+		for _, r := range rs {
+			rc <- JoinResult{Channel: r.channel, Err: err}
+		}
+		return nil, err
+	}
+	/* Work out which channels actually need joining */
+	byName := make(map[string]*joinReq)
+	var (
+		names []string
+		keys  []string
+	)
+	for _, r := range rs {
+		if i.InChannel(r.channel) {
+			rc <- JoinResult{Channel: r.channel}
+			continue
+		}
+		r.tries++
+		byName[fold(r.channel)] = r
+		names = append(names, r.channel)
+		keys = append(keys, r.key)
+	}
+	if 0 == len(names) {
+		return nil, nil
+	}
+
+	/* Join them and wait for the replies */
+	var (
+		results = make(map[string]error)
+		retry   []*joinReq
+	)
+	wctx, cancel := context.WithTimeout(ctx, joinTimeout)
+	defer cancel()
+	_, err := i.wait(wctx, func() error {
+		return i.JoinMany(names, keys)
+	}, func(m Message) (bool, bool) {
+		var (
+			r  *joinReq
+			ok bool
+		)
+		switch m.Command {
+		case "JOIN":
+			if r, ok = byName[fold(m.Param(0))]; ok && i.isMe(m.Nick) {
+				results[fold(r.channel)] = nil
+			}
+		default:
+			if r, ok = byName[fold(m.Param(1))]; !ok {
+				break
+			}
+			if joinErrors[m.Command] || (joinThrottles[m.Command] &&
+				joinTries <= r.tries) {
+				results[fold(r.channel)] = numericError(m)
+			} else if joinThrottles[m.Command] {
+				retry = append(retry, r)
+				results[fold(r.channel)] = nil
+			}
+		}
+		return false, len(results) == len(byName)
+	})
+	/* Report what we heard, and don't retry what we didn't */
+	throttled := make(map[*joinReq]bool)
+	for _, r := range retry {
+		throttled[r] = true
+	}
+	for k, r := range byName {
+		if throttled[r] {
+			continue
+		}
+		rerr, ok := results[k]
+		if !ok {
+			rerr = err
+			if errors.Is(rerr, context.DeadlineExceeded) &&
+				nil == ctx.Err() {
+				rerr = errors.New("no reply from server")
+			}
+		}
+		rc <- JoinResult{Channel: r.channel, Err: rerr}
+	}
+	if nil != err && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	return retry, nil
+}
+
+// JoinWait joins channel, with the optional key, and waits for the server to send the list of its members, which it returns.  If the server refuses, the error is a *NumericError.  If we're already in the channel, its members are returned without sending a JOIN.  If ctx has no deadline, JoinWait gives up with a *TimeoutError after i.RequestTimeout.
+func (i *IRC) JoinWait(ctx context.Context, channel, key string) (
+	[]string, error) {
+	if "" == channel || strings.Contains(channel, ",") {
+		return nil, errors.New("JoinWait needs exactly one channel")
+	}
+	if i.InChannel(channel) {
+		return i.Members(channel), nil
+	}
+	ms, err := i.wait(ctx, func() error {
+		return i.Join(channel, key)
+	}, func(m Message) (bool, bool) {
+		ok := fold(channel) == fold(m.Param(1)) &&
+			("366" == m.Command || /* RPL_ENDOFNAMES */
+				joinErrors[m.Command] || joinThrottles[m.Command])
+		return ok, ok
+	})
+	if nil != err {
+		return nil, err
+	}
+	if m := ms[0]; "366" != m.Command {
+		return nil, numericError(m)
+	}
+	return i.Members(channel), nil
+}
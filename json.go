@@ -0,0 +1,69 @@
+package minimalirc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+/*
+ * json.go
+ * Messages as JSON, for non-Go consumers
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// JSONMessage is the JSON form of a message written by JSONHandler and sent by JSONChannel.  It's a Message with the time it was sent, per the server-time tag, or when it was received.
+type JSONMessage struct {
+	Time time.Time `json:"time"`
+	Message
+}
+
+/* jsonMessage makes the JSON for m, received with ctx */
+func jsonMessage(ctx context.Context, m Message) ([]byte, error) {
+	t, ok := TimeFromContext(ctx)
+	if !ok {
+		if t, ok = m.Time(); !ok {
+			t = time.Now()
+		}
+	}
+	if nil == m.Params {
+		m.Params = []string{}
+	}
+	return json.Marshal(JSONMessage{Time: t, Message: m})
+}
+
+// JSONHandler returns a Handler which writes each message to w as a JSONMessage, one per line, making minimalirc a shim between IRC and anything which reads JSON.  Register it with Handle.  Errors are sent to i.OnEvent as ErrorEvents.  If the handler is registered on more than one IRC struct, writes to w aren't serialized.
+func JSONHandler(w io.Writer) Handler {
+	return func(ctx context.Context, m Message) {
+		b, err := jsonMessage(ctx, m)
+		if nil == err {
+			_, err = w.Write(append(b, '\n'))
+		}
+		if nil != err {
+			if i, ok := FromContext(ctx); ok {
+				i.event(ErrorEvent{Err: err})
+			}
+		}
+	}
+}
+
+// JSONChannel returns a Handler which sends each message on c as a JSONMessage.  Register it with Handle.  Sending blocks reading from the server until c is read or the connection ends, in which case the message is dropped.
+func JSONChannel(c chan<- []byte) Handler {
+	return func(ctx context.Context, m Message) {
+		b, err := jsonMessage(ctx, m)
+		if nil != err {
+			if i, ok := FromContext(ctx); ok {
+				i.event(ErrorEvent{Err: err})
+			}
+			return
+		}
+		select {
+		case c <- b:
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package minimalirc
+
+/*
+ * knock.go
+ * Ask to be invited to channels
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// KnockEvent is sent when someone KNOCKs on a channel on which we're an operator (numeric 710).
+type KnockEvent struct {
+	Channel string
+	From    string /* nick!user@host of the knocker */
+	Text    string /* Usually something like "has asked for an invite" */
+}
+
+func (KnockEvent) event() {}
+
+// KnockReplyEvent is sent when the server replies to a KNOCK.  Err is nil if the knock was delivered (numeric 711), or a *NumericError if not (712, 713, or 714).
+type KnockReplyEvent struct {
+	Channel string
+	Err     error
+}
+
+func (KnockReplyEvent) event() {}
+
+// Knock asks the operators of channel, which is usually +i, to invite us, with the optional reason.  The server's reply is sent as a KnockReplyEvent.  Not all servers support KNOCK; those which do usually have KNOCK in their ISUPPORT tokens.
+func (i *IRC) Knock(channel, reason string) error {
+	if "" == reason {
+		return i.PrintfLine("KNOCK %v", channel)
+	}
+	return i.PrintfLine("KNOCK %v :%v", channel, reason)
+}
+
+/* handleKnock turns KNOCK numerics into events */
+func (i *IRC) handleKnock(m Message) {
+	switch m.Command {
+	case "710": /* RPL_KNOCK */
+		i.event(KnockEvent{
+			Channel: m.Param(1),
+			From:    m.Param(2),
+			Text:    m.Param(3),
+		})
+	case "711": /* RPL_KNOCKDLVR */
+		i.event(KnockReplyEvent{Channel: m.Param(1)})
+	case "712", /* ERR_TOOMANYKNOCK */
+		"713", /* ERR_CHANOPEN */
+		"714": /* ERR_KNOCKONCHAN */
+		i.event(KnockReplyEvent{
+			Channel: m.Param(1),
+			Err:     numericError(m),
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ * lag.go
+ * Measure lag and send as fast as the server keeps up
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* Defaults for LagPolicy */
+const (
+	defaultLagEvery   = 15 * time.Second
+	defaultLagTarget  = 2 * time.Second
+	defaultLagMinRate = 0.5
+	defaultLagMaxRate = 5
+	defaultLagBurst   = 5
+)
+
+// LagEvent is sent by AdaptRate each time it measures the lag, with the rate it's set.
+type LagEvent struct {
+	Lag  time.Duration /* Time for the server to answer a PING */
+	Rate float64       /* Lines per second i.Limiter now allows */
+}
+
+func (LagEvent) event() {}
+
+// LagPolicy says how AdaptRate adjusts i.Limiter.  Every Every, the lag is measured.  If it's over Target, the rate's halved, down to MinRate.  If it's under half of Target, the rate's increased by MinRate, up to MaxRate.
+type LagPolicy struct {
+	Every   time.Duration /* Time between measurements, 15s if 0 */
+	Target  time.Duration /* Acceptable lag, 2s if 0 */
+	MinRate float64       /* Lines per second, 0.5 if 0 */
+	MaxRate float64       /* Lines per second, 5 if 0 */
+	Burst   int           /* Burst for i.Limiter, 5 if 0 */
+}
+
+// MeasureLag sends the server a PING and returns how long it took for the PONG to come back, which includes the time the server took to get through whatever we'd sent before the PING.  The time the PING spends waiting to be sent (e.g. for i.Limiter) isn't counted.  The lag is also kept for Lag.
+func (i *IRC) MeasureLag(ctx context.Context) (time.Duration, error) {
+	var sent time.Time
+	tok := fmt.Sprintf("lag-%v", time.Now().UnixNano())
+	_, err := i.wait(ctx, func() error {
+		if err := i.PrintfLine("PING :%v", tok); nil != err {
+			return err
+		}
+		sent = time.Now()
+		return nil
+	}, func(m Message) (bool, bool) {
+		ok := "PONG" == m.Command && tok == m.Trailing()
+		return ok, ok
+	})
+	if nil != err {
+		return 0, err
+	}
+	lag := time.Since(sent)
+	i.mu.Lock()
+	i.lag = lag
+	i.mu.Unlock()
+	return lag, nil
+}
+
+// Lag returns the lag last measured with MeasureLag (or by AdaptRate), or 0 if it's not been measured.
+func (i *IRC) Lag() time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.lag
+}
+
+// AdaptRate measures the lag while we're registered, as Every, and speeds up or slows down i.Limiter to keep the lag near p.Target, like the flood control of classic clients, so we send as fast as the server will let us without being disconnected for flooding.  If i.Limiter is nil, it's set to a new Limiter allowing p.MaxRate lines per second.  Since i.Limiter may be shared, the combined rate of every connection using it is adjusted.  Each measurement is sent as a LagEvent, and errors as ErrorEvents.  The returned function stops the adjustments, leaving the rate as it is.
+func (i *IRC) AdaptRate(p LagPolicy) func() {
+	if 0 >= p.Every {
+		p.Every = defaultLagEvery
+	}
+	if 0 >= p.Target {
+		p.Target = defaultLagTarget
+	}
+	if 0 >= p.MinRate {
+		p.MinRate = defaultLagMinRate
+	}
+	if 0 >= p.MaxRate {
+		p.MaxRate = defaultLagMaxRate
+	}
+	if 0 >= p.Burst {
+		p.Burst = defaultLagBurst
+	}
+	if nil == i.Limiter {
+		i.Limiter = NewLimiter(p.MaxRate, p.Burst)
+	}
+	l := i.Limiter
+	return i.Every(p.Every, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, p.Every)
+		defer cancel()
+		lag, err := i.MeasureLag(ctx)
+		/* No PONG in time is as laggy as it gets */
+		var te *TimeoutError
+		if errors.As(err, &te) {
+			lag, err = te.Waited, nil
+		}
+		if nil != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error measuring lag: %v", err))})
+			return
+		}
+		rate := l.Rate()
+		switch {
+		case lag > p.Target:
+			rate /= 2
+		case lag < p.Target/2:
+			rate += p.MinRate
+		}
+		if rate < p.MinRate {
+			rate = p.MinRate
+		} else if rate > p.MaxRate {
+			rate = p.MaxRate
+		}
+		l.SetRate(rate, p.Burst)
+		i.event(LagEvent{Lag: lag, Rate: rate})
+	})
+}
@@ -0,0 +1,91 @@
+package minimalirc
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+ * limiter.go
+ * Limit how fast lines are sent
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Limiter is a token bucket which limits the rate at which lines are sent.  A Limiter may be shared by several IRC structs (via their Limiter fields) to limit their combined rate.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64   /* Lines per second */
+	burst  float64   /* Maximum tokens */
+	tokens float64   /* Lines which may be sent now */
+	last   time.Time /* When tokens was last updated */
+}
+
+// NewLimiter returns a Limiter which allows rate lines per second on average, with bursts of up to burst lines.  burst is treated as 1 if it is less than 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if 1 > burst {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// SetRate changes the rate and burst of l.
+func (l *Limiter) SetRate(rate float64, burst int) {
+	if 1 > burst {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = rate
+	l.burst = float64(burst)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Rate returns the number of lines per second l allows.
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Wait blocks until a line may be sent.  A Limiter with a rate of 0 or less never blocks.
+func (l *Limiter) Wait() {
+	time.Sleep(l.reserve())
+}
+
+/* reserve takes a token, returning how long to wait before it may be used */
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if 0 >= l.rate {
+		return 0
+	}
+	now := time.Now()
+	l.refill(now)
+	l.tokens--
+	if 0 <= l.tokens {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+/* refill adds the tokens accrued since l.last.  l.mu must be held. */
+func (l *Limiter) refill(now time.Time) {
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		l.last = now
+	}
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
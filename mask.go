@@ -0,0 +1,43 @@
+package minimalirc
+
+/*
+ * mask.go
+ * Hostmask matching
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// MatchMask returns true if s (usually a nick!user@host) matches the IRC-style glob mask, in which * matches any run of characters and ? matches any one character.  Case is folded per RFC1459.
+func MatchMask(mask, s string) bool {
+	return matchFolded([]rune(fold(mask)), []rune(fold(s)))
+}
+
+/* matchFolded does the work for MatchMask */
+func matchFolded(m, s []rune) bool {
+	/* Position to go back to on mismatch after a * */
+	star, sstar := -1, 0
+	mi, si := 0, 0
+	for si < len(s) {
+		switch {
+		case mi < len(m) && ('?' == m[mi] || m[mi] == s[si]):
+			mi++
+			si++
+		case mi < len(m) && '*' == m[mi]:
+			star, sstar = mi, si
+			mi++
+		case -1 != star:
+			/* Let the * eat one more character */
+			sstar++
+			mi, si = star+1, sstar
+		default:
+			return false
+		}
+	}
+	/* Trailing *s match nothing */
+	for mi < len(m) && '*' == m[mi] {
+		mi++
+	}
+	return mi == len(m)
+}
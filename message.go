@@ -0,0 +1,287 @@
+package minimalirc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ * message.go
+ * Parse lines from the server into something usable
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* serverTimeFormat is the format of the time tag */
+const serverTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Message is a line from the server, broken into its parts.  Its JSON encoding is stable; see JSONHandler.
+type Message struct {
+	Raw     string            `json:"raw"`              /* The line as received */
+	Tags    map[string]string `json:"tags,omitempty"`   /* IRCv3 message tags, unescaped */
+	Prefix  string            `json:"prefix,omitempty"` /* Source of the message, without the : */
+	Nick    string            `json:"nick,omitempty"`   /* Nick (or server) from Prefix */
+	User    string            `json:"user,omitempty"`   /* Username from Prefix */
+	Host    string            `json:"host,omitempty"`   /* Host from Prefix */
+	Command string            `json:"command"`          /* Command or numeric, upper-case */
+	Params  []string          `json:"params"`           /* Parameters, the trailing one included */
+}
+
+// ParseMessage splits line into a Message.  It never fails; malformed lines yield a Message with whatever could be parsed.
+func ParseMessage(line string) Message {
+	m := Message{Raw: line}
+	rest := line
+	/* Tags come first */
+	if strings.HasPrefix(rest, "@") {
+		var tags string
+		tags, rest = splitSpace(rest[1:])
+		m.Tags = parseTags(tags)
+	}
+	/* Then the source */
+	if strings.HasPrefix(rest, ":") {
+		m.Prefix, rest = splitSpace(rest[1:])
+		m.Nick, m.User, m.Host = SplitPrefix(m.Prefix)
+	}
+	/* Then the command */
+	var cmd string
+	cmd, rest = splitSpace(rest)
+	m.Command = strings.ToUpper(cmd)
+	/* And finally the parameters */
+	for "" != rest {
+		if strings.HasPrefix(rest, ":") {
+			m.Params = append(m.Params, rest[1:])
+			break
+		}
+		var p string
+		p, rest = splitSpace(rest)
+		m.Params = append(m.Params, p)
+	}
+	return m
+}
+
+// SplitPrefix splits a nick!user@host prefix into its parts.  Parts which aren't present are returned as the empty string.
+func SplitPrefix(prefix string) (nick, user, host string) {
+	nick = prefix
+	if n := strings.IndexByte(nick, '@'); -1 != n {
+		host = nick[n+1:]
+		nick = nick[:n]
+	}
+	if n := strings.IndexByte(nick, '!'); -1 != n {
+		user = nick[n+1:]
+		nick = nick[:n]
+	}
+	return
+}
+
+// String turns m back into a line suitable for sending.  m.Raw is ignored.
+func (m Message) String() string {
+	var b strings.Builder
+	if 0 != len(m.Tags) {
+		b.WriteString("@" + FormatTags(m.Tags) + " ")
+	}
+	if "" != m.Prefix {
+		b.WriteString(":" + m.Prefix + " ")
+	}
+	b.WriteString(m.Command)
+	for n, p := range m.Params {
+		b.WriteByte(' ')
+		/* The last parameter might need to be marked as trailing */
+		if n == len(m.Params)-1 && ("" == p ||
+			strings.HasPrefix(p, ":") ||
+			strings.ContainsRune(p, ' ')) {
+			b.WriteByte(':')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// Param returns the nth parameter of m, or the empty string if m doesn't have that many.
+func (m Message) Param(n int) string {
+	if 0 > n || len(m.Params) <= n {
+		return ""
+	}
+	return m.Params[n]
+}
+
+// Trailing returns the last parameter of m, which for most commands is the human-readable text.
+func (m Message) Trailing() string {
+	return m.Param(len(m.Params) - 1)
+}
+
+// Time returns the time from m's server-time tag, if it has one.
+func (m Message) Time() (time.Time, bool) {
+	v, ok := m.Tags["time"]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(serverTimeFormat, v)
+	if nil != err {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+/* splitSpace returns the part of s before the first spaces, and the rest */
+func splitSpace(s string) (string, string) {
+	n := strings.IndexByte(s, ' ')
+	if -1 == n {
+		return s, ""
+	}
+	return s[:n], strings.TrimLeft(s[n+1:], " ")
+}
+
+/* parseTags parses the tags at the start of a message, sans @ */
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, t := range strings.Split(s, ";") {
+		if "" == t {
+			continue
+		}
+		kv := strings.SplitN(t, "=", 2)
+		/* A value without a key can't be sent back */
+		if "" == kv[0] {
+			continue
+		}
+		if 1 == len(kv) {
+			tags[kv[0]] = ""
+			continue
+		}
+		tags[kv[0]] = unescapeTag(kv[1])
+	}
+	return tags
+}
+
+/* unescapeTag undoes the escaping done to tag values */
+func unescapeTag(v string) string {
+	if -1 == strings.IndexByte(v, '\\') {
+		return v
+	}
+	var b strings.Builder
+	for n := 0; n < len(v); n++ {
+		if '\\' != v[n] {
+			b.WriteByte(v[n])
+			continue
+		}
+		n++
+		if n == len(v) {
+			break
+		}
+		switch v[n] {
+		case ':':
+			b.WriteByte(';')
+		case 's':
+			b.WriteByte(' ')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(v[n])
+		}
+	}
+	return b.String()
+}
+
+/* fold case-folds s per RFC1459, for comparing nicks and channels */
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '[':
+			return '{'
+		case ']':
+			return '}'
+		case '\\':
+			return '|'
+		case '~':
+			return '^'
+		}
+		if 'A' <= r && 'Z' >= r {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// ParseError describes why a line couldn't be parsed by ParseMessageStrict.
+type ParseError struct {
+	Line   string /* The offending line */
+	Reason string /* What was wrong with it */
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("malformed message (%v): %q", e.Reason, e.Line)
+}
+
+// ParseErrorEvent is sent when i.Strict is true and a line from the server can't be parsed.  The line is not processed further, passed to handlers, or sent on i.C.
+type ParseErrorEvent struct {
+	Err *ParseError
+}
+
+func (ParseErrorEvent) event() {}
+
+/* Limits on message sizes */
+const (
+	maxTagsLen = 8191 /* Tags, including the @ and trailing space */
+	maxBodyLen = 510  /* Everything else, sans CRLF */
+)
+
+// ParseMessageStrict is like ParseMessage, but returns a *ParseError if line isn't a well-formed IRC message.  Lines are rejected if they are too long, contain NULs or line breaks, have an empty tag section or prefix, or have a command which is neither letters nor a three-digit numeric.
+func ParseMessageStrict(line string) (Message, error) {
+	m := ParseMessage(line)
+	perr := func(r string) (Message, error) {
+		return m, &ParseError{Line: line, Reason: r}
+	}
+	if strings.ContainsAny(line, "\x00\r\n") {
+		return perr("forbidden character")
+	}
+	/* Split off and check the tags */
+	body := line
+	if strings.HasPrefix(line, "@") {
+		n := strings.IndexByte(line, ' ')
+		if -1 == n {
+			return perr("no command")
+		}
+		if 1 == n {
+			return perr("empty tags")
+		}
+		if n+1 > maxTagsLen {
+			return perr("tags too long")
+		}
+		body = strings.TrimLeft(line[n+1:], " ")
+	}
+	if len(body) > maxBodyLen {
+		return perr("message too long")
+	}
+	if strings.HasPrefix(body, ":") && "" == m.Prefix {
+		return perr("empty prefix")
+	}
+	if !validCommand(m.Command) {
+		return perr("invalid command")
+	}
+	return m, nil
+}
+
+/* validCommand returns true if c is all letters or a three-digit numeric */
+func validCommand(c string) bool {
+	if "" == c {
+		return false
+	}
+	if 3 == len(c) && isDigit(c[0]) && isDigit(c[1]) && isDigit(c[2]) {
+		return true
+	}
+	for n := 0; n < len(c); n++ {
+		if ('A' > c[n] || 'Z' < c[n]) && ('a' > c[n] || 'z' < c[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+/* isDigit returns true if b is an ASCII digit */
+func isDigit(b byte) bool {
+	return '0' <= b && '9' >= b
+}
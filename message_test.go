@@ -0,0 +1,122 @@
+//go:build go1.18
+// +build go1.18
+
+package minimalirc
+
+import (
+	"reflect"
+	"testing"
+)
+
+/*
+ * message_test.go
+ * Fuzz the message parsers
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* fuzzLines seeds the fuzzers with lines like the ones servers send */
+var fuzzLines = []string{
+	"",
+	" ",
+	":",
+	"@",
+	"PING :irc.example.com",
+	"PING",
+	":irc.example.com 001 nick :Welcome to the network, nick",
+	":irc.example.com 005 nick CHANTYPES=# STATUSMSG=@+ :are supported",
+	":nick!user@host PRIVMSG #channel :hello, world",
+	":nick!user@host PRIVMSG #channel ::-)",
+	":nick!user@host PRIVMSG nick :\x01ACTION waves\x01",
+	":nick!user@host NOTICE nick :",
+	":nick!user@host JOIN #channel account :Real Name",
+	":nick!user@host QUIT :*.net *.split",
+	":nick!user@host MODE #channel +ov nick other",
+	"@time=2026-10-16T00:00:00.000Z;msgid=abc :n!u@h PRIVMSG #c :hi",
+	"@a=b\\:c\\sd\\\\e;f;=g :n!u@h TAGMSG #c",
+	"@; :n!u@h TAGMSG #c",
+	"@+typing=active :n!u@h TAGMSG #c",
+	":n!u@h  PRIVMSG   #c   :spaces",
+	": PRIVMSG #c :empty prefix",
+	"privmsg #c lower case",
+	"12 a b c",
+	"PRIVMSG #c :\x00nul",
+	"PRIVMSG #c :line\r\nbreak",
+	/* Found by fuzzing */
+	": :0",
+	"@= A",
+}
+
+/* sameMessage returns true if a and b are the same, apart from Raw */
+func sameMessage(a, b Message) bool {
+	if 0 != len(a.Tags) || 0 != len(b.Tags) {
+		if !reflect.DeepEqual(a.Tags, b.Tags) {
+			return false
+		}
+	}
+	if len(a.Params) != len(b.Params) {
+		return false
+	}
+	for n := range a.Params {
+		if a.Params[n] != b.Params[n] {
+			return false
+		}
+	}
+	return a.Prefix == b.Prefix && a.Nick == b.Nick && a.User == b.User &&
+		a.Host == b.Host && a.Command == b.Command
+}
+
+func FuzzParseMessage(f *testing.F) {
+	for _, l := range fuzzLines {
+		f.Add(l)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		m := ParseMessage(line)
+		if line != m.Raw {
+			t.Fatalf("Raw is %q, not %q", m.Raw, line)
+		}
+		/* Messages with sensible commands should survive being sent
+		back, even if the rest is a mess */
+		if validCommand(m.Command) {
+			s := m.String()
+			if n := ParseMessage(s); !sameMessage(m, n) {
+				t.Fatalf("%q parsed to %#v, but %q to %#v",
+					line, m, s, n)
+			}
+		}
+		/* Accessors shouldn't panic on anything */
+		m.Param(-1)
+		m.Param(len(m.Params))
+		m.Trailing()
+		m.Time()
+	})
+}
+
+func FuzzParseMessageStrict(f *testing.F) {
+	for _, l := range fuzzLines {
+		f.Add(l)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		m, err := ParseMessageStrict(line)
+		if nil != err {
+			if _, ok := err.(*ParseError); !ok {
+				t.Fatalf("error for %q is a %T, not a *ParseError",
+					line, err)
+			}
+			return
+		}
+		/* Anything accepted should survive being sent back */
+		s := m.String()
+		n, err := ParseMessageStrict(s)
+		if nil != err {
+			t.Fatalf("%q accepted, but %q (from String) not: %v",
+				line, s, err)
+		}
+		if !sameMessage(m, n) {
+			t.Fatalf("%q parsed to %#v, but %q to %#v",
+				line, m, s, n)
+		}
+	})
+}
@@ -2,16 +2,20 @@ package minimalirc
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"math/rand"
 	"net"
 	"net/textproto"
+	"regexp"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
-	"unicode"
 )
 
 /*
@@ -51,30 +55,181 @@ type IRC struct {
 	C       <-chan string     /* Messages from the server are sent here */
 	E       <-chan error      /* Receives an error before close(C) */
 	S       net.Conn          /* Represents the connection to the server */
+	rw      io.ReadWriter     /* The connection, as passed to Start */
 	c       chan string       /* Sendable, closable C */
 	e       chan error        /* Sendable E */
 	Msglen  int               /* Size of an IRC message */
 	Default string            /* Default target for privmsgs */
 	rng     *rand.Rand        /* Random number generator */
-	snick   string            /* The server's idea of our nick */
+	rngmu   sync.Mutex        /* Protects rng */
+	wmu     sync.Mutex        /* Serializes writes to w */
+	cmu     sync.Mutex        /* Protects the settings Apply changes */
+	emu     sync.Mutex        /* Protects evq and evbusy */
+	evq     []Event           /* Events waiting for OnEvent */
+	evbusy  bool              /* True while events are being delivered */
+	sq      sendQueue         /* Orders lines waiting to be sent */
+	corks   int               /* Calls to Cork less calls to Uncork */
+	queued  []time.Time       /* When unflushed lines were queued */
+	tmu     sync.Mutex        /* Serializes writes to Transcript */
+	amu     sync.Mutex        /* Serializes writes to Audit */
+	tee     io.Writer         /* Set by TeeTo */
+	teemu   sync.Mutex        /* Protects and serializes writes to tee */
+	mu      sync.Mutex        /* Protects the state below */
+
+	/* Passively-gathered state */
+	snick      string                       /* The server's idea of our nick */
+	splitNicks map[string]splitNick         /* Nicks lost in netsplits */
+	splits     map[[2]string]*gatheredSplit /* Netsplits being gathered */
+	channels   map[string]*channel          /* Channels we're in */
+	myUser     string                       /* Our username, per the server */
+	myHost     string                       /* Our visible host */
+	realnames  map[string]string            /* Others', by fold(nick) */
+	accounts   map[string]string            /* Others', by fold(nick) */
+	state      State                        /* Connection state */
+	closed     bool                         /* True once i.c is closed */
+	caps       map[string]bool              /* ACKed capabilities */
+	offered    map[string]string            /* Server's capabilities */
+	capLS      map[string]string            /* Gathered from a CAP LS */
+	capReqs    int                          /* CAP REQs not answered */
+	sasl       *saslState                   /* SASL login in progress */
+	scramBad   bool                         /* Server failed SCRAM */
+	marks      map[string]HistoryMark       /* Last message per target */
+	activity   map[string]*activity         /* Said, by fold(channel) */
+	handlers   []handler                    /* Called for each message */
+	jobs       chan handlerJob              /* For handler workers */
+	ordered    chan handlerJob              /* For the ordered worker */
+	ctx        context.Context              /* Cancelled on disconnect */
+	cancel     context.CancelFunc           /* Cancels ctx */
+	counter    counter                      /* Traffic statistics */
+	commands   map[string]CommandFunc       /* Bot commands, by name */
+	subcmds    map[string]CommandFunc       /* By "command sub" */
+	cmdLimits  map[string]CommandLimit      /* Set by LimitCommand */
+	cmdBuckets map[cmdBucketKey]*cmdBucket  /* Senders' recent commands */
+	cmdRoles   map[string]Role              /* Set by RequireRole */
+	grants     roleGrants                   /* Roles we've given out */
+	roleLoaded bool                         /* Loaded i.Store's grants */
+	seenTags   map[string]time.Time         /* Seen command HMAC tags */
+	intercepts map[string]Interceptor       /* Per-target Interceptors */
+	ctcpSeen   map[string][]time.Time       /* Recent CTCPs, by host */
+	ignMasks   []string                     /* Ignored hostmasks */
+	ignREs     []*regexp.Regexp             /* Ignored lines */
+	highlights []highlight                  /* Words to notice */
+	responses  []*response                  /* Set by SetResponses */
+	waiters    []*waiter                    /* Waiting for replies */
+	sched      []*scheduled                 /* Messages to send later */
+	schedTimer *time.Timer                  /* Fires for the next one */
+	tasks      []*task                      /* Run while registered */
+	idles      []*idle                      /* Run when it's quiet */
+	routes     map[routeKey]chan Message    /* ChannelC and QueryC */
+	queries    []*Query                     /* Open queries */
+	unechoed   map[string][]unechoed        /* Sent, by fold(target) */
+	umodes     map[byte]bool                /* Our user modes */
+	watched    map[string]string            /* Watched nicks, by fold(nick) */
+	presence   map[string]bool              /* Watched nicks online */
+	motdDone   bool                         /* Seen the end of the MOTD */
+	offline    map[string][]string          /* Queued by Deliver */
+	offWatch   map[string]bool              /* Watched for Deliver */
+	offLoaded  bool                         /* Loaded i.Store's queue */
+	sent       map[string][]SentMessage     /* Echoed, by fold(target) */
+	byID       map[string]Message           /* Received, by msgid */
+	idRing     []string                     /* Order of byID's msgids */
+	idNext     int                          /* Next slot in idRing */
+	isupport   map[string]string            /* ISUPPORT tokens */
+	nicklen    int                          /* Last NICKLEN seen */
+	lag        time.Duration                /* Last measured lag */
+	identified bool                         /* Services say we're in */
+	cloaked    bool                         /* CloakMode is set */
+	certs      []*x509.Certificate          /* Last server cert chain */
 
 	/* Configs and defauls.  These may be changed at any time. */
 	Host          string /* Host to which to connect */
 	Port          uint16 /* Port to which to connect */
 	Ssl           bool   /* True to use SSL/TLS */
 	Hostname      string /* Hostname to verify on server's certificate */
+	LocalAddr     string /* Local IP address from which to connect */
+	Network       string /* tcp4 or tcp6 to use only IPv4 or IPv6 */
+	LookupSRV     bool   /* Find servers with Host's SRV records */
 	Nick          string /* For NICK */
 	Username      string /* For USER */
+	UserMode      string /* For USER, RFC2812 mode bits, "x" if unset */
+	UserUnused    string /* For USER, unused parameter, "x" if unset */
 	Realname      string /* For USER */
 	IdNick        string /* To auth to NickServ */
 	IdPass        string /* To auth to NickServ */
+	SASL          bool   /* Log in with SASL, not Auth, if we can */
 	Channel       string /* For JOIN */
 	Chanpass      string /* For JOIN */
 	Txp           string /* Prefix for logging sent messages */
 	Rxp           string /* Prefix for logging received messages */
+	LogSecrets    bool   /* Don't redact passwords logged via Txp/Rxp */
+	Name          string /* Identifies us in logs, errors, and Health */
+	Identd        string /* Address for an identd during Connect */
 	Pongs         bool   /* Automatic ping responses */
 	RandomNumbers bool   /* Append random numbers to the nick */
 	QuitMessage   string /* Message to send when the client QUITs */
+	GuardChannels bool   /* Privmsg errors if not in the channel */
+	AutoRejoin    bool   /* Privmsg JOINs the channel if not in it */
+	Invisible     bool   /* Send MODE <nick> +i after registration */
+	Strict        bool   /* Drop malformed lines from the server */
+	ResumeHistory bool   /* Request missed messages on JOIN */
+	PassiveMode   bool   /* Never send anything not asked for */
+	TrackActivity bool   /* Count what's said, for ChannelActivity */
+
+	AutoJoinOnInvite bool             /* JOIN channels to which we're INVITEd */
+	InviteAllow      []string         /* Masks allowed to invite, e.g. * for all */
+	Caps             []string         /* IRCv3 capabilities to request */
+	MaxBytesPerSec   int              /* Throttle sent bytes per second, if >0 */
+	JitterMin        time.Duration    /* Minimum random delay before sending */
+	JitterMax        time.Duration    /* Maximum random delay, none if 0 */
+	EnvelopeKey      []byte           /* Key for PrivmsgEncrypted */
+	CommandPrefix    string           /* Bot command prefix, "!" if unset */
+	CommandKey       []byte           /* Key to authenticate commands */
+	CommandWindow    time.Duration    /* Max age of authenticated commands */
+	CommandLimit     CommandLimit     /* Limits each sender's commands */
+	CTCPReplies      bool             /* Answer CTCP VERSION, PING, etc. */
+	CTCPVersion      string           /* CTCP VERSION reply */
+	CTCPClientInfo   string           /* CTCP CLIENTINFO reply */
+	CTCPFloodCount   int              /* Max CTCPs per host per window */
+	CTCPFloodWindow  time.Duration    /* Window for CTCPFloodCount */
+	CTCPFloodTotal   int              /* Max CTCPs from anybody per window */
+	HighlightNick    bool             /* HighlightEvents for our nick */
+	Limiter          *Limiter         /* Limits lines sent, if not nil */
+	QuitLinger       time.Duration    /* Time Quit waits for the server */
+	TrackLatency     bool             /* Keep Stats.SendLatency */
+	NickLen          int              /* Max nick length before NICKLEN */
+	CloakMode        string           /* User mode to set for a cloak, e.g. x */
+	Services         Services         /* Services for Auth, NickServ if nil */
+	Transcript       io.Writer        /* Records the session, see Replay */
+	Audit            io.Writer        /* Records what we did, sans secrets */
+	ResendTruncated  bool             /* Resend text the server cut off */
+	Offline          OfflineMode      /* Deliver's handling of offline nicks */
+	Fingerprints     []Fingerprint    /* ID picks one at random, if set */
+	DialTimeout      time.Duration    /* Max time to connect, a minute if 0 */
+	FallbackDelay    time.Duration    /* Delay before trying IPv4, 300ms if 0 */
+	RequestTimeout   time.Duration    /* Max wait for replies, 30s if 0 */
+	ClientCert       *tls.Certificate /* Sent when i.Ssl is set, for CertFP */
+	CertWarning      time.Duration    /* Warn of cert expiry, 2 weeks if 0 */
+	Store            Store            /* Keeps things across restarts */
+	HandlerWorkers   int              /* Goroutines to run handlers, if >0 */
+	HandlerQueue     int              /* Handler calls waiting for workers */
+	HandlerOverflow  OverflowPolicy   /* When HandlerQueue's full */
+	TeeTimestamps    bool             /* Timestamp lines copied by TeeTo */
+	ActivityWindow   time.Duration    /* Longest ChannelActivity window, 1h if 0 */
+
+	/* NickFunc, if set, is called by ID with i.Nick to make the nick to
+	send to the server, in place of RandomNumbers */
+	NickFunc func(nick string) string
+
+	/* TranslateReply, if set, is called by Command's Reply methods with
+	the format they're given, and returns the format to use, e.g. one in
+	the sender's language */
+	TranslateReply func(c *Command, format string) string
+
+	/* Callbacks.  OnEvent is called with one event at a time, in the order
+	they're sent, usually from the goroutine reading from the server, but
+	sometimes from timers or handler workers; events sent while it's
+	running are delivered after it returns.  It should return quickly. */
+	OnEvent func(e Event) /* Receives events, if not nil */
 }
 
 // New allocates, initializes, and returns a pointer to a new IRC struct.  hostname will be ignored if ssl is false, or assumed to be the same as host if it is the empty string and ssl is true.
@@ -105,128 +260,340 @@ func New(host string, port uint16, ssl bool, hostname string,
 	return i
 }
 
-// Connect connects to the server, and calls Handshake().  After connect returns, messages sent by the IRC server will be available on i.C.  If i.Rxp is set, received messages from the server will be logged via log.Printf prefixed by i.Rxp, separated by a space.  If an error is encountered reading messages from the IRC server, i.C will be closed and the error will be sent on i.E.  i.S represents the connection to the server.
+// Connect connects to the server, and calls Handshake().  After connect returns, messages sent by the IRC server will be available on i.C.  If i.Rxp is set, received messages from the server will be logged via log.Printf prefixed by i.Rxp, separated by a space, with passwords redacted as with i.Txp.  If an error is encountered reading messages from the IRC server (or the library panics while handling a message), i.C will be closed and the error will be sent on i.E.  i.S represents the connection to the server.  Connect may be called again after i.C is closed to reconnect, in which case i.C and i.E are replaced.  If i.Identd is set (e.g. to ":113"), an ident server is started on that address before connecting, which answers the server's query about our connection with i.Username and then stops, or stops after a minute; failing to start it is sent as an ErrorEvent.  If i.LocalAddr is set, the connection is made from that address, which is useful on hosts with more than one (e.g. for a vhost).  i.Network may be set to tcp4 or tcp6 to connect only over IPv4 or IPv6.  Otherwise, if the server has both IPv6 and IPv4 addresses, they're tried in parallel, with the first family returned by the resolver (usually IPv6) given a head start of i.FallbackDelay (300ms if 0, or no fallback if negative).  If i.LookupSRV is set, SRV records for _ircs._tcp.<i.Host> (or _irc._tcp if i.Ssl isn't set) are looked up, and the servers they name are tried in order of priority and weight, before i.Host and i.Port; their certificates are still checked against i.Hostname.  Each address a server resolves to is tried in turn, with i.DialTimeout (a minute if 0) shared between them, so an unreachable address doesn't stop the others being tried.  With TLS, the server's certificate chain is kept for ServerCerts, and a CertEvent is sent if it's changed since the last connection or expires within i.CertWarning.
 func (i *IRC) Connect() error {
-	/* Dial the server */
-	h := net.JoinHostPort(i.Host, fmt.Sprintf("%v", i.Port))
-	if i.Ssl { /* SSL requested */
+	i.setState(Connecting)
+	/* Some servers won't let us in without an ident */
+	var id *identd
+	if "" != i.Identd {
 		var err error
-		i.S, err = tls.Dial("tcp", h,
-			&tls.Config{ServerName: i.Hostname})
-		if nil != err {
-			return errors.New(fmt.Sprintf("unable to make ssl "+
-				"connection to %v: %v", h, err))
-		}
-	} else { /* Plaintext connection */
-		var err error
-		i.S, err = net.Dial("tcp", h)
-		if nil != err {
-			return errors.New(fmt.Sprintf("unable to make "+
-				"plaintext connection to %v: %v", h, err))
+		if id, err = i.startIdentd(); nil != err {
+			i.event(ErrorEvent{Err: err})
+		} else {
+			defer func() {
+				if Disconnected == i.State() {
+					id.stop()
+				}
+			}()
 		}
 	}
+	/* Dial the server */
+	conn, err := i.dialServers()
+	if nil != err {
+		i.setState(Disconnected)
+		return i.named(err)
+	}
+	if nil != id {
+		id.connected(conn)
+	}
+	i.checkCerts(conn)
+	return i.Start(conn)
+}
 
-	/* Make a reader and a writer */
-	i.r = textproto.NewReader(bufio.NewReader(i.S))
-	i.w = textproto.NewWriter(bufio.NewWriter(i.S))
+// Start is like Connect, but uses rw as the connection to the server instead of dialing.  This is useful for replaying recorded server transcripts, or for connections made some other way.  If rw is a net.Conn it will be available as i.S, otherwise i.S will be nil.  If rw is an io.Closer, it will be closed when reading from it fails or on Quit.
+func (i *IRC) Start(rw io.ReadWriter) error {
+	i.use(rw)
 
 	/* Send nick and user */
 	if err := i.Handshake(); nil != err {
-		return errors.New(fmt.Sprintf("unable to handshake: %v", err))
+		i.closeConn()
+		i.setState(Disconnected)
+		return i.named(errors.New(fmt.Sprintf("unable to handshake: "+
+			"%v", err)))
 	}
 
 	/* Start reads from server into channel */
-	go func() {
-		/* Close the connection when we're done with it */
-		defer i.S.Close()
-		for {
-			/* Get a line from the reader */
-			line, err := i.r.ReadLine()
-			/* Close the channel on error */
-			if nil != err {
-				i.e <- err
-				close(i.c)
-				return
-			}
-			/* Log the line if needed */
-			if "" != i.Rxp {
-				log.Printf("%v %v", i.Rxp, line)
-			}
-			/* Handle pings if desired */
-			if i.Pongs && strings.HasPrefix(strings.ToLower(line),
-				"ping ") {
-				/* Try to send pong */
-				err := i.PrintfLine("PONG %v",
-					strings.SplitN(line, " ", 2)[1])
-				/* A send error is as bad as a read error */
-				if nil != err {
-					i.e <- err
-					close(i.c)
-					return
-				}
-			}
-			/* Maybe get a nick */
-			parts := strings.SplitN(line, " ", 4)
-			/* If the 2nd bit is a 3-digit number, the 3rd bit is
-			our nick */
-			if 4 == len(parts) {
-				n := []rune(parts[1])
-				if 3 == len(n) &&
-					unicode.IsDigit(n[0]) &&
-					unicode.IsDigit(n[1]) &&
-					unicode.IsDigit(n[2]) {
-					i.snick = parts[2]
-				}
-			}
+	go i.readLoop()
+	return nil
+}
 
-			/* Send out the line */
-			i.c <- line
+// use sets up i to use rw as its connection to the server, forgetting
+// anything learned on the last connection
+func (i *IRC) use(rw io.ReadWriter) {
+	/* New context for the new connection, and new channels if the last
+	connection's are closed */
+	i.mu.Lock()
+	if nil != i.cancel {
+		i.cancel()
+	}
+	i.ctx, i.cancel = context.WithCancel(context.Background())
+	/* The old workers stop with the old context */
+	i.jobs = nil
+	i.ordered = nil
+	if i.closed {
+		i.c = make(chan string)
+		i.C = i.c
+		i.e = make(chan error, 1)
+		i.E = i.e
+		i.closed = false
+	}
+	i.mu.Unlock()
+
+	/* Keep hold of the connection */
+	i.rw = rw
+	i.S, _ = rw.(net.Conn)
+
+	/* Forget what we learned on the last connection */
+	i.mu.Lock()
+	i.channels = nil
+	i.caps = nil
+	i.offered = nil
+	i.capLS = nil
+	i.capReqs = 0
+	i.sasl = nil
+	i.scramBad = false
+	i.isupport = nil
+	i.unechoed = nil
+	i.umodes = nil
+	i.presence = nil
+	i.motdDone = false
+	i.identified = false
+	i.cloaked = false
+	i.myUser = ""
+	i.myHost = ""
+	i.realnames = nil
+	i.accounts = nil
+	i.mu.Unlock()
+
+	/* Make a reader and a writer, counting what goes through them */
+	i.resetStats()
+	m := meter{rw: rw, i: i}
+	i.r = textproto.NewReader(bufio.NewReader(m))
+	i.w = textproto.NewWriter(bufio.NewWriter(m))
+	i.setState(Connected)
+}
+
+/* closeConn closes the connection to the server, if it can be closed */
+func (i *IRC) closeConn() error {
+	if c, ok := i.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// readLoop reads lines from the server and sends them on i.c.  If reading
+// fails or a panic occurs, the error is sent on i.e and i.c is closed.
+func (i *IRC) readLoop() {
+	/* Close the connection when we're done with it */
+	defer i.closeConn()
+	/* Report panics rather than silently dying */
+	defer func() {
+		if r := recover(); nil != r {
+			i.fail(&PanicError{Value: r, Stack: debug.Stack()})
 		}
 	}()
-	return nil
+	for {
+		/* Get a line from the reader */
+		line, err := i.r.ReadLine()
+		/* Close the channel on error */
+		if nil != err {
+			i.fail(err)
+			return
+		}
+		/* Work out what to do with it */
+		i.countLine(false)
+		line, ok, err := i.handleLine(line)
+		if nil != err {
+			i.fail(err)
+			return
+		}
+		/* Send out the line, if it's not been rejected */
+		if ok {
+			i.c <- line
+		}
+	}
+}
+
+// handleLine does the library's processing of a line from the server.  It
+// returns the line to send on i.c, which may have been modified by an
+// Interceptor, false if the line should not be sent on i.c, and an error if
+// the connection is no longer usable.
+func (i *IRC) handleLine(line string) (string, bool, error) {
+	/* Copy the line as-is, if asked */
+	i.teeLine(line)
+	/* Log the line if needed */
+	if _, rxp, _ := i.logSettings(); "" != rxp {
+		i.logf("%v %v", rxp, i.logLine(line))
+	}
+	i.record(false, line)
+	/* Netsplits are sent with whichever line comes next */
+	i.flushSplits()
+	/* Most chatter needs no more than that */
+	if i.uninteresting(line) {
+		return line, true, nil
+	}
+	/* Parse the line, making sure it's sensible if we're being strict */
+	m := ParseMessage(line)
+	if i.Strict {
+		var err error
+		if m, err = ParseMessageStrict(line); nil != err {
+			i.event(ParseErrorEvent{Err: err.(*ParseError)})
+			return line, false, nil
+		}
+	}
+	/* Echoes of our messages are compared as sent, before decryption */
+	i.checkEcho(m)
+	/* Give interceptors (e.g. decryption) a go at PRIVMSGs */
+	if n, ok := i.intercept(m); ok {
+		m = n
+		line = m.String()
+	}
+	/* Handle pings if desired */
+	if i.Pongs && !i.PassiveMode && "PING" == m.Command &&
+		"" == m.Prefix && nil == m.Tags {
+		/* Try to send pong, a send error is as bad as a read error.
+		Some servers send a bare PING. */
+		pong := "PONG"
+		if 0 != len(m.Params) {
+			pong += " :" + m.Trailing()
+		}
+		if err := i.PrintfLine("%s", pong); nil != err {
+			return line, false, err
+		}
+	}
+	/* Numerics from the server are addressed to our nick */
+	if 3 == len(m.Command) && isDigit(m.Command[0]) &&
+		isDigit(m.Command[1]) && isDigit(m.Command[2]) &&
+		2 <= len(m.Params) {
+		i.mu.Lock()
+		i.snick = m.Params[0]
+		i.mu.Unlock()
+	}
+
+	/* Keep track of goings-on, noting which channels QUITs and NICKs
+	affect before they're no longer tracked */
+	var affected []string
+	if "QUIT" == m.Command || "NICK" == m.Command {
+		affected = i.CommonChannels(m.Nick)
+	}
+	i.trackCaps(m)
+	i.trackSASL(m)
+	i.trackISupport(m)
+	i.trackNickLen(m)
+	i.trackRegistration(m)
+	i.trackChannels(m)
+	i.trackHostmask(m)
+	i.trackRealnames(m)
+	i.trackAccounts(m)
+	i.trackUserModes(m)
+	i.trackPresence(m)
+	i.trackOffline(m)
+	i.watchSplits(m)
+	i.trackHistory(m)
+	i.trackActivity(m)
+	i.trackSent(m)
+	i.trackMsgIDs(m)
+	i.trackAuth(m)
+	i.trackQueries(m)
+	i.feedWaiters(m)
+
+	/* Don't go any further with messages from the ignored */
+	if i.ignored(m) {
+		return line, false, nil
+	}
+	i.handleInvite(m)
+	i.handleKnock(m)
+	i.handleSilence(m)
+	i.handleTagmsg(m)
+	i.handleReaction(m)
+	i.handleRedact(m)
+	i.handleEnvelope(m)
+	i.handleChallenge(m)
+	i.handleCTCP(m)
+	i.handleHighlight(m)
+	i.handleResponses(m)
+	i.route(m)
+	i.routeQueries(m)
+
+	/* Let the user have a go, if there's a user to have a go */
+	if i.listening() {
+		ctx := i.messageContext(m, affected)
+		i.dispatch(ctx, m)
+		i.runCommand(ctx, m)
+	}
+	return line, true, nil
 }
 
-// ID sets the nick and user from the values in i, and sends a NICK command without any parameters (to get an easy-to-parse response with the nick as the server knows it).  If i.Nick, i.Username or i.Realname are the empty string, this is a no-op.
+// fail sends err on i.e, closes i.c, cancels the handlers' context, and notes
+// that we're disconnected.  Only the first call per connection has any effect.
+func (i *IRC) fail(err error) {
+	i.mu.Lock()
+	if i.closed {
+		i.mu.Unlock()
+		return
+	}
+	i.closed = true
+	i.cancel()
+	i.mu.Unlock()
+	i.audit(AuditClose, "%v", err)
+	i.e <- i.named(err)
+	close(i.c)
+	i.setState(Disconnected)
+}
+
+// ID sets the nick and user from the values in i, and sends a NICK command without any parameters (to get an easy-to-parse response with the nick as the server knows it).  If i.Caps isn't empty or i.SASL is set, capability negotiation is started first; the capabilities are requested when the server lists them.  If i.Nick, i.Username or i.Realname are the empty string, this is a no-op.  The mode and unused parameters to USER are taken from i.UserMode and i.UserUnused, per RFC2812.  i.UserMode is a bitmask; 8 requests +i and 4 requests +w, though not all servers honor it (see i.Invisible).  If i.Fingerprints isn't empty, RandomFingerprint is called with it first.  If i.NickFunc is set, it's called with i.Nick to make the nick to send, otherwise if i.RandomNumbers is set, random numbers are appended.  The nick is shortened to the server's NICKLEN if it's known from a previous connection, or i.NickLen if not, and SNick returns it until the server says otherwise.
 func (i *IRC) ID() error {
-	if "" == i.Nick || "" == i.Username || "" == i.Realname {
+	/* Look like someone else, if asked */
+	i.RandomFingerprint(i.Fingerprints)
+	if "" == i.configNick() || "" == i.Username || "" == i.Realname {
 		return nil
 	}
-	/* Add some numbers to the nick */
-	nick := i.Nick
-	if i.RandomNumbers {
-		nick = fmt.Sprintf("%v-%v", nick, i.rng.Int63())
-	}
+	/* Add some numbers to the nick, or let the user make it, making sure
+	the server won't have to shorten it, so we know what it is */
+	nick := i.makeNick(false)
+	i.mu.Lock()
+	i.snick = nick
+	i.mu.Unlock()
+	/* Mode and unused USER parameters */
+	fp := i.Fingerprint()
 	/* Iterate over the commands to send */
-	for _, line := range []string{
+	lines := []string{
 		fmt.Sprintf("NICK :%v", nick),
-		fmt.Sprintf("USER %v x x :%v", i.Username, i.Realname),
+		fmt.Sprintf("USER %v %v %v :%v", i.Username, fp.UserMode,
+			fp.UserUnused, i.Realname),
 		"NICK",
-	} {
+	}
+	/* Capability negotiation has to start before registration */
+	if 0 != len(i.wantedCaps()) {
+		lines = append([]string{"CAP LS 302"}, lines...)
+	}
+	/* Send them all at once */
+	i.Cork()
+	for _, line := range lines {
 		/* Try to send the line */
-		if err := i.PrintfLine(line); nil != err {
+		if err := i.PrintfLine("%s", line); nil != err {
+			i.Uncork()
 			return errors.New(fmt.Sprintf("error sending ID "+
 				"line %v: %v", line, err))
 		}
 	}
+	if err := i.Uncork(); nil != err {
+		return errors.New(fmt.Sprintf("error sending ID lines: %v",
+			err))
+	}
 	return nil
 }
 
-// Auth authenticates to NickServ with the values in i.  If either i.IdNick or i.IdPass are the empty string, this is a no-op.
+// Auth authenticates to NickServ, or i.Services if it's set, with the values in i.  If either i.IdNick or i.IdPass are the empty string, this is a no-op.  If i.Services is a Challenger, a challenge is requested and answered instead of sending the password.  NickServ's reply is sent as an AuthResultEvent, and Identified reports whether it worked.
 func (i *IRC) Auth() error {
 	/* Don't auth with blank creds */
-	if "" == i.IdNick || "" == i.IdPass {
+	idNick, idPass := i.creds()
+	if "" == idNick || "" == idPass {
 		return nil
 	}
-	l := fmt.Sprintf("PRIVMSG NickServ :identify %v %v", i.IdNick,
-		i.IdPass)
-	if err := i.PrintfLine(l); nil != err {
+	i.audit(AuditAuth, "%v as %v", i.services().Bot(), idNick)
+	l := i.services().IdentifyLine(idNick, idPass)
+	if c, ok := i.services().(Challenger); ok {
+		l = c.ChallengeLine()
+	}
+	if err := i.PrintfLine("%s", l); nil != err {
 		return errors.New(fmt.Sprintf("error authenticating to "+
 			"services: %v", err))
 	}
 	return nil
 }
 
-// Join joins the channel with the optional password (which may be the empty string).  If the channel is the empty string, the value from i.Channel and i.Chanpass will be used.  If channel and i.Channel are both the empty string, this is a no-op.
+// Join joins the channel with the optional password (which may be the empty string).  If the channel is the empty string, the value from i.Channel and i.Chanpass will be used.  If channel and i.Channel are both the empty string, this is a no-op.  channel may be a comma-separated list of channels, with a comma-separated list of passwords, in which case it's handled by JoinMany.
 func (i *IRC) Join(channel, pass string) error {
 	/* If not specified, try the channel in i */
 	if "" == channel {
@@ -237,24 +604,31 @@ func (i *IRC) Join(channel, pass string) error {
 	if "" == channel {
 		return nil
 	}
+	/* Lists of channels may need splitting up */
+	if strings.Contains(channel, ",") {
+		return i.JoinMany(strings.Split(channel, ","),
+			strings.Split(pass, ","))
+	}
 	l := fmt.Sprintf("JOIN %v %v", channel, pass)
-	if err := i.PrintfLine(l); nil != err {
+	if err := i.PrintfLine("%s", l); nil != err {
 		return errors.New(fmt.Sprintf("error joining %v: %v",
 			channel, err))
 	}
 	return nil
 }
 
-// Handshake is a shorthand for ID, Auth, and Join, in that order, using the values in i.
+// Handshake is a shorthand for ID, Auth, and Join, in that order, using the values in i.  If i.SASL is set, Auth isn't called; instead, during capability negotiation, we log in with SASL EXTERNAL if i.ClientCert is set, or SCRAM-SHA-256 or PLAIN with i.IdNick and i.IdPass, trying the next if the server says one failed, and sending each result as a SASLEvent.  PLAIN isn't tried if the server lists SCRAM-SHA-256.  A server asking for more than 65536 SCRAM-SHA-256 iterations is refused, as it would take too long.  If none works or the server doesn't offer SASL, Auth is called after registration.  If the server can't prove it knows the password during SCRAM-SHA-256, an ErrorEvent with ErrSCRAMServer is sent and the password isn't sent at all.
 func (i *IRC) Handshake() error {
 	/* Set nick and user */
 	if err := i.ID(); nil != err {
 		return errors.New(fmt.Sprintf("handshake error (ID): %v", err))
 	}
-	/* Auth to services */
-	if err := i.Auth(); err != nil {
-		return errors.New(fmt.Sprintf("handshake error (Auth): %v",
-			err))
+	/* Auth to services, unless SASL will do it */
+	if !i.SASL {
+		if err := i.Auth(); err != nil {
+			return errors.New(fmt.Sprintf("handshake error "+
+				"(Auth): %v", err))
+		}
 	}
 	/* Join the channel */
 	if err := i.Join("", ""); err != nil {
@@ -264,21 +638,57 @@ func (i *IRC) Handshake() error {
 	return nil
 }
 
-// PrintfLine sends the formatted string to the IRC server.  The message should be a raw IRC protocol message (like WHOIS or CAP).  It is not wrapped in PRIVMSG or anything else.  For PRIVMSGs, see Privmsg  .If i.Txp is not the empty string, successfully sent lines will be logged via log.Printf() prefixed by i.Txp, separated by a space.  Note that all the functions used to send protocol messages use PrintfLine.
+// PrintfLine sends the formatted string to the IRC server.  The message should be a raw IRC protocol message (like WHOIS or CAP).  It is not wrapped in PRIVMSG or anything else.  For PRIVMSGs, see Privmsg  .If i.Txp is not the empty string, successfully sent lines will be logged via log.Printf() prefixed by i.Txp, separated by a space, with passwords (e.g. in PASS, AUTHENTICATE, and NickServ IDENTIFY) replaced by <redacted> unless i.LogSecrets is true.  Note that all the functions used to send protocol messages use PrintfLine.  Lines are sent immediately unless i.Cork has been called.  If i.Limiter is set, PrintfLine waits until it allows a line to be sent.  While lines are waiting, they're sent in order of Priority: PRIVMSGs, NOTICEs, and TAGMSGs are sent with PriorityReply, after anything else waiting (PriorityControl), so PONGs and the like aren't held up.  PrintfLinePriority, PrivmsgBulk, and SendBinary send with other priorities.
 func (i *IRC) PrintfLine(f string, args ...interface{}) error {
-	/* Form the line into a string */
 	line := fmt.Sprintf(f, args...)
+	return i.sendLine(linePriority(line), line)
+}
+
+/* sendLine sends line when it's the turn of lines with priority p */
+func (i *IRC) sendLine(p Priority, line string) error {
+	queued := time.Now()
+	/* Let more important lines go first */
+	flushed, err := i.sq.acquire(p, line)
+	if nil != err {
+		return err
+	}
+	defer i.sq.release()
+	/* One line at a time */
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+	/* Wait our turn, if we're limited and not in a hurry */
+	if nil != i.Limiter && !flushed {
+		i.Limiter.Wait()
+	}
+	/* Wait a bit, if we're being unpredictable */
+	if 0 < i.JitterMax && !flushed {
+		time.Sleep(i.jitter())
+	}
 	/* Try to send the line */
-	if err := i.w.PrintfLine(line); err != nil {
+	if err := i.writeLine(line, queued); err != nil {
 		return err
 	}
+	i.countLine(true)
 	/* Log if desired */
 	if "" != i.Txp {
-		log.Printf("%v %v", i.Txp, line)
+		i.logf("%v %v", i.Txp, i.logLine(line))
 	}
+	i.record(true, line)
+	i.audit(AuditSend, "%s", line)
 	return nil
 }
 
+/* jitter returns a random duration between i.JitterMin and i.JitterMax */
+func (i *IRC) jitter() time.Duration {
+	min, max := i.JitterMin, i.JitterMax
+	if min >= max {
+		return min
+	}
+	i.rngmu.Lock()
+	defer i.rngmu.Unlock()
+	return min + time.Duration(i.rng.Int63n(int64(max-min)))
+}
+
 // Target returns a target suitable for use in Privmsg, or "" if there is none.
 func (i *IRC) target(target string) string {
 	/* Use the default target if none was given */
@@ -296,33 +706,90 @@ func (i *IRC) target(target string) string {
 	return target
 }
 
-// Privmsg sends a PRIVMSG to the target, which may be a nick or a channel.  If the target is an empty string, the message will be sent to i.Target, unless that is also an empty string, in which case nothing is sent.
+// Privmsg sends a PRIVMSG to the target, which may be a nick or a channel.  If the target is an empty string, the message will be sent to i.Target, unless that is also an empty string, in which case nothing is sent.  If the target is a channel we're not in, and i.AutoRejoin is true, the channel will be JOINed first, otherwise if i.GuardChannels is true a *NotInChannelError is returned.  The target may be a comma-separated list, in which case the message is sent with Broadcast.  A channel may be preceded by membership prefixes from StatusPrefixes (e.g. @#channel) to send only to the channel's members with that status; if the server doesn't support the prefix, an *UnsupportedStatusError is returned.
 func (i *IRC) Privmsg(msg, target string) error {
+	return i.privmsg(PriorityReply, msg, target)
+}
+
+// PrivmsgBulk is like Privmsg, but sends with PriorityBulk, so that if lines are waiting to be sent, everything else goes first.  It's meant for long pastes and other output nobody's waiting on.
+func (i *IRC) PrivmsgBulk(msg, target string) error {
+	return i.privmsg(PriorityBulk, msg, target)
+}
+
+/* privmsg is Privmsg, sending with priority p */
+func (i *IRC) privmsg(p Priority, msg, target string) error {
+	/* Get the target */
+	t := i.target(target)
+	if "" == t {
+		return nil
+	}
+	/* Lists of targets may need splitting up */
+	if strings.Contains(t, ",") {
+		return i.broadcast(p, msg, strings.Split(t, ","))
+	}
+	/* Make sure we're in the channel */
+	if err := i.guard(t); nil != err {
+		return err
+	}
+	/* Encrypt or whatever */
+	msg, err := i.interceptOut(t, msg)
+	if nil != err {
+		return err
+	}
+	/* Send the message, noting it first in case the echo's quick */
+	i.expectEcho("PRIVMSG", t, msg)
+	return i.PrintfLinePriority(p, "PRIVMSG %v :%v", t, msg)
+}
+
+// Notice sends a NOTICE to the target.  Target is handled as in Privmsg.
+func (i *IRC) Notice(msg, target string) error {
 	/* Get the target */
 	t := i.target(target)
 	if "" == t {
 		return nil
 	}
-	/* Send the message */
-	return i.PrintfLine("PRIVMSG %v :%v", t, msg)
+	/* Make sure we're in the channel */
+	if err := i.guard(t); nil != err {
+		return err
+	}
+	/* Send the message, noting it first in case the echo's quick */
+	i.expectEcho("NOTICE", t, msg)
+	return i.PrintfLine("NOTICE %v :%v", t, msg)
 }
 
-// PrivmsgSize returns the length of the message that can be shoved into a PRIVMSG to the target.  i.Msglen may be changed to override the default size of an IRC message (467 bytes, determined experimentally on freenode, 510 should be it, though).  See Privmsg for the meaning of target.
+// PrivmsgSize returns the length of the message that can be shoved into a PRIVMSG to the target.  i.Msglen may be changed to override the default size of an IRC message (467 bytes, determined experimentally on freenode, 510 should be it, though).  Once our hostmask is known (see Hostmask), the size is further limited to what fits in the 510 bytes the server relays with our hostmask prepended.  See Privmsg for the meaning of target.
 func (i *IRC) PrivmsgSize(target string) int {
 	/* Get the target */
 	t := i.target(target)
 	if "" == t {
 		return -1
 	}
-	return i.Msglen - len([]byte(fmt.Sprintf("PRIVMSG %v :", t)))
+	n := i.Msglen - len([]byte(fmt.Sprintf("PRIVMSG %v :", t)))
+	/* Account for the hostmask the server will add */
+	if m, ok := i.MeasuredPrivmsgSize(t); ok && m < n {
+		n = m
+	}
+	return n
+}
+
+// MeasuredPrivmsgSize returns the exact length of the message which fits in a PRIVMSG to the target, as relayed by the server with our hostmask prepended, and true.  If our hostmask isn't known (it's asked for with USERHOST after registration), it returns -1 and false.  Unlike PrivmsgSize, i.Msglen isn't taken into account.  See Privmsg for the meaning of target.
+func (i *IRC) MeasuredPrivmsgSize(target string) (int, bool) {
+	t := i.target(target)
+	hm := i.Hostmask()
+	if "" == t || "" == hm {
+		return -1, false
+	}
+	return 510 - len(":"+hm+" ") - len("PRIVMSG "+t+" :"), true
 }
 
 // Nick returns a guess as to what the server thinks the nick is.  This is handy for servers that truncate nicks when RandomNumbers is true.  This is, however, only a guess (albiet a good one).  It should be called after setting the nick with Nick() or Handshake().  Note this is based on passive inspection of received messagess, which requires reading due to the read channel being unbuffered. */
 func (i *IRC) SNick() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	return i.snick
 }
 
-// Quit sends a QUIT command to the IRC server, with the optional msg as the quit message and closes the connection if the send succeeds.  If msg is the empty string, i.QuitMessage will be used, unless it's also the empty string, in which case no message is sent with the QUIT command.
+// Quit sends a QUIT command to the IRC server, with the optional msg as the quit message and closes the connection if the send succeeds.  If i.QuitLinger is positive, Quit waits up to that long for the server to acknowledge the QUIT with an ERROR or by closing the connection before closing it, which gives the QUIT time to reach the server and the ERROR time to be read, and then waits up to i.QuitLinger again for reading to stop.  If msg is the empty string, i.QuitMessage will be used, unless it's also the empty string, in which case no message is sent with the QUIT command.
 func (i *IRC) Quit(msg string) error {
 	/* Use the stored message if msg is empty */
 	if "" == msg && "" != i.QuitMessage {
@@ -332,14 +799,65 @@ func (i *IRC) Quit(msg string) error {
 	if "" != msg {
 		msg = " :" + msg
 	}
-	/* Send the quit message */
-	if err := i.PrintfLine("QUIT%v", msg); nil != err {
+	/* Send the quit message, and maybe wait for the server to see it */
+	if err := i.sendQuit(msg); nil != err {
 		return err
 	}
-	/* Close the connection */
-	if err := i.S.Close(); nil != err {
-		return err
+	/* Close the connection, unless the server's beaten us to it */
+	done := i.connDone()
+	if err := i.closeConn(); nil != err {
+		select {
+		case <-done:
+		default:
+			return err
+		}
+	}
+	/* Give the read goroutine a moment to finish up */
+	if 0 < i.QuitLinger {
+		select {
+		case <-done:
+		case <-time.After(i.QuitLinger):
+		}
 	}
-
 	return nil
 }
+
+// connDone returns a channel which is closed when the current connection
+// ends
+func (i *IRC) connDone() <-chan struct{} {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.ctx {
+		c := make(chan struct{})
+		close(c)
+		return c
+	}
+	return i.ctx.Done()
+}
+
+// sendQuit sends a QUIT with the given suffix, and waits up to i.QuitLinger
+// for the server to send ERROR or hang up
+func (i *IRC) sendQuit(suffix string) error {
+	send := func() error { return i.PrintfLine("QUIT%v", suffix) }
+	if 0 >= i.QuitLinger {
+		return send()
+	}
+	var (
+		sent    bool
+		sendErr error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), i.QuitLinger)
+	defer cancel()
+	i.wait(ctx, func() error {
+		sent = true
+		sendErr = send()
+		return sendErr
+	}, func(m Message) (bool, bool) {
+		return false, "ERROR" == m.Command
+	})
+	/* Never connected */
+	if !sent {
+		return send()
+	}
+	return sendErr
+}
@@ -0,0 +1,539 @@
+// Package minimalircd is a tiny IRC server, just enough to run integration tests and small airgapped lab networks against.  It supports registration, JOIN, PART, PRIVMSG and NOTICE relay, NICK changes, ISON, MODE queries, and PING, and disconnects clients which don't answer its PINGs.
+package minimalircd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * minimalircd.go
+ * Tiny IRC server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../minimalirc.go for license details.
+ */
+
+// clientBuffer is the number of lines which may be queued for a client
+// before it's considered too slow and disconnected
+const clientBuffer = 1024
+
+// drainTimeout is how long a disconnected client has to receive its last
+// lines
+const drainTimeout = time.Second
+
+// Server is an IRC server.  Nicks and channel names are compared case-insensitively using ASCII case-folding.
+type Server struct {
+	Name         string        /* Server name, "minimalircd" if empty */
+	PingInterval time.Duration /* Time between PINGs to idle clients */
+	PingTimeout  time.Duration /* Time to wait for PONG, PingInterval if 0 */
+
+	mu       sync.Mutex
+	clients  map[string]*client          /* Registered, by nick */
+	channels map[string]map[*client]bool /* Members, by channel */
+	names    map[string]string           /* Channel names as created */
+}
+
+/* client is a connection to the server */
+type client struct {
+	s    *Server
+	conn net.Conn
+	out  chan string
+	done chan struct{}
+	once sync.Once
+	pong chan struct{} /* Written to when the client PONGs */
+
+	/* Only changed with s.mu held */
+	nick string
+	user string
+	host string
+	reg  bool /* Registered */
+}
+
+// New returns a new Server with the given name.
+func New(name string) *Server {
+	return &Server{
+		Name:     name,
+		clients:  make(map[string]*client),
+		channels: make(map[string]map[*client]bool),
+		names:    make(map[string]string),
+	}
+}
+
+// ListenAndServe listens on addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if nil != err {
+		return err
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve accepts clients from l until it returns an error.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if nil != err {
+			return err
+		}
+		go s.serveClient(c)
+	}
+}
+
+/* name returns the server's name */
+func (s *Server) name() string {
+	if "" == s.Name {
+		return "minimalircd"
+	}
+	return s.Name
+}
+
+/* serveClient handles a client until it disconnects */
+func (s *Server) serveClient(conn net.Conn) {
+	c := &client{
+		s:    s,
+		conn: conn,
+		out:  make(chan string, clientBuffer),
+		done: make(chan struct{}),
+		pong: make(chan struct{}, 1),
+		host: "localhost",
+	}
+	if h, _, err := net.SplitHostPort(conn.RemoteAddr().String()); nil ==
+		err {
+		c.host = h
+	}
+	defer s.quit(c, "Connection closed")
+	go c.writeLoop()
+	if 0 < s.PingInterval {
+		go c.pingLoop()
+	}
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	for {
+		line, err := r.ReadLine()
+		if nil != err {
+			return
+		}
+		m := minimalirc.ParseMessage(line)
+		if "QUIT" == m.Command {
+			s.quit(c, "Quit: "+m.Param(0))
+			return
+		}
+		s.handle(c, m)
+	}
+}
+
+/* writeLoop sends queued lines to the client */
+func (c *client) writeLoop() {
+	w := textproto.NewWriter(bufio.NewWriter(c.conn))
+	for {
+		select {
+		case l := <-c.out:
+			if err := w.PrintfLine("%s", l); nil != err {
+				c.close()
+				c.conn.Close()
+				return
+			}
+		case <-c.done:
+			c.drain(w)
+			return
+		}
+	}
+}
+
+// drain sends what's left in c.out, briefly, so the client gets to see why
+// it's being disconnected, and closes the connection
+func (c *client) drain(w *textproto.Writer) {
+	defer c.conn.Close()
+	c.conn.SetWriteDeadline(time.Now().Add(drainTimeout))
+	for {
+		select {
+		case l := <-c.out:
+			if err := w.PrintfLine("%s", l); nil != err {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// pingLoop PINGs the client every s.PingInterval, and disconnects it if it
+// doesn't PONG within s.PingTimeout
+func (c *client) pingLoop() {
+	timeout := c.s.PingTimeout
+	if 0 >= timeout {
+		timeout = c.s.PingInterval
+	}
+	t := time.NewTicker(c.s.PingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-c.done:
+			return
+		}
+		/* Forget about PONGs we didn't ask for */
+		select {
+		case <-c.pong:
+		default:
+		}
+		c.send("PING :" + c.s.name())
+		select {
+		case <-c.pong:
+		case <-time.After(timeout):
+			c.s.quit(c, fmt.Sprintf("Ping timeout: %v", timeout))
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+/* send queues a line for the client, dropping it if it's too slow */
+func (c *client) send(line string) {
+	select {
+	case c.out <- line:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+/* close disconnects the client, once writeLoop's drained c.out */
+func (c *client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+/* prefix returns the client's nick!user@host.  c.s.mu must be held. */
+func (c *client) prefix() string {
+	return fmt.Sprintf("%v!%v@%v", c.nick, c.user, c.host)
+}
+
+/* reply sends a numeric reply to c.  s.mu must be held. */
+func (s *Server) reply(c *client, numeric string, params ...string) {
+	nick := c.nick
+	if "" == nick {
+		nick = "*"
+	}
+	c.send(minimalirc.Message{
+		Prefix:  s.name(),
+		Command: numeric,
+		Params:  append([]string{nick}, params...),
+	}.String())
+}
+
+/* handle handles a message from a client */
+func (s *Server) handle(c *client, m minimalirc.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch m.Command {
+	case "CAP":
+		if "LS" == strings.ToUpper(m.Param(0)) {
+			c.send(fmt.Sprintf(":%v CAP * LS :", s.name()))
+		}
+		return
+	case "PING":
+		c.send(fmt.Sprintf(":%v PONG %v :%v", s.name(), s.name(),
+			m.Param(0)))
+		return
+	case "PONG":
+		select {
+		case c.pong <- struct{}{}:
+		default:
+		}
+		return
+	case "NICK":
+		s.nick(c, m.Param(0))
+		return
+	case "USER":
+		if c.reg {
+			s.reply(c, "462", "You may not reregister")
+			return
+		}
+		if "" == m.Param(0) {
+			s.reply(c, "461", "USER", "Not enough parameters")
+			return
+		}
+		c.user = "~" + m.Param(0)
+		s.welcome(c)
+		return
+	}
+	if !c.reg {
+		s.reply(c, "451", "You have not registered")
+		return
+	}
+	switch m.Command {
+	case "JOIN":
+		for _, ch := range strings.Split(m.Param(0), ",") {
+			s.join(c, ch)
+		}
+	case "PART":
+		for _, ch := range strings.Split(m.Param(0), ",") {
+			s.part(c, ch, m.Param(1))
+		}
+	case "PRIVMSG", "NOTICE":
+		s.relay(c, m.Command, m.Param(0), m.Param(1))
+	case "NAMES":
+		s.namesReply(c, m.Param(0))
+	case "ISON":
+		var on []string
+		for _, n := range strings.Fields(strings.Join(m.Params, " ")) {
+			if o, ok := s.clients[strings.ToLower(n)]; ok && o.reg {
+				on = append(on, o.nick)
+			}
+		}
+		s.reply(c, "303", strings.Join(on, " "))
+	case "MODE":
+		s.mode(c, m.Param(0), m.Param(1))
+	case "WHO", "AWAY", "USERHOST", "TAGMSG":
+		/* Quietly ignored */
+	default:
+		s.reply(c, "421", m.Command, "Unknown command")
+	}
+}
+
+// mode answers a MODE for target.  There are no modes to set, so only queries
+// get anything useful back.  s.mu must be held.
+func (s *Server) mode(c *client, target, modes string) {
+	if "" == target {
+		s.reply(c, "461", "MODE", "Not enough parameters")
+		return
+	}
+	if !strings.HasPrefix(target, "#") {
+		switch {
+		case strings.ToLower(target) != strings.ToLower(c.nick):
+			s.reply(c, "502", "Can't change mode for other users")
+		case "" == modes:
+			s.reply(c, "221", "+")
+		default:
+			s.reply(c, "501", "Unknown MODE flag")
+		}
+		return
+	}
+	k := strings.ToLower(target)
+	if _, ok := s.channels[k]; !ok {
+		s.reply(c, "403", target, "No such channel")
+		return
+	}
+	switch m := strings.TrimLeft(modes, "+-"); {
+	case "" == m:
+		s.reply(c, "324", s.names[k], "+")
+	case "b" == m && !strings.HasPrefix(modes, "-"):
+		/* Ban list, which is always empty */
+		s.reply(c, "368", s.names[k], "End of channel ban list")
+	default:
+		s.reply(c, "472", m[:1], "is unknown mode char to me")
+	}
+}
+
+/* nick changes c's nick.  s.mu must be held. */
+func (s *Server) nick(c *client, nick string) {
+	if "" == nick || strings.ContainsAny(nick, " ,*?!@#&:") {
+		s.reply(c, "432", nick, "Erroneous nickname")
+		return
+	}
+	k := strings.ToLower(nick)
+	if o, ok := s.clients[k]; ok && o != c {
+		s.reply(c, "433", nick, "Nickname is already in use")
+		return
+	}
+	old := c.nick
+	if !c.reg {
+		c.nick = nick
+		s.welcome(c)
+		return
+	}
+	/* Tell everybody who can see us */
+	line := fmt.Sprintf(":%v NICK :%v", c.prefix(), nick)
+	seen := map[*client]bool{c: true}
+	c.send(line)
+	for _, ms := range s.channels {
+		if !ms[c] {
+			continue
+		}
+		for o := range ms {
+			if !seen[o] {
+				seen[o] = true
+				o.send(line)
+			}
+		}
+	}
+	delete(s.clients, strings.ToLower(old))
+	c.nick = nick
+	s.clients[k] = c
+}
+
+/* welcome registers c if it's sent both NICK and USER.  s.mu must be held */
+func (s *Server) welcome(c *client) {
+	if c.reg || "" == c.nick || "" == c.user {
+		return
+	}
+	/* Someone else may have registered with the nick since we took it */
+	k := strings.ToLower(c.nick)
+	if o, ok := s.clients[k]; ok && o != c {
+		nick := c.nick
+		c.nick = ""
+		s.reply(c, "433", nick, "Nickname is already in use")
+		return
+	}
+	c.reg = true
+	s.clients[k] = c
+	s.reply(c, "001", "Welcome to the Internet Relay Network "+
+		c.prefix())
+	s.reply(c, "002", "Your host is "+s.name())
+	s.reply(c, "003", "This server was created just now")
+	s.reply(c, "004", s.name(), "minimalircd", "i", "n")
+	s.reply(c, "005", "CASEMAPPING=ascii", "CHANTYPES=#",
+		"are supported by this server")
+	s.reply(c, "422", "MOTD File is missing")
+}
+
+/* join adds c to channel ch.  s.mu must be held. */
+func (s *Server) join(c *client, ch string) {
+	if !strings.HasPrefix(ch, "#") || 1 == len(ch) ||
+		strings.ContainsAny(ch, " ,\x07") {
+		s.reply(c, "403", ch, "No such channel")
+		return
+	}
+	k := strings.ToLower(ch)
+	ms, ok := s.channels[k]
+	if !ok {
+		ms = make(map[*client]bool)
+		s.channels[k] = ms
+		s.names[k] = ch
+	}
+	if ms[c] {
+		return
+	}
+	ms[c] = true
+	line := fmt.Sprintf(":%v JOIN %v", c.prefix(), s.names[k])
+	for o := range ms {
+		o.send(line)
+	}
+	s.reply(c, "331", s.names[k], "No topic is set")
+	s.namesReply(c, ch)
+}
+
+/* namesReply sends c the members of ch.  s.mu must be held. */
+func (s *Server) namesReply(c *client, ch string) {
+	k := strings.ToLower(ch)
+	if ms, ok := s.channels[k]; ok {
+		var ns []string
+		for o := range ms {
+			ns = append(ns, o.nick)
+		}
+		sort.Strings(ns)
+		s.reply(c, "353", "=", s.names[k], strings.Join(ns, " "))
+	}
+	s.reply(c, "366", ch, "End of /NAMES list")
+}
+
+/* part removes c from ch.  s.mu must be held. */
+func (s *Server) part(c *client, ch, reason string) {
+	k := strings.ToLower(ch)
+	ms, ok := s.channels[k]
+	if !ok || !ms[c] {
+		s.reply(c, "442", ch, "You're not on that channel")
+		return
+	}
+	line := minimalirc.Message{
+		Prefix:  c.prefix(),
+		Command: "PART",
+		Params:  []string{s.names[k], reason},
+	}.String()
+	for o := range ms {
+		o.send(line)
+	}
+	s.leave(c, k)
+}
+
+// leave removes c from the channel with key k, removing the channel if it's
+// empty.  s.mu must be held.
+func (s *Server) leave(c *client, k string) {
+	delete(s.channels[k], c)
+	if 0 == len(s.channels[k]) {
+		delete(s.channels, k)
+		delete(s.names, k)
+	}
+}
+
+/* relay sends a PRIVMSG or NOTICE from c to target.  s.mu must be held. */
+func (s *Server) relay(c *client, cmd, target, text string) {
+	if "" == target {
+		s.reply(c, "411", "No recipient given ("+cmd+")")
+		return
+	}
+	line := minimalirc.Message{
+		Prefix:  c.prefix(),
+		Command: cmd,
+		Params:  []string{target, text},
+	}.String()
+	k := strings.ToLower(target)
+	if strings.HasPrefix(target, "#") {
+		ms, ok := s.channels[k]
+		if !ok {
+			s.reply(c, "403", target, "No such channel")
+			return
+		}
+		if !ms[c] {
+			s.reply(c, "404", target, "Cannot send to channel")
+			return
+		}
+		for o := range ms {
+			if o != c {
+				o.send(line)
+			}
+		}
+		return
+	}
+	o, ok := s.clients[k]
+	if !ok {
+		s.reply(c, "401", target, "No such nick/channel")
+		return
+	}
+	o.send(line)
+}
+
+/* quit removes c from the server, telling everybody who can see it */
+func (s *Server) quit(c *client, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer c.close()
+	if !c.reg {
+		return
+	}
+	if o, ok := s.clients[strings.ToLower(c.nick)]; !ok || o != c {
+		return
+	}
+	line := minimalirc.Message{
+		Prefix:  c.prefix(),
+		Command: "QUIT",
+		Params:  []string{reason},
+	}.String()
+	seen := map[*client]bool{c: true}
+	for k, ms := range s.channels {
+		if !ms[c] {
+			continue
+		}
+		for o := range ms {
+			if !seen[o] {
+				seen[o] = true
+				o.send(line)
+			}
+		}
+		s.leave(c, k)
+	}
+	c.send(fmt.Sprintf("ERROR :Closing link (%v)", reason))
+	delete(s.clients, strings.ToLower(c.nick))
+}
@@ -0,0 +1,117 @@
+package minimalircd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+ * minimalircd_test.go
+ * Test the tiny IRC server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../minimalirc.go for license details.
+ */
+
+/* testClient is a raw connection to a Server */
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *textproto.Reader
+}
+
+/* newTestClient starts s and connects to it, registered as nick */
+func newTestClient(t *testing.T, s *Server, nick string) *testClient {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Skipf("Listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go s.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if nil != err {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	tc := &testClient{
+		t:    t,
+		conn: conn,
+		r:    textproto.NewReader(bufio.NewReader(conn)),
+	}
+	tc.send("NICK " + nick)
+	tc.send("USER u 0 * :r")
+	tc.expect("422")
+	return tc
+}
+
+/* send sends a line to the server */
+func (tc *testClient) send(line string) {
+	if _, err := fmt.Fprintf(tc.conn, "%s\r\n", line); nil != err {
+		tc.t.Fatalf("send: %v", err)
+	}
+}
+
+// expect reads lines until one with the given command, which it returns, or
+// fails if there's none within a couple of seconds
+func (tc *testClient) expect(command string) string {
+	tc.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		l, err := tc.r.ReadLine()
+		if nil != err {
+			tc.t.Fatalf("waiting for %v: %v", command, err)
+		}
+		f := strings.Fields(l)
+		if 0 != len(f) && strings.HasPrefix(f[0], ":") {
+			f = f[1:]
+		}
+		if 0 != len(f) && command == f[0] {
+			return l
+		}
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	s := New("test")
+	s.PingInterval = 50 * time.Millisecond
+	tc := newTestClient(t, s, "nick")
+
+	/* Answering keeps us connected */
+	for n := 0; n < 2; n++ {
+		tc.expect("PING")
+		tc.send("PONG :test")
+	}
+
+	/* Not answering doesn't */
+	tc.expect("PING")
+	if l := tc.expect("ERROR"); !strings.Contains(l, "Ping timeout") {
+		t.Errorf("disconnected with %q", l)
+	}
+}
+
+func TestMode(t *testing.T) {
+	tc := newTestClient(t, New("test"), "nick")
+	tc.send("JOIN #a")
+	tc.expect("366")
+	for _, c := range []struct {
+		line string
+		want string
+	}{
+		{"MODE nick", ":test 221 nick +"},
+		{"MODE other", ":test 502 nick :Can't change mode for other users"},
+		{"MODE #a", ":test 324 nick #a +"},
+		{"MODE #a +b", ":test 368 nick #a :End of channel ban list"},
+		{"MODE #a +k key", ":test 472 nick k :is unknown mode char to me"},
+		{"MODE #nope", ":test 403 nick #nope :No such channel"},
+	} {
+		tc.send(c.line)
+		if got := tc.expect(strings.Fields(c.want)[1]); c.want != got {
+			t.Errorf("%q: got %q, not %q", c.line, got, c.want)
+		}
+	}
+}
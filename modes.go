@@ -0,0 +1,206 @@
+package minimalirc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * modes.go
+ * Query and change channel modes and lists
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* modesPerLine is the number of list modes set per MODE command */
+const modesPerLine = 3
+
+// ListEntry is an entry in a channel's ban, ban exception, or invite exception list.
+type ListEntry struct {
+	Mask  string    /* Banned or excepted mask */
+	SetBy string    /* Who set it, if the server says */
+	SetAt time.Time /* When it was set, if the server says */
+}
+
+// ChannelModes are a channel's modes, as returned by ChannelModes.
+type ChannelModes struct {
+	Modes   string    /* Mode letters, e.g. nt, without parameters */
+	Key     string    /* Channel key (+k), if set and visible to us */
+	Limit   int       /* User limit (+l), 0 if unset */
+	Created time.Time /* When the channel was created, if known */
+}
+
+// Has returns true if the channel has the mode letter m.
+func (c ChannelModes) Has(m byte) bool {
+	return -1 != strings.IndexByte(c.Modes, m)
+}
+
+// BanList asks the server for channel's ban list (+b) and returns it.  Errors from the server are returned as *NumericErrors.
+func (i *IRC) BanList(ctx context.Context, channel string) ([]ListEntry,
+	error) {
+	return i.modeList(ctx, channel, "b", "367", "368")
+}
+
+// ExceptList asks the server for channel's ban exception list (+e) and returns it.  Errors are as for BanList.
+func (i *IRC) ExceptList(ctx context.Context, channel string) ([]ListEntry,
+	error) {
+	return i.modeList(ctx, channel, "e", "348", "349")
+}
+
+// InviteList asks the server for channel's invite exception list (+I) and returns it.  Errors are as for BanList.
+func (i *IRC) InviteList(ctx context.Context, channel string) ([]ListEntry,
+	error) {
+	return i.modeList(ctx, channel, "I", "346", "347")
+}
+
+// modeList requests a list mode and collects the entry numerics until the
+// end numeric
+func (i *IRC) modeList(
+	ctx context.Context,
+	channel, mode, entry, end string,
+) ([]ListEntry, error) {
+	var nerr *NumericError
+	ms, err := i.wait(ctx, func() error {
+		return i.PrintfLine("MODE %v +%v", channel, mode)
+	}, func(m Message) (bool, bool) {
+		if fold(m.Param(1)) != fold(channel) {
+			return false, false
+		}
+		switch m.Command {
+		case entry:
+			return true, false
+		case end:
+			return false, true
+		case "403", "442", "472", "479", "482":
+			nerr = numericError(m)
+			return false, true
+		}
+		return false, false
+	})
+	if nil != err {
+		return nil, err
+	}
+	if nil != nerr {
+		return nil, nerr
+	}
+	es := make([]ListEntry, 0, len(ms))
+	for _, m := range ms {
+		e := ListEntry{Mask: m.Param(2), SetBy: m.Param(3)}
+		if s, err := strconv.ParseInt(m.Param(4), 10, 64); nil == err {
+			e.SetAt = time.Unix(s, 0)
+		}
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+// ChannelModes asks the server for channel's modes.  Only the k and l modes' parameters are understood; channels with other modes which take parameters may have a wrong Key or Limit.  Errors from the server are returned as *NumericErrors.
+func (i *IRC) ChannelModes(ctx context.Context, channel string) (
+	ChannelModes, error) {
+	var (
+		nerr *NumericError
+		cm   ChannelModes
+	)
+	/* Not every server sends 329, so a PING marks the end of the
+	replies */
+	tok := fmt.Sprintf("modes-%v", time.Now().UnixNano())
+	ms, err := i.wait(ctx, func() error {
+		if err := i.PrintfLine("MODE %v", channel); nil != err {
+			return err
+		}
+		return i.PrintfLine("PING :%v", tok)
+	}, func(m Message) (bool, bool) {
+		if "PONG" == m.Command && tok == m.Trailing() {
+			return false, true
+		}
+		if fold(m.Param(1)) != fold(channel) {
+			return false, false
+		}
+		switch m.Command {
+		case "324", "329": /* RPL_CHANNELMODEIS, RPL_CREATIONTIME */
+			return true, false
+		case "403", "442", "479":
+			nerr = numericError(m)
+		}
+		return false, false
+	})
+	if nil != err {
+		return cm, err
+	}
+	if nil != nerr {
+		return cm, nerr
+	}
+	for _, m := range ms {
+		switch m.Command {
+		case "324":
+			if 2 < len(m.Params) {
+				cm.Modes, cm.Key, cm.Limit = parseChannelModes(
+					m.Params[2:])
+			}
+		case "329":
+			if s, err := strconv.ParseInt(m.Param(2), 10, 64); nil ==
+				err {
+				cm.Created = time.Unix(s, 0)
+			}
+		}
+	}
+	return cm, nil
+}
+
+// parseChannelModes splits the parameters of a 324 into mode letters, the
+// key, and the limit
+func parseChannelModes(ps []string) (modes, key string, limit int) {
+	if 0 == len(ps) {
+		return "", "", 0
+	}
+	ms, args := strings.TrimPrefix(ps[0], "+"), ps[1:]
+	next := func() string {
+		if 0 == len(args) {
+			return ""
+		}
+		a := args[0]
+		args = args[1:]
+		return a
+	}
+	for _, c := range []byte(ms) {
+		switch c {
+		case 'k':
+			key = next()
+		case 'l':
+			limit, _ = strconv.Atoi(next())
+		}
+	}
+	return ms, key, limit
+}
+
+// Ban bans masks from channel.  Several masks are set per MODE command.
+func (i *IRC) Ban(channel string, masks ...string) error {
+	return i.setListMode(channel, "+b", masks)
+}
+
+// Unban removes masks from channel's ban list.
+func (i *IRC) Unban(channel string, masks ...string) error {
+	return i.setListMode(channel, "-b", masks)
+}
+
+/* setListMode sends MODEs to add or remove masks from a list */
+func (i *IRC) setListMode(channel, mode string, masks []string) error {
+	for 0 != len(masks) {
+		n := modesPerLine
+		if n > len(masks) {
+			n = len(masks)
+		}
+		ms := mode[:1] + strings.Repeat(mode[1:], n)
+		if err := i.PrintfLine("MODE %v %v %v", channel, ms,
+			strings.Join(masks[:n], " ")); nil != err {
+			return err
+		}
+		masks = masks[n:]
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package minimalirc
+
+import (
+	"fmt"
+	"log"
+)
+
+/*
+ * name.go
+ * Tell connections apart
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// NamedError is an error from a connection with a Name.  If i.Name is set, errors sent on i.E and in ErrorEvents, and errors returned by Connect and Start, are wrapped in a *NamedError.  Use errors.Is or errors.As to look at the underlying error.
+type NamedError struct {
+	Name string /* The connection's Name */
+	Err  error
+}
+
+func (e *NamedError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Name, e.Err)
+}
+
+// Unwrap returns e.Err.
+func (e *NamedError) Unwrap() error {
+	return e.Err
+}
+
+/* named wraps err in a *NamedError if i has a Name and err isn't nil */
+func (i *IRC) named(err error) error {
+	if "" == i.Name || nil == err {
+		return err
+	}
+	return &NamedError{Name: i.Name, Err: err}
+}
+
+/* logf logs with log.Printf, prefixed with i.Name if it's set */
+func (i *IRC) logf(f string, args ...interface{}) {
+	if "" == i.Name {
+		log.Printf(f, args...)
+		return
+	}
+	log.Printf("[%v] %v", i.Name, fmt.Sprintf(f, args...))
+}
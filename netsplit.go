@@ -0,0 +1,174 @@
+package minimalirc
+
+import (
+	"regexp"
+	"time"
+)
+
+/*
+ * netsplit.go
+ * Notice netsplits, rejoins, and nick collisions
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+const (
+	/* How long to gather QUITs before reporting a netsplit */
+	netsplitWindow = 2 * time.Second
+	/* How long to remember a nick lost in a split */
+	netsplitMemory = time.Hour
+)
+
+// netsplitRE matches the QUIT reason given to users lost in a netsplit, e.g.
+// "*.net *.split" or "hub.example.com leaf.example.com"
+var netsplitRE = regexp.MustCompile(`^(\S+\.\S+) (\S+\.\S+)$`)
+
+/* collisionRE matches QUIT and KILL reasons for nick collisions */
+var collisionRE = regexp.MustCompile(`(?i)nick collision`)
+
+// NetsplitEvent is sent when users QUIT with a netsplit reason.  QUITs for the same split arriving close together are gathered into one event, which is sent with the first line from the server after a couple of seconds have passed.
+type NetsplitEvent struct {
+	Servers [2]string /* The servers which split */
+	Nicks   []string  /* Nicks lost in the split */
+}
+
+// RejoinedEvent is sent when a nick lost in a netsplit JOINs a channel.  It's sent at most once for each channel the nick rejoins.
+type RejoinedEvent struct {
+	Nick    string    /* Nick which came back */
+	Channel string    /* Channel it joined */
+	Servers [2]string /* The split it was lost in */
+}
+
+// NickCollisionEvent is sent when a nick is killed in a nick collision.  Ours is true when the nick is ours (numeric 436).
+type NickCollisionEvent struct {
+	Nick   string /* Colliding nick */
+	Reason string /* Reason from the server */
+	Ours   bool   /* True if it's our nick */
+}
+
+func (NetsplitEvent) event()      {}
+func (RejoinedEvent) event()      {}
+func (NickCollisionEvent) event() {}
+
+/* splitNick records when and in which split a nick was lost */
+type splitNick struct {
+	servers  [2]string
+	when     time.Time
+	rejoined map[string]bool /* Channels it's rejoined, by fold(channel) */
+}
+
+/* gatheredSplit is a NetsplitEvent being gathered, and when to send it */
+type gatheredSplit struct {
+	e   NetsplitEvent
+	due time.Time
+}
+
+/* watchSplits looks for netsplits, rejoins, and nick collisions in m */
+func (i *IRC) watchSplits(m Message) {
+	switch m.Command {
+	case "QUIT":
+		reason := m.Param(0)
+		if g := netsplitRE.FindStringSubmatch(reason); nil != g {
+			i.addSplitNick(m.Nick, [2]string{g[1], g[2]})
+			return
+		}
+		/* A normal QUIT means the nick won't be rejoining */
+		i.mu.Lock()
+		delete(i.splitNicks, fold(m.Nick))
+		i.mu.Unlock()
+		if collisionRE.MatchString(reason) {
+			i.event(NickCollisionEvent{Nick: m.Nick, Reason: reason})
+		}
+	case "KILL":
+		if collisionRE.MatchString(m.Param(1)) {
+			i.event(NickCollisionEvent{
+				Nick:   m.Param(0),
+				Reason: m.Param(1),
+			})
+		}
+	case "436": /* ERR_NICKCOLLISION */
+		i.event(NickCollisionEvent{
+			Nick:   m.Param(1),
+			Reason: m.Trailing(),
+			Ours:   true,
+		})
+	case "JOIN":
+		ch := fold(m.Param(0))
+		i.mu.Lock()
+		s, ok := i.splitNicks[fold(m.Nick)]
+		if ok && time.Since(s.when) > netsplitMemory {
+			delete(i.splitNicks, fold(m.Nick))
+			ok = false
+		}
+		/* Only the first JOIN of each channel is a rejoin */
+		if ok && s.rejoined[ch] {
+			ok = false
+		} else if ok {
+			s.rejoined[ch] = true
+		}
+		i.mu.Unlock()
+		if ok {
+			i.event(RejoinedEvent{
+				Nick:    m.Nick,
+				Channel: m.Param(0),
+				Servers: s.servers,
+			})
+		}
+	}
+}
+
+// addSplitNick notes that nick was lost in the split between servers, and
+// starts gathering nicks for a NetsplitEvent if this is a new split
+func (i *IRC) addSplitNick(nick string, servers [2]string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.splitNicks {
+		i.splitNicks = make(map[string]splitNick)
+	}
+	/* Forget long-gone nicks */
+	for k, v := range i.splitNicks {
+		if time.Since(v.when) > netsplitMemory {
+			delete(i.splitNicks, k)
+		}
+	}
+	i.splitNicks[fold(nick)] = splitNick{
+		servers:  servers,
+		when:     time.Now(),
+		rejoined: make(map[string]bool),
+	}
+	/* Add to a split being gathered, or start a new one */
+	if nil == i.splits {
+		i.splits = make(map[[2]string]*gatheredSplit)
+	}
+	if g, ok := i.splits[servers]; ok {
+		g.e.Nicks = append(g.e.Nicks, nick)
+		return
+	}
+	i.splits[servers] = &gatheredSplit{
+		e:   NetsplitEvent{Servers: servers, Nicks: []string{nick}},
+		due: time.Now().Add(netsplitWindow),
+	}
+}
+
+// flushSplits sends the NetsplitEvents which have been gathered for long
+// enough.  It's called for every line from the server, before the fast path
+// in handleLine, from the goroutine reading from the server like the rest of
+// the events, rather than from a timer.
+func (i *IRC) flushSplits() {
+	now := time.Now()
+	var es []NetsplitEvent
+	i.mu.Lock()
+	for k, g := range i.splits {
+		if now.Before(g.due) {
+			continue
+		}
+		es = append(es, g.e)
+		delete(i.splits, k)
+	}
+	i.mu.Unlock()
+	for _, e := range es {
+		i.event(e)
+	}
+}
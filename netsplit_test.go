@@ -0,0 +1,49 @@
+package minimalirc
+
+import (
+	"testing"
+	"time"
+)
+
+/*
+ * netsplit_test.go
+ * Test noticing netsplits
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+func TestNetsplitFastPath(t *testing.T) {
+	i := New("irc.example.com", 6697, true, "", "nick", "u", "r")
+	var es []NetsplitEvent
+	i.OnEvent = func(e Event) {
+		if s, ok := e.(NetsplitEvent); ok {
+			es = append(es, s)
+		}
+	}
+	i.use(&sentLines{})
+	i.Feed(":irc.example.com 001 nick :Welcome")
+	i.Feed(":nick!u@h JOIN #a")
+	i.Feed(":other!u@h QUIT :hub.example.com leaf.example.com")
+	if 0 != len(es) {
+		t.Fatalf("netsplit sent early: %v", es)
+	}
+
+	/* Pretend the split's been gathered long enough */
+	i.mu.Lock()
+	for _, g := range i.splits {
+		g.due = time.Now().Add(-time.Second)
+	}
+	i.mu.Unlock()
+
+	/* Plain chatter should be enough to send it */
+	line := ":third!u@h PRIVMSG #a :hello"
+	if !i.uninteresting(line) {
+		t.Fatalf("%q doesn't take the fast path", line)
+	}
+	i.Feed(line)
+	if 1 != len(es) || 1 != len(es[0].Nicks) || "other" != es[0].Nicks[0] {
+		t.Fatalf("got %v", es)
+	}
+}
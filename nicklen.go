@@ -0,0 +1,38 @@
+package minimalirc
+
+/*
+ * nicklen.go
+ * Keep nicks short enough for the server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// fitNick truncates nick to the server's NICKLEN, if we know it.  NICKLEN is
+// only sent after registration, so i.NickLen is used until the server's
+// told us, and the server's value is remembered across reconnects.
+func (i *IRC) fitNick(nick string) string {
+	i.mu.Lock()
+	max := i.nicklen
+	i.mu.Unlock()
+	if 0 >= max {
+		max = i.NickLen
+	}
+	if 0 < max && len(nick) > max {
+		return nick[:max]
+	}
+	return nick
+}
+
+/* trackNickLen remembers the server's NICKLEN */
+func (i *IRC) trackNickLen(m Message) {
+	if "005" != m.Command {
+		return
+	}
+	if n := i.isupportInt("NICKLEN", 0); 0 < n {
+		i.mu.Lock()
+		i.nicklen = n
+		i.mu.Unlock()
+	}
+}
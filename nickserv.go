@@ -0,0 +1,104 @@
+package minimalirc
+
+import (
+	"strings"
+)
+
+/*
+ * nickserv.go
+ * Work out whether identification worked
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// AuthResult is the result of identifying to services.
+type AuthResult int
+
+// Results of identifying to services.
+const (
+	AuthAccepted      AuthResult = iota /* We're identified */
+	AuthNotRegistered                   /* The account doesn't exist */
+	AuthBadPassword                     /* The password was wrong */
+)
+
+func (r AuthResult) String() string {
+	switch r {
+	case AuthAccepted:
+		return "accepted"
+	case AuthNotRegistered:
+		return "not registered"
+	case AuthBadPassword:
+		return "bad password"
+	}
+	return "unknown"
+}
+
+// AuthResultEvent is sent when NickServ (or i.Services) tells us how identifying went, or the server says we've logged in (numeric 900).
+type AuthResultEvent struct {
+	Result AuthResult
+	Text   string /* What NickServ said */
+}
+
+func (AuthResultEvent) event() {}
+
+// authReplies are lower-case snippets of notices from the common services
+// packages (Atheme, Anope, and friends, as well as X and Q), and what they
+// mean
+var authReplies = []struct {
+	text   string
+	result AuthResult
+}{
+	{"password accepted", AuthAccepted},
+	{"you are now identified", AuthAccepted},
+	{"you are now logged in", AuthAccepted},
+	{"you are successfully identified", AuthAccepted},
+	{"authentication successful", AuthAccepted},
+	{"isn't registered", AuthNotRegistered},
+	{"is not registered", AuthNotRegistered},
+	{"is not a registered nickname", AuthNotRegistered},
+	{"invalid password", AuthBadPassword},
+	{"password incorrect", AuthBadPassword},
+	{"incorrect password", AuthBadPassword},
+	{"authentication failed", AuthBadPassword},
+}
+
+// Identified returns true if services have told us we're identified since we connected.
+func (i *IRC) Identified() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.identified
+}
+
+/* trackAuth looks for NickServ's response to identification */
+func (i *IRC) trackAuth(m Message) {
+	var r AuthResult
+	switch {
+	case "900" == m.Command: /* RPL_LOGGEDIN */
+		r = AuthAccepted
+	case "NOTICE" == m.Command &&
+		fold(i.services().Bot()) == fold(m.Nick):
+		var ok bool
+		if r, ok = authReply(m.Trailing()); !ok {
+			return
+		}
+	default:
+		return
+	}
+	i.mu.Lock()
+	i.identified = AuthAccepted == r
+	i.mu.Unlock()
+	i.event(AuthResultEvent{Result: r, Text: m.Trailing()})
+}
+
+/* authReply works out what text from NickServ means, if anything */
+func authReply(text string) (AuthResult, bool) {
+	text = strings.ToLower(text)
+	for _, r := range authReplies {
+		if strings.Contains(text, r.text) {
+			return r.result, true
+		}
+	}
+	return 0, false
+}
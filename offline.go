@@ -0,0 +1,192 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * offline.go
+ * Get messages to nicks who aren't around
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// OfflineMode says what Deliver does with messages to nicks which aren't online.
+type OfflineMode int
+
+// What to do with messages to offline nicks.
+const (
+	OfflineFail  OfflineMode = iota /* Return ErrOffline */
+	OfflineMemo                     /* Send them with MemoServ */
+	OfflineQueue                    /* Send them when the nick's online */
+)
+
+/* offlineNS is the i.Store namespace for Deliver's queue */
+const offlineNS = "offline"
+
+// ErrOffline is returned by Deliver when the nick isn't online and i.Offline is OfflineFail.
+var ErrOffline = errors.New("nick is offline")
+
+// IsOn asks the server which of the nicks are online, with ISON.
+func (i *IRC) IsOn(ctx context.Context, nicks ...string) ([]string,
+	error) {
+	ms, err := i.wait(ctx, func() error {
+		return i.PrintfLine("ISON :%v", strings.Join(nicks, " "))
+	}, func(m Message) (bool, bool) {
+		ok := "303" == m.Command /* RPL_ISON */
+		return ok, ok
+	})
+	if nil != err {
+		return nil, err
+	}
+	return strings.Fields(ms[0].Trailing()), nil
+}
+
+// Deliver sends msg to nick in a PRIVMSG if nick is online, per Watch if nick is being watched or ISON if not.  If nick isn't online, what happens depends on i.Offline.  Queued messages are sent, in order, when the server says nick's come online (see Watch; nick is watched while it has queued messages), or we see nick join a channel, change to nick, or send us a message.  The queue is kept across reconnects, and, if i.Store is set, across restarts; a stored queue is loaded when we first register.
+func (i *IRC) Deliver(ctx context.Context, nick, msg string) error {
+	online, known := i.Online(nick)
+	if !known {
+		on, err := i.IsOn(ctx, nick)
+		if nil != err {
+			return err
+		}
+		online = 0 != len(on)
+	}
+	if online {
+		return i.Privmsg(msg, nick)
+	}
+	switch i.Offline {
+	case OfflineMemo:
+		return i.SendMemo(ctx, nick, msg)
+	case OfflineQueue:
+		i.queueOffline(nick, msg)
+		return nil
+	}
+	return ErrOffline
+}
+
+// Queued returns the number of messages queued for nick by Deliver.
+func (i *IRC) Queued(nick string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.offline[fold(nick)])
+}
+
+/* queueOffline queues msgs for nick, and watches nick if need be */
+func (i *IRC) queueOffline(nick string, msgs ...string) {
+	k := fold(nick)
+	i.mu.Lock()
+	if nil == i.offline {
+		i.offline = make(map[string][]string)
+	}
+	i.offline[k] = append(i.offline[k], msgs...)
+	_, watched := i.watched[k]
+	if !watched {
+		if nil == i.offWatch {
+			i.offWatch = make(map[string]bool)
+		}
+		i.offWatch[k] = true
+	}
+	i.mu.Unlock()
+	i.saveOffline()
+	if watched {
+		return
+	}
+	if err := i.Watch(nick); nil != err && ErrNoPresence != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error watching %v for queued messages: %v", nick, err))})
+	}
+}
+
+/* deliverQueued sends the messages queued for nick, who's online */
+func (i *IRC) deliverQueued(nick string) {
+	if i.PassiveMode {
+		return
+	}
+	k := fold(nick)
+	i.mu.Lock()
+	msgs := i.offline[k]
+	delete(i.offline, k)
+	unwatch := i.offWatch[k]
+	delete(i.offWatch, k)
+	i.mu.Unlock()
+	if 0 == len(msgs) {
+		return
+	}
+	defer i.saveOffline()
+	for n, msg := range msgs {
+		if err := i.Privmsg(msg, nick); nil != err {
+			/* Try again later */
+			i.mu.Lock()
+			i.offline[k] = append(msgs[n:], i.offline[k]...)
+			i.mu.Unlock()
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error sending queued message to %v: %v", nick,
+				err))})
+			return
+		}
+	}
+	if unwatch {
+		if err := i.Unwatch(nick); nil != err && ErrNoPresence != err {
+			i.event(ErrorEvent{Err: err})
+		}
+	}
+}
+
+/* trackOffline sends queued messages when we see their nicks */
+func (i *IRC) trackOffline(m Message) {
+	var nick string
+	switch m.Command {
+	case "JOIN", "PRIVMSG", "NOTICE":
+		nick = m.Nick
+	case "NICK":
+		nick = m.Param(0)
+	default:
+		return
+	}
+	if 0 != i.Queued(nick) {
+		i.deliverQueued(nick)
+	}
+}
+
+/* saveOffline puts the queue in i.Store, if it's set */
+func (i *IRC) saveOffline() {
+	if nil == i.Store {
+		return
+	}
+	i.mu.Lock()
+	q := make(map[string][]string, len(i.offline))
+	for k, v := range i.offline {
+		q[k] = v
+	}
+	i.mu.Unlock()
+	if 0 != len(q) {
+		i.storeJSON(offlineNS, "queue", q)
+		return
+	}
+	if err := i.Store.Delete(offlineNS, i.storeKey("queue")); nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error removing stored queue: %v", err))})
+	}
+}
+
+// loadOffline adds the queue in i.Store, if it's set, to the queue, and
+// watches the nicks in it.  Only the first call has any effect.
+func (i *IRC) loadOffline() {
+	i.mu.Lock()
+	loaded := i.offLoaded
+	i.offLoaded = true
+	i.mu.Unlock()
+	var q map[string][]string
+	if loaded || !i.loadJSON(offlineNS, "queue", &q) {
+		return
+	}
+	for nick, msgs := range q {
+		i.queueOffline(nick, msgs...)
+	}
+}
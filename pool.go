@@ -0,0 +1,130 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+ * pool.go
+ * Lots of connections at once
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// PoolLine is a line received by one of a Pool's connections.  When a connection ends, a PoolLine with the error from its E channel and an empty Line is sent.
+type PoolLine struct {
+	N    int    /* Index of the connection in Pool.IRCs */
+	IRC  *IRC   /* The connection */
+	Line string /* The line */
+	Err  error  /* Why the connection ended */
+}
+
+// Pool is a set of connections to the same server, useful for load-testing servers.  Lines received by all of the connections are sent on C, which is closed when all of the connections have ended.
+type Pool struct {
+	IRCs    []*IRC          /* The connections */
+	C       <-chan PoolLine /* Lines from all the connections */
+	Stagger time.Duration   /* Delay between connections */
+	Limiter *Limiter        /* Shared rate limit, if not nil */
+
+	c  chan PoolLine
+	wg sync.WaitGroup
+}
+
+// NewPool makes a Pool of n connections to a server.  The arguments are as for New, except that each connection's nick is made with fmt.Sprintf(nickFormat, n), where n is the connection's index in p.IRCs (e.g. "load%03d").  The connections' fields may be changed before calling Connect.  Pongs is set for each connection, and each connection's Name is set to its nick.
+func NewPool(n int, host string, port uint16, ssl bool, hostname,
+	nickFormat, username, realname string) *Pool {
+	p := &Pool{IRCs: make([]*IRC, n), c: make(chan PoolLine)}
+	p.C = p.c
+	for j := range p.IRCs {
+		i := New(host, port, ssl, hostname, fmt.Sprintf(nickFormat, j),
+			username, realname)
+		i.Pongs = true
+		i.Name = i.Nick
+		p.IRCs[j] = i
+	}
+	return p
+}
+
+// Connect connects each of the connections in turn, waiting p.Stagger between connections.  If p.Limiter is set, it's used by every connection which doesn't already have a Limiter.  Connections which fail to connect are skipped, and an error is returned describing how many failed.  Connect should only be called once.
+func (p *Pool) Connect() error {
+	var (
+		nerr  int
+		first error
+	)
+	for j, i := range p.IRCs {
+		if 0 != j && 0 < p.Stagger {
+			time.Sleep(p.Stagger)
+		}
+		if nil == i.Limiter {
+			i.Limiter = p.Limiter
+		}
+		if err := i.Connect(); nil != err {
+			if 0 == nerr {
+				first = err
+			}
+			nerr++
+			continue
+		}
+		p.wg.Add(1)
+		go p.forward(j, i)
+	}
+	/* Close C when everything's done */
+	go func() {
+		p.wg.Wait()
+		close(p.c)
+	}()
+	if 0 != nerr {
+		return errors.New(fmt.Sprintf("%v of %v connections failed, "+
+			"first error: %v", nerr, len(p.IRCs), first))
+	}
+	return nil
+}
+
+/* forward sends lines from i on p.c */
+func (p *Pool) forward(n int, i *IRC) {
+	defer p.wg.Done()
+	c, e := i.C, i.E
+	for l := range c {
+		p.c <- PoolLine{N: n, IRC: i, Line: l}
+	}
+	p.c <- PoolLine{N: n, IRC: i, Err: <-e}
+}
+
+// PrintfLine calls PrintfLine on each connected connection, and returns the first error encountered, if any.
+func (p *Pool) PrintfLine(f string, args ...interface{}) error {
+	return p.each(func(i *IRC) error { return i.PrintfLine(f, args...) })
+}
+
+// Privmsg calls Privmsg on each connected connection, and returns the first error encountered, if any.
+func (p *Pool) Privmsg(msg, target string) error {
+	return p.each(func(i *IRC) error { return i.Privmsg(msg, target) })
+}
+
+// Join calls Join on each connected connection, and returns the first error encountered, if any.
+func (p *Pool) Join(channel, pass string) error {
+	return p.each(func(i *IRC) error { return i.Join(channel, pass) })
+}
+
+// Quit calls Quit on each connected connection, and returns the first error encountered, if any.
+func (p *Pool) Quit(msg string) error {
+	return p.each(func(i *IRC) error { return i.Quit(msg) })
+}
+
+/* each calls f for every connection which isn't disconnected */
+func (p *Pool) each(f func(i *IRC) error) error {
+	var first error
+	for _, i := range p.IRCs {
+		if Disconnected == i.State() {
+			continue
+		}
+		if err := f(i); nil != err && nil == first {
+			first = err
+		}
+	}
+	return first
+}
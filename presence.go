@@ -0,0 +1,169 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+ * presence.go
+ * Know when nicks come and go, with MONITOR or WATCH
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* watchBatch is the number of nicks per MONITOR or WATCH line */
+const watchBatch = 20
+
+// ErrNoPresence is returned by Watch and Unwatch if the server supports neither MONITOR nor WATCH.
+var ErrNoPresence = errors.New("server supports neither MONITOR nor WATCH")
+
+// PresenceEvent is sent when the server tells us a nick we're watching is online or offline.
+type PresenceEvent struct {
+	Nick     string
+	Online   bool
+	Hostmask string /* nick!user@host, if the server said */
+}
+
+func (PresenceEvent) event() {}
+
+// Watch asks the server to tell us when the nicks come online or go offline, which it does with PresenceEvents.  MONITOR is used if the server supports it, or WATCH if not.  The nicks are remembered, and watched again after reconnecting.  Before registration, the nicks are only remembered; they're sent once the server's said what it supports.
+func (i *IRC) Watch(nicks ...string) error {
+	i.mu.Lock()
+	if nil == i.watched {
+		i.watched = make(map[string]string)
+	}
+	for _, n := range nicks {
+		i.watched[fold(n)] = n
+	}
+	i.mu.Unlock()
+	return i.sendWatch(true, nicks)
+}
+
+// Unwatch stops watching the nicks.
+func (i *IRC) Unwatch(nicks ...string) error {
+	i.mu.Lock()
+	for _, n := range nicks {
+		delete(i.watched, fold(n))
+		delete(i.presence, fold(n))
+	}
+	i.mu.Unlock()
+	return i.sendWatch(false, nicks)
+}
+
+// Watched returns the nicks being watched, sorted.
+func (i *IRC) Watched() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ns := make([]string, 0, len(i.watched))
+	for _, n := range i.watched {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// Online returns whether a watched nick is online, and false for known if the server hasn't said.
+func (i *IRC) Online(nick string) (online, known bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	online, known = i.presence[fold(nick)]
+	return online, known
+}
+
+// sendWatch tells the server to add or remove nicks, with whichever of
+// MONITOR or WATCH it supports.  If we don't know yet, it's a no-op.
+func (i *IRC) sendWatch(add bool, nicks []string) error {
+	if 0 == len(nicks) || !i.knowsSupport() {
+		return nil
+	}
+	_, monitor := i.ISupport("MONITOR")
+	_, watch := i.ISupport("WATCH")
+	if !monitor && !watch {
+		return ErrNoPresence
+	}
+	for _, c := range chunk(nicks, watchBatch, nil) {
+		var l string
+		switch {
+		case monitor && add:
+			l = "MONITOR + " + strings.Join(c, ",")
+		case monitor:
+			l = "MONITOR - " + strings.Join(c, ",")
+		case add:
+			l = "WATCH +" + strings.Join(c, " +")
+		default:
+			l = "WATCH -" + strings.Join(c, " -")
+		}
+		if err := i.PrintfLine("%s", l); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+/* knowsSupport returns true once the server's sent its ISUPPORT tokens */
+func (i *IRC) knowsSupport() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.motdDone
+}
+
+/* trackPresence keeps track of watched nicks */
+func (i *IRC) trackPresence(m Message) {
+	switch m.Command {
+	case "376", "422": /* RPL_ENDOFMOTD, ERR_NOMOTD */
+		/* ISUPPORT's done by now, so we can watch what we've saved */
+		i.mu.Lock()
+		if i.motdDone {
+			i.mu.Unlock()
+			return
+		}
+		i.motdDone = true
+		i.mu.Unlock()
+		if i.PassiveMode {
+			return
+		}
+		if err := i.sendWatch(true, i.Watched()); nil != err &&
+			ErrNoPresence != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error watching nicks: %v", err))})
+		}
+	case "730", "731": /* RPL_MONONLINE, RPL_MONOFFLINE */
+		for _, t := range strings.Split(m.Trailing(), ",") {
+			if "" == t {
+				continue
+			}
+			n, _, _ := SplitPrefix(t)
+			hm := ""
+			if n != t {
+				hm = t
+			}
+			i.presenceChanged(n, "730" == m.Command, hm)
+		}
+	case "600", "604": /* RPL_LOGON, RPL_NOWON */
+		i.presenceChanged(m.Param(1), true,
+			fmt.Sprintf("%v!%v@%v", m.Param(1), m.Param(2), m.Param(3)))
+	case "601", "605": /* RPL_LOGOFF, RPL_NOWOFF */
+		i.presenceChanged(m.Param(1), false, "")
+	case "734", "512": /* ERR_MONLISTFULL, ERR_TOOMANYWATCH */
+		i.event(ErrorEvent{Err: numericError(m)})
+	}
+}
+
+/* presenceChanged notes a watched nick's state and sends an event */
+func (i *IRC) presenceChanged(nick string, online bool, hostmask string) {
+	i.mu.Lock()
+	if nil == i.presence {
+		i.presence = make(map[string]bool)
+	}
+	i.presence[fold(nick)] = online
+	i.mu.Unlock()
+	i.event(PresenceEvent{Nick: nick, Online: online, Hostmask: hostmask})
+	if online {
+		i.deliverQueued(nick)
+	}
+}
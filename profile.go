@@ -0,0 +1,86 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * profile.go
+ * Settings for well-known networks
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Profile holds the settings for a network.
+type Profile struct {
+	Host     string   /* Server to which to connect */
+	Port     uint16   /* Port to which to connect */
+	Ssl      bool     /* True to use SSL/TLS */
+	SASL     bool     /* True if the network supports SASL */
+	Services Services /* Network's services, nil if it has none */
+	Msglen   int      /* Size of an IRC message */
+}
+
+// Profiles are the settings for some well-known networks, keyed by lower-case name.  Entries may be added or changed before calling NewProfile.
+var Profiles = map[string]Profile{
+	"libera": {
+		Host:     "irc.libera.chat",
+		Port:     6697,
+		Ssl:      true,
+		SASL:     true,
+		Services: NickServ{},
+		Msglen:   467,
+	},
+	"oftc": {
+		Host:     "irc.oftc.net",
+		Port:     6697,
+		Ssl:      true,
+		Services: NickServ{},
+		Msglen:   467,
+	},
+	"efnet": {
+		Host:   "irc.efnet.org",
+		Port:   6697,
+		Ssl:    true,
+		Msglen: 467,
+	},
+	"rizon": {
+		Host:     "irc.rizon.net",
+		Port:     6697,
+		Ssl:      true,
+		Services: NickServ{},
+		Msglen:   467,
+	},
+	"quakenet": {
+		Host:     "irc.quakenet.org",
+		Port:     6667,
+		Services: QuakeNetQ{},
+		Msglen:   467,
+	},
+	"undernet": {
+		Host:     "irc.undernet.org",
+		Port:     6667,
+		Services: UndernetX{},
+		Msglen:   467,
+	},
+}
+
+// NewProfile is like New, but takes the server settings from the named entry in Profiles (e.g. "libera").  i.Pongs is set.  Every field may be changed before calling Connect.
+func NewProfile(profile, nick, username, realname string) (*IRC, error) {
+	p, ok := Profiles[strings.ToLower(profile)]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown profile %q",
+			profile))
+	}
+	i := New(p.Host, p.Port, p.Ssl, "", nick, username, realname)
+	if 0 != p.Msglen {
+		i.Msglen = p.Msglen
+	}
+	i.Services = p.Services
+	i.Pongs = true
+	return i, nil
+}
@@ -0,0 +1,124 @@
+package minimalirc
+
+import "sync"
+
+/*
+ * query.go
+ * One-to-one conversations
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Query is a private conversation with a nick.  PRIVMSGs from the nick sent directly to us are sent on C, which is buffered; if it fills, messages are dropped and a RouteFullEvent is sent for each.  If the nick changes, the Query follows it.  C is closed when the nick QUITs (which we only see if we share a channel) or Close is called.  Messages are still sent on i.C and to handlers as well.
+type Query struct {
+	C <-chan Message
+
+	i      *IRC
+	c      chan Message
+	mu     sync.Mutex
+	nick   string /* Nick, as last seen */
+	closed bool
+}
+
+// Query starts a Query with nick.  Each call returns a new Query; if there's more than one with the same nick, each gets the nick's messages.
+func (i *IRC) Query(nick string) *Query {
+	c := make(chan Message, routeBuffer)
+	q := &Query{C: c, i: i, c: c, nick: nick}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.queries = append(i.queries, q)
+	return q
+}
+
+// Nick returns the nick with which q is a conversation.
+func (q *Query) Nick() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.nick
+}
+
+// Send sends msg to q's nick in a PRIVMSG.  Long messages are split as with Privmsg.
+func (q *Query) Send(msg string) error {
+	return q.i.Privmsg(msg, q.Nick())
+}
+
+// Close closes q.C and stops q from receiving messages.  It's safe to call Close more than once.
+func (q *Query) Close() {
+	q.close()
+	i := q.i
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n, o := range i.queries {
+		if o == q {
+			i.queries = append(i.queries[:n], i.queries[n+1:]...)
+			return
+		}
+	}
+}
+
+/* close closes q.C, if it's not already closed */
+func (q *Query) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.c)
+	}
+}
+
+/* queriesWith returns the queries with nick */
+func (i *IRC) queriesWith(nick string) []*Query {
+	i.mu.Lock()
+	qs := i.queries
+	i.mu.Unlock()
+	var ws []*Query
+	for _, q := range qs {
+		if fold(q.Nick()) == fold(nick) {
+			ws = append(ws, q)
+		}
+	}
+	return ws
+}
+
+/* trackQueries follows nick changes and closes queries on QUIT */
+func (i *IRC) trackQueries(m Message) {
+	if "NICK" != m.Command && "QUIT" != m.Command {
+		return
+	}
+	for _, q := range i.queriesWith(m.Nick) {
+		if "QUIT" == m.Command {
+			q.Close()
+			continue
+		}
+		q.mu.Lock()
+		q.nick = m.Param(0)
+		q.mu.Unlock()
+	}
+}
+
+/* routeQueries sends private PRIVMSGs to the sender's queries */
+func (i *IRC) routeQueries(m Message) {
+	if "PRIVMSG" != m.Command {
+		return
+	}
+	if _, c := i.SplitStatus(m.Param(0)); isChannel(c) {
+		return
+	}
+	for _, q := range i.queriesWith(m.Nick) {
+		q.mu.Lock()
+		sent := true
+		if !q.closed {
+			select {
+			case q.c <- m:
+			default:
+				sent = false
+			}
+		}
+		q.mu.Unlock()
+		if !sent {
+			i.event(RouteFullEvent{Target: m.Nick, Message: m})
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package minimalirc
+
+import "errors"
+
+/*
+ * react.go
+ * Reactions to messages
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ErrNoMsgID is returned when reacting to a message without a msgid tag.  The message-tags capability must be in i.Caps for servers to send msgids.
+var ErrNoMsgID = errors.New("message has no msgid")
+
+// ReactionEvent is sent when a TAGMSG with a +draft/react tag is received.  A TagmsgEvent is sent as well.
+type ReactionEvent struct {
+	From     string /* nick!user@host of the reactor */
+	Nick     string /* Reactor's nick */
+	Target   string /* Channel or our nick */
+	MsgID    string /* Message reacted to, from +draft/reply */
+	Reaction string /* Usually an emoji */
+}
+
+func (ReactionEvent) event() {}
+
+// React reacts to msg, a PRIVMSG or NOTICE we received, with emoji (or any short text) by sending a TAGMSG with +draft/react and +draft/reply tags.  The reaction goes where a reply from ReplyTo would.  msg must have a msgid tag, and the message-tags capability must be enabled.
+func (i *IRC) React(msg Message, emoji string) error {
+	id := msg.Tags["msgid"]
+	if "" == id {
+		return ErrNoMsgID
+	}
+	return i.Tagmsg(i.replyTarget(msg), map[string]string{
+		"+draft/react": emoji,
+		"+draft/reply": id,
+	})
+}
+
+/* handleReaction turns reactions into events */
+func (i *IRC) handleReaction(m Message) {
+	if "TAGMSG" != m.Command {
+		return
+	}
+	r, ok := m.Tags["+draft/react"]
+	if !ok {
+		return
+	}
+	i.event(ReactionEvent{
+		From:     m.Prefix,
+		Nick:     m.Nick,
+		Target:   m.Param(0),
+		MsgID:    m.Tags["+draft/reply"],
+		Reaction: r,
+	})
+}
@@ -0,0 +1,149 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ * redact.go
+ * Delete and edit messages
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* maxSent is the number of our messages remembered per target */
+const maxSent = 32
+
+// ErrNoRedaction is returned by RedactMessage without the draft/message-redaction capability.  Add "draft/message-redaction" to i.Caps to request it.
+var ErrNoRedaction = errors.New("draft/message-redaction capability not " +
+	"enabled")
+
+// RedactEvent is sent when someone deletes a message.
+type RedactEvent struct {
+	From   string /* nick!user@host of the redactor */
+	Nick   string /* Redactor's nick */
+	Target string /* Channel or our nick */
+	MsgID  string /* Deleted message's msgid */
+	Reason string /* May be empty */
+}
+
+func (RedactEvent) event() {}
+
+// SentMessage is one of our messages as echoed back by the server.
+type SentMessage struct {
+	Command string /* PRIVMSG or NOTICE */
+	Target  string
+	Text    string
+	MsgID   string
+	Time    time.Time /* Server time, if sent */
+}
+
+// RedactMessage asks the server to delete the message to target with the given msgid, e.g. one returned by LastSent.  reason may be empty.  The draft/message-redaction capability must be enabled.  Failures are sent as ErrorEvents.
+func (i *IRC) RedactMessage(target, msgid, reason string) error {
+	if !i.HasCap("draft/message-redaction") {
+		return ErrNoRedaction
+	}
+	if "" == reason {
+		return i.PrintfLine("REDACT %v %v", target, msgid)
+	}
+	return i.PrintfLine("REDACT %v %v :%v", target, msgid, reason)
+}
+
+// EditMessage replaces the message to target with the given msgid with msg, by sending msg with a +draft/edit tag.  Clients which don't understand the tag will see msg as a new message.  The message-tags capability must be enabled.
+func (i *IRC) EditMessage(target, msgid, msg string) error {
+	return i.PrivmsgTags(msg, target, map[string]string{
+		"+draft/edit": msgid,
+	})
+}
+
+// SentMessages returns the last few messages we've sent to target, oldest first, which the server echoed with a msgid.  The echo-message and message-tags capabilities must be in i.Caps for messages to be remembered.
+func (i *IRC) SentMessages(target string) []SentMessage {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]SentMessage(nil), i.sent[fold(target)]...)
+}
+
+// LastSent returns the last message we sent to target which the server echoed with a msgid, suitable for passing to RedactMessage.  It returns false if there isn't one.
+func (i *IRC) LastSent(target string) (SentMessage, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ss := i.sent[fold(target)]
+	if 0 == len(ss) {
+		return SentMessage{}, false
+	}
+	return ss[len(ss)-1], true
+}
+
+// trackSent remembers echoes of our messages, and forgets messages which
+// have been redacted
+func (i *IRC) trackSent(m Message) {
+	switch m.Command {
+	case "PRIVMSG", "NOTICE":
+		id := m.Tags["msgid"]
+		if "" == id || !i.isMe(m.Nick) || 2 > len(m.Params) {
+			return
+		}
+		st, _ := m.Time()
+		s := SentMessage{
+			Command: m.Command,
+			Target:  m.Param(0),
+			Text:    m.Trailing(),
+			MsgID:   id,
+			Time:    st,
+		}
+		k := fold(s.Target)
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if nil == i.sent {
+			i.sent = make(map[string][]SentMessage)
+		}
+		ss := i.sent[k]
+		if maxSent <= len(ss) {
+			ss = ss[len(ss)-maxSent+1:]
+		}
+		i.sent[k] = append(ss, s)
+	case "REDACT":
+		k := fold(m.Param(0))
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		ss := i.sent[k]
+		for n, s := range ss {
+			if s.MsgID != m.Param(1) {
+				continue
+			}
+			ss = append(ss[:n:n], ss[n+1:]...)
+			if 0 == len(ss) {
+				delete(i.sent, k)
+			} else {
+				i.sent[k] = ss
+			}
+			return
+		}
+	}
+}
+
+/* handleRedact turns REDACTs and failed redactions into events */
+func (i *IRC) handleRedact(m Message) {
+	switch m.Command {
+	case "REDACT":
+		i.event(RedactEvent{
+			From:   m.Prefix,
+			Nick:   m.Nick,
+			Target: m.Param(0),
+			MsgID:  m.Param(1),
+			Reason: m.Param(2),
+		})
+	case "FAIL":
+		if "REDACT" != m.Param(0) || 3 > len(m.Params) {
+			return
+		}
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"unable to redact message: %v (%v)", m.Trailing(),
+			strings.Join(m.Params[1:len(m.Params)-1], " ")))})
+	}
+}
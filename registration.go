@@ -0,0 +1,60 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+ * registration.go
+ * Things to do once the server's accepted us
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* trackRegistration watches for registration and changes to our nick */
+func (i *IRC) trackRegistration(m Message) {
+	switch m.Command {
+	case "001": /* RPL_WELCOME */
+		i.welcomed()
+	case "NICK":
+		if i.isMe(m.Nick) {
+			i.mu.Lock()
+			i.snick = m.Param(0)
+			i.mu.Unlock()
+		}
+	}
+}
+
+// welcomed is called when the server sends 001, and does the things which
+// need doing after registration
+func (i *IRC) welcomed() {
+	i.setState(Registered)
+	if i.PassiveMode {
+		return
+	}
+	/* Pick up where the last run left off */
+	i.loadOffline()
+	/* Fall back to services if SASL didn't log us in */
+	if i.SASL && !i.Identified() && !i.failedSCRAM() {
+		if err := i.Auth(); nil != err {
+			i.event(ErrorEvent{Err: err})
+		}
+	}
+	/* Find out exactly how the server sees us, for PrivmsgSize */
+	if err := i.PrintfLine("USERHOST %v", i.SNick()); nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error requesting our hostmask: %v", err))})
+	}
+	/* Hide our host if asked */
+	i.requestCloak()
+	/* Go invisible if asked */
+	if i.Invisible {
+		if err := i.PrintfLine("MODE %v +i", i.SNick()); nil != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error setting invisible mode: %v", err))})
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package minimalirc
+
+/*
+ * reply.go
+ * Threaded replies
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* maxMsgIDs is the number of received messages remembered by msgid */
+const maxMsgIDs = 256
+
+// ReplyTo sends text in reply to msg, a PRIVMSG or NOTICE we received.  The reply goes to the channel if msg was sent to one, or to the sender otherwise.  If msg has a msgid tag and the message-tags capability is enabled, the reply carries a +draft/reply tag, which clients on networks such as those running Ergo may show as a thread.  Otherwise it's sent as a plain PRIVMSG.
+func (i *IRC) ReplyTo(msg Message, text string) error {
+	t := i.replyTarget(msg)
+	id := msg.Tags["msgid"]
+	if "" == id || !i.HasCap("message-tags") {
+		return i.Privmsg(text, t)
+	}
+	return i.PrivmsgTags(text, t, map[string]string{"+draft/reply": id})
+}
+
+// replyTarget returns where a reply to m should go: the channel it was sent
+// to, or its sender if it was sent to us
+func (i *IRC) replyTarget(m Message) string {
+	if t := m.Param(0); "" != t && !i.isMe(t) {
+		return t
+	}
+	return m.Nick
+}
+
+// MessageByID returns a recently-received PRIVMSG or NOTICE with the given msgid.  The last few hundred messages with msgids are remembered.  The message-tags capability must be in i.Caps for servers to send msgids.
+func (i *IRC) MessageByID(msgid string) (Message, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	m, ok := i.byID[msgid]
+	return m, ok
+}
+
+// RepliedTo returns the message to which m is a reply, per its +draft/reply tag, if m is a reply and the message is one MessageByID remembers.
+func (i *IRC) RepliedTo(m Message) (Message, bool) {
+	id, ok := m.Tags["+draft/reply"]
+	if !ok {
+		return Message{}, false
+	}
+	return i.MessageByID(id)
+}
+
+/* trackMsgIDs remembers messages with msgids for MessageByID */
+func (i *IRC) trackMsgIDs(m Message) {
+	if "PRIVMSG" != m.Command && "NOTICE" != m.Command {
+		return
+	}
+	id := m.Tags["msgid"]
+	if "" == id {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.byID {
+		i.byID = make(map[string]Message)
+		i.idRing = make([]string, maxMsgIDs)
+	}
+	/* Forget the oldest */
+	delete(i.byID, i.idRing[i.idNext])
+	i.idRing[i.idNext] = id
+	i.idNext = (i.idNext + 1) % len(i.idRing)
+	i.byID[id] = m
+}
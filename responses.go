@@ -0,0 +1,247 @@
+package minimalirc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+/*
+ * responses.go
+ * Canned replies to things people say
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ResponseScope says where a Response applies.
+type ResponseScope string
+
+// Where Responses apply.
+const (
+	ScopeAll      ResponseScope = ""         /* Channels and private messages */
+	ScopeChannels ResponseScope = "channels" /* Only in channels */
+	ScopePrivate  ResponseScope = "private"  /* Only in private messages */
+)
+
+// Response is an entry in the table given to SetResponses.  When someone else says something matching Pattern, Reply is sent where ReplyTo would send it, with ${nick}, ${channel}, ${target}, and the pattern's groups (e.g. $1 or ${name}) expanded.  $$ is a literal $.  In JSON, Cooldown may be a string like "30s" as well as a number of nanoseconds.
+type Response struct {
+	Pattern     string        `json:"pattern"`     /* Regular expression */
+	Reply       string        `json:"reply"`       /* Text to send */
+	Action      bool          `json:"action"`      /* Send a CTCP ACTION */
+	Probability float64       `json:"probability"` /* Chance of replying, 1 if 0 */
+	Cooldown    time.Duration `json:"cooldown"`    /* Per target */
+	Scope       ResponseScope `json:"scope"`       /* Where it applies */
+	Channels    []string      `json:"channels"`    /* Limits it, if not empty */
+}
+
+/* response is a Response, ready to use */
+type response struct {
+	Response
+	re   *regexp.Regexp
+	last map[string]time.Time /* Last reply, by fold(target) */
+}
+
+// UnmarshalJSON decodes a Response, allowing Cooldown to be a string.
+func (r *Response) UnmarshalJSON(b []byte) error {
+	type plain Response
+	var v struct {
+		plain
+		Cooldown json.RawMessage `json:"cooldown"`
+	}
+	if err := json.Unmarshal(b, &v); nil != err {
+		return err
+	}
+	*r = Response(v.plain)
+	if 0 == len(v.Cooldown) || "null" == string(v.Cooldown) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(v.Cooldown, &s); nil != err {
+		return json.Unmarshal(v.Cooldown, &r.Cooldown)
+	}
+	d, err := time.ParseDuration(s)
+	if nil != err {
+		return errors.New(fmt.Sprintf("invalid cooldown %q: %v", s, err))
+	}
+	r.Cooldown = d
+	return nil
+}
+
+// SetResponses replaces the table of automatic responses with rs.  For each PRIVMSG from someone else, the first Response whose Pattern matches, which applies where it was sent, and which isn't cooling down from its last reply there is rolled for.  If the roll succeeds, its Reply is sent.  Either way, no other Response is tried.  If any Pattern is invalid or any Probability isn't between 0 and 1, an error is returned and the table is unchanged.  Errors sending replies are sent as ErrorEvents.  Calling SetResponses with nil stops responding.
+func (i *IRC) SetResponses(rs []Response) error {
+	var table []*response
+	for n, r := range rs {
+		re, err := regexp.Compile(r.Pattern)
+		if nil != err {
+			return errors.New(fmt.Sprintf("response %v: %v", n, err))
+		}
+		if 0 > r.Probability || 1 < r.Probability {
+			return errors.New(fmt.Sprintf("response %v: probability %v "+
+				"not between 0 and 1", n, r.Probability))
+		}
+		switch r.Scope {
+		case ScopeAll, ScopeChannels, ScopePrivate:
+		default:
+			return errors.New(fmt.Sprintf("response %v: unknown scope "+
+				"%q", n, r.Scope))
+		}
+		r.Channels = append([]string(nil), r.Channels...)
+		table = append(table, &response{
+			Response: r,
+			re:       re,
+			last:     make(map[string]time.Time),
+		})
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.responses = table
+	return nil
+}
+
+// LoadResponses reads a JSON array of Responses from r and passes them to SetResponses.
+func (i *IRC) LoadResponses(r io.Reader) error {
+	var rs []Response
+	if err := json.NewDecoder(r).Decode(&rs); nil != err {
+		return errors.New(fmt.Sprintf("unable to decode responses: %v",
+			err))
+	}
+	return i.SetResponses(rs)
+}
+
+// Responses returns the table of automatic responses set with SetResponses.
+func (i *IRC) Responses() []Response {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	rs := make([]Response, len(i.responses))
+	for n, r := range i.responses {
+		rs[n] = r.Response
+		rs[n].Channels = append([]string(nil), r.Channels...)
+	}
+	return rs
+}
+
+/* appliesIn returns true if r may reply to a message sent to target */
+func (r *response) appliesIn(target string, private bool) bool {
+	switch {
+	case private:
+		return ScopeChannels != r.Scope
+	case ScopePrivate == r.Scope:
+		return false
+	case 0 == len(r.Channels):
+		return true
+	}
+	for _, c := range r.Channels {
+		if fold(c) == fold(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand makes r's reply to m, sent in channel (which is the empty string
+// for private messages), given the submatch indices in match
+func (r *response) expand(
+	m Message,
+	target string,
+	channel string,
+	match []int,
+) string {
+	text := m.Trailing()
+	group := func(n int) string {
+		if 0 > n || len(match) <= 2*n+1 || 0 > match[2*n] {
+			return ""
+		}
+		return text[match[2*n]:match[2*n+1]]
+	}
+	return os.Expand(r.Reply, func(name string) string {
+		switch name {
+		case "$":
+			return "$"
+		case "nick":
+			return m.Nick
+		case "channel":
+			return channel
+		case "target":
+			return target
+		}
+		if n, err := strconv.Atoi(name); nil == err {
+			return group(n)
+		}
+		return group(r.re.SubexpIndex(name))
+	})
+}
+
+/* handleResponses sends an automatic response to m, if one applies */
+func (i *IRC) handleResponses(m Message) {
+	if "PRIVMSG" != m.Command || "" == m.Nick || i.isMe(m.Nick) {
+		return
+	}
+	text := m.Trailing()
+	if _, _, ok := ParseCTCP(text); ok {
+		return
+	}
+	target := i.replyTarget(m)
+	private := i.isMe(m.Param(0))
+	_, channel := i.SplitStatus(target)
+	if private {
+		channel = ""
+	}
+	now := time.Now()
+
+	/* Find the response, and note we're using it */
+	i.mu.Lock()
+	var (
+		r     *response
+		match []int
+	)
+	for _, c := range i.responses {
+		if !c.appliesIn(channel, private) {
+			continue
+		}
+		if match = c.re.FindStringSubmatchIndex(text); nil == match {
+			continue
+		}
+		if l, ok := c.last[fold(target)]; ok &&
+			now.Sub(l) < c.Cooldown {
+			continue
+		}
+		r = c
+		break
+	}
+	if nil == r || !i.roll(r.Probability) {
+		i.mu.Unlock()
+		return
+	}
+	r.last[fold(target)] = now
+	i.mu.Unlock()
+
+	/* Send the reply */
+	reply := r.expand(m, target, channel, match)
+	var err error
+	if r.Action {
+		err = i.CTCP(target, "ACTION", reply)
+	} else {
+		err = i.ReplyTo(m, reply)
+	}
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error responding to %v in %v: %v", m.Nick, target, err))})
+	}
+}
+
+/* roll returns true with probability p, or always if p is 0 */
+func (i *IRC) roll(p float64) bool {
+	if 0 == p || 1 <= p {
+		return true
+	}
+	i.rngmu.Lock()
+	defer i.rngmu.Unlock()
+	return i.rng.Float64() < p
+}
@@ -0,0 +1,104 @@
+package minimalirc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/*
+ * retry.go
+ * Keep trying to connect
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* Defaults for RetryPolicy */
+const (
+	defaultRetryMin    = time.Second
+	defaultRetryMax    = 5 * time.Minute
+	defaultRetryFactor = 2
+)
+
+// RetryPolicy says how ConnectWithRetry waits between attempts.  The delay starts at Min and is multiplied by Factor after each failure, up to Max.  Each delay is then shortened by a random fraction of up to Jitter (between 0 and 1) of itself, so many clients don't all retry at once.
+type RetryPolicy struct {
+	Min         time.Duration /* First delay, 1s if 0 */
+	Max         time.Duration /* Longest delay, 5m if 0 */
+	Factor      float64       /* Delay multiplier, 2 if 0 */
+	Jitter      float64       /* Max fraction of a delay to skip */
+	MaxAttempts int           /* Give up after this many, never if 0 */
+}
+
+// ConnectAttempt records a failed attempt to connect.
+type ConnectAttempt struct {
+	Time time.Time /* When the attempt started */
+	Err  error
+}
+
+// RetryError is returned by ConnectWithRetry when it gives up.  It unwraps to the last attempt's error, or the context's error if the context ended the retries.
+type RetryError struct {
+	Attempts []ConnectAttempt /* Every failed attempt */
+	Err      error            /* Why we gave up */
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("gave up connecting after %v attempts: %v",
+		len(e.Attempts), e.Err)
+}
+
+// Unwrap returns e.Err.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// ConnectWithRetry calls Connect until it succeeds, waiting between attempts as p says.  Connect fails when the server can't be reached or the registration lines can't be sent; the connection ending later (e.g. because the server refused to register us) is reported on i.E as usual.  If p.MaxAttempts attempts fail or ctx is done first, a *RetryError is returned.
+func (i *IRC) ConnectWithRetry(ctx context.Context, p RetryPolicy) error {
+	if 0 >= p.Min {
+		p.Min = defaultRetryMin
+	}
+	if 0 >= p.Max {
+		p.Max = defaultRetryMax
+	}
+	if 0 >= p.Factor {
+		p.Factor = defaultRetryFactor
+	}
+	var (
+		as    []ConnectAttempt
+		delay = p.Min
+	)
+	for {
+		a := ConnectAttempt{Time: time.Now()}
+		if a.Err = i.Connect(); nil == a.Err {
+			return nil
+		}
+		as = append(as, a)
+		if 0 < p.MaxAttempts && len(as) >= p.MaxAttempts {
+			return &RetryError{Attempts: as, Err: a.Err}
+		}
+		t := time.NewTimer(i.retryJitter(delay, p.Jitter))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return &RetryError{Attempts: as, Err: ctx.Err()}
+		}
+		if delay = time.Duration(float64(delay) * p.Factor); delay > p.Max {
+			delay = p.Max
+		}
+	}
+}
+
+/* retryJitter shortens d by a random fraction of up to jitter of d */
+func (i *IRC) retryJitter(d time.Duration, jitter float64) time.Duration {
+	if 0 >= jitter {
+		return d
+	}
+	if 1 < jitter {
+		jitter = 1
+	}
+	i.rngmu.Lock()
+	defer i.rngmu.Unlock()
+	return d - time.Duration(jitter*i.rng.Float64()*float64(d))
+}
@@ -0,0 +1,413 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+ * roles.go
+ * Who may run which bot commands
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* i.Store namespace and key for granted roles */
+const (
+	rolesNS  = "roles"
+	rolesKey = "grants"
+)
+
+/* whoxToken marks the WHOX replies we asked for to learn accounts */
+const whoxToken = "694"
+
+// Role is what a sender may do with bot commands.  Each Role may do everything the Roles below it may do.
+type Role int
+
+// Roles, least powerful first.
+const (
+	RoleNone  Role = iota /* Anybody */
+	RoleUser              /* Someone we know */
+	RoleAdmin             /* Someone we trust */
+	RoleOwner             /* Someone who can do anything */
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleNone:
+		return "none"
+	case RoleUser:
+		return "user"
+	case RoleAdmin:
+		return "admin"
+	case RoleOwner:
+		return "owner"
+	}
+	return "unknown"
+}
+
+// ParseRole returns the Role named s, which is case-insensitive, as returned by Role's String method.
+func ParseRole(s string) (Role, error) {
+	for r := RoleNone; r <= RoleOwner; r++ {
+		if strings.EqualFold(s, r.String()) {
+			return r, nil
+		}
+	}
+	return RoleNone, errors.New(fmt.Sprintf("unknown role %q", s))
+}
+
+// MarshalText returns the Role's name, so Roles are readable in JSON.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText parses a Role's name, as for ParseRole.
+func (r *Role) UnmarshalText(b []byte) error {
+	p, err := ParseRole(string(b))
+	if nil != err {
+		return err
+	}
+	*r = p
+	return nil
+}
+
+// Grant is a Role given to everyone logged in to Account, or everyone whose nick!user@host matches Mask.  Only one of Account and Mask is set.
+type Grant struct {
+	Account string
+	Mask    string
+	Role    Role
+}
+
+// DeniedCommandEvent is sent when a command isn't run because its sender doesn't have the Role RequireRole says it needs.
+type DeniedCommandEvent struct {
+	From     string /* nick!user@host of the sender */
+	Account  string /* Sender's account, if known */
+	Command  string /* Name of the command */
+	Role     Role   /* Sender's role */
+	Required Role   /* Role needed to run the command */
+}
+
+func (DeniedCommandEvent) event() {}
+
+/* roleGrants are the roles we've granted, as kept in i.Store */
+type roleGrants struct {
+	Accounts map[string]Role `json:"accounts"` /* By fold(account) */
+	Masks    map[string]Role `json:"masks"`
+}
+
+// RequireRole makes the command name only runnable by senders with at least Role r.  For a subcommand registered with HandleSubcommand, name may be the command and subcommand separated by a space, in which case that Role is needed instead of the command's.  Requiring RoleNone lets anybody run it again.  Senders' roles come from GrantAccount and GrantMask.
+func (i *IRC) RequireRole(name string, r Role) {
+	name = strings.ToLower(name)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if RoleNone == r {
+		delete(i.cmdRoles, name)
+		return
+	}
+	if nil == i.cmdRoles {
+		i.cmdRoles = make(map[string]Role)
+	}
+	i.cmdRoles[name] = r
+}
+
+// GrantAccount gives r to whoever's logged in to account.  Accounts are taken from the account tag (the account-tag capability), or learned from JOINs (extended-join), ACCOUNT messages (account-notify), and WHOX replies for channels we join once RequireRole has been called; these capabilities should be in i.Caps.  Granting RoleNone is the same as RevokeAccount.  If i.Store is set, grants are kept there.
+func (i *IRC) GrantAccount(account string, r Role) {
+	i.grant(&i.grants.Accounts, fold(account), r)
+}
+
+// GrantMask gives r to everyone whose nick!user@host matches mask (see MatchMask).  Granting RoleNone is the same as RevokeMask.  If i.Store is set, grants are kept there.
+func (i *IRC) GrantMask(mask string, r Role) {
+	i.grant(&i.grants.Masks, mask, r)
+}
+
+// RevokeAccount takes away the Role given to account with GrantAccount.  It returns false if account hadn't been given one.
+func (i *IRC) RevokeAccount(account string) bool {
+	return i.revoke(&i.grants.Accounts, fold(account))
+}
+
+// RevokeMask takes away the Role given to mask with GrantMask.  It returns false if mask hadn't been given one.
+func (i *IRC) RevokeMask(mask string) bool {
+	return i.revoke(&i.grants.Masks, mask)
+}
+
+/* grant sets *m[k] to r, and saves the grants */
+func (i *IRC) grant(m *map[string]Role, k string, r Role) {
+	if RoleNone == r {
+		i.revoke(m, k)
+		return
+	}
+	i.loadRoles()
+	i.mu.Lock()
+	if nil == *m {
+		*m = make(map[string]Role)
+	}
+	(*m)[k] = r
+	i.mu.Unlock()
+	i.saveRoles()
+}
+
+/* revoke removes k from *m, and saves the grants if it was there */
+func (i *IRC) revoke(m *map[string]Role, k string) bool {
+	i.loadRoles()
+	i.mu.Lock()
+	_, ok := (*m)[k]
+	delete(*m, k)
+	i.mu.Unlock()
+	if ok {
+		i.saveRoles()
+	}
+	return ok
+}
+
+// Grants returns the Roles given with GrantAccount and GrantMask, most powerful first.
+func (i *IRC) Grants() []Grant {
+	i.loadRoles()
+	i.mu.Lock()
+	var gs []Grant
+	for a, r := range i.grants.Accounts {
+		gs = append(gs, Grant{Account: a, Role: r})
+	}
+	for m, r := range i.grants.Masks {
+		gs = append(gs, Grant{Mask: m, Role: r})
+	}
+	i.mu.Unlock()
+	sort.Slice(gs, func(j, k int) bool {
+		if gs[j].Role != gs[k].Role {
+			return gs[j].Role > gs[k].Role
+		}
+		/* Accounts before masks */
+		if ("" == gs[j].Mask) != ("" == gs[k].Mask) {
+			return "" == gs[j].Mask
+		}
+		return gs[j].Account+gs[j].Mask < gs[k].Account+gs[k].Mask
+	})
+	return gs
+}
+
+// RoleOf returns the most powerful Role given to the sender of m, and their account, if it's known.  If the account-tag capability is enabled, the account comes only from m's account tag, and a message without one is from someone who's not logged in.
+func (i *IRC) RoleOf(m Message) (Role, string) {
+	i.loadRoles()
+	tagged := i.HasCap("account-tag")
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	account, ok := m.Tags["account"]
+	if !ok && !tagged {
+		account = i.accounts[fold(m.Nick)]
+	}
+	if "*" == account {
+		account = ""
+	}
+	r := RoleNone
+	if "" != account {
+		r = i.grants.Accounts[fold(account)]
+	}
+	if "" == m.User {
+		return r, account
+	}
+	for mask, mr := range i.grants.Masks {
+		if mr > r && MatchMask(mask, m.Prefix) {
+			r = mr
+		}
+	}
+	return r, account
+}
+
+// AccountOf returns the services account nick's logged in to, if it's been learned as described for GrantAccount.
+func (i *IRC) AccountOf(nick string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	a, ok := i.accounts[fold(nick)]
+	return a, ok
+}
+
+// permitCommand returns true if c's sender has the Role needed to run it.
+// If not, it sends a DeniedCommandEvent.
+func (i *IRC) permitCommand(c *Command) bool {
+	c.Role, c.Account = i.RoleOf(c.Message)
+	i.mu.Lock()
+	need, ok := i.cmdRoles[c.Name+" "+c.Subcommand]
+	if !ok {
+		need = i.cmdRoles[c.Name]
+	}
+	i.mu.Unlock()
+	if c.Role >= need {
+		return true
+	}
+	i.event(DeniedCommandEvent{
+		From:     c.Message.Prefix,
+		Account:  c.Account,
+		Command:  c.Name,
+		Role:     c.Role,
+		Required: need,
+	})
+	return false
+}
+
+// loadRoles adds the grants in i.Store, if it's set, to the grants.  Only the
+// first call with i.Store set has any effect.
+func (i *IRC) loadRoles() {
+	if nil == i.Store {
+		return
+	}
+	i.mu.Lock()
+	loaded := i.roleLoaded
+	i.roleLoaded = true
+	i.mu.Unlock()
+	var g roleGrants
+	if loaded || !i.loadJSON(rolesNS, rolesKey, &g) {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.grants.Accounts {
+		i.grants.Accounts = make(map[string]Role)
+	}
+	if nil == i.grants.Masks {
+		i.grants.Masks = make(map[string]Role)
+	}
+	/* Grants made before loading win */
+	for a, r := range g.Accounts {
+		if _, ok := i.grants.Accounts[a]; !ok {
+			i.grants.Accounts[a] = r
+		}
+	}
+	for m, r := range g.Masks {
+		if _, ok := i.grants.Masks[m]; !ok {
+			i.grants.Masks[m] = r
+		}
+	}
+}
+
+/* saveRoles puts the grants in i.Store, if it's set */
+func (i *IRC) saveRoles() {
+	if nil == i.Store {
+		return
+	}
+	i.mu.Lock()
+	g := roleGrants{
+		Accounts: make(map[string]Role, len(i.grants.Accounts)),
+		Masks:    make(map[string]Role, len(i.grants.Masks)),
+	}
+	for a, r := range i.grants.Accounts {
+		g.Accounts[a] = r
+	}
+	for m, r := range i.grants.Masks {
+		g.Masks[m] = r
+	}
+	i.mu.Unlock()
+	i.storeJSON(rolesNS, rolesKey, g)
+}
+
+/* setAccount notes nick's account, or forgets it if account is "" */
+func (i *IRC) setAccount(nick, account string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if "" == account {
+		delete(i.accounts, fold(nick))
+		return
+	}
+	if nil == i.accounts {
+		i.accounts = make(map[string]string)
+	}
+	i.accounts[fold(nick)] = account
+}
+
+// trackAccounts keeps track of others' accounts, for RoleOf.  It's called
+// after trackChannels, so we know who's left our channels.
+func (i *IRC) trackAccounts(m Message) {
+	switch m.Command {
+	case "ACCOUNT": /* account-notify, * if logged out */
+		a := m.Param(0)
+		if "*" == a {
+			a = ""
+		}
+		i.setAccount(m.Nick, a)
+	case "JOIN": /* With extended-join, channel account :realname */
+		if 3 == len(m.Params) {
+			a := m.Param(1)
+			if "*" == a {
+				a = ""
+			}
+			i.setAccount(m.Nick, a)
+		}
+		if i.isMe(m.Nick) {
+			i.whoAccounts(m.Param(0))
+		}
+	case "354": /* RPL_WHOSPCRPL, me token nick account */
+		if 4 != len(m.Params) || whoxToken != m.Param(1) {
+			return
+		}
+		a := m.Param(3)
+		if "0" == a {
+			a = ""
+		}
+		i.setAccount(m.Param(2), a)
+	case "NICK":
+		i.mu.Lock()
+		if a, ok := i.accounts[fold(m.Nick)]; ok {
+			delete(i.accounts, fold(m.Nick))
+			i.accounts[fold(m.Param(0))] = a
+		}
+		i.mu.Unlock()
+	case "QUIT":
+		i.setAccount(m.Nick, "")
+	case "PART":
+		if i.isMe(m.Nick) {
+			i.forgetStrangers()
+		} else if 0 == len(i.CommonChannels(m.Nick)) {
+			i.setAccount(m.Nick, "")
+		}
+	case "KICK":
+		if i.isMe(m.Param(1)) {
+			i.forgetStrangers()
+		} else if 0 == len(i.CommonChannels(m.Param(1))) {
+			i.setAccount(m.Param(1), "")
+		}
+	}
+}
+
+// forgetStrangers forgets the accounts of nicks which aren't in any of our
+// channels, as we'll no longer see them QUIT or change nick, and someone else
+// could take the nick
+func (i *IRC) forgetStrangers() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n := range i.accounts {
+		shared := false
+		for _, c := range i.channels {
+			if _, ok := c.members[n]; ok {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			delete(i.accounts, n)
+		}
+	}
+}
+
+// whoAccounts asks for the accounts of channel's members with WHOX, if the
+// server supports it and any command needs a Role
+func (i *IRC) whoAccounts(channel string) {
+	if i.PassiveMode {
+		return
+	}
+	if _, ok := i.ISupport("WHOX"); !ok {
+		return
+	}
+	i.mu.Lock()
+	need := 0 != len(i.cmdRoles)
+	i.mu.Unlock()
+	if !need {
+		return
+	}
+	if err := i.PrintfLine("WHO %v %%tna,%v", channel,
+		whoxToken); nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error asking for accounts in %v: %v", channel, err))})
+	}
+}
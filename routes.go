@@ -0,0 +1,101 @@
+package minimalirc
+
+/*
+ * routes.go
+ * Per-channel and per-nick streams of PRIVMSGs
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* routeBuffer is the number of messages a route holds before dropping */
+const routeBuffer = 64
+
+/* routeKey identifies a route */
+type routeKey struct {
+	query bool   /* Private messages from a nick, not a channel */
+	name  string /* fold(channel or nick) */
+}
+
+// RouteFullEvent is sent when a PRIVMSG is dropped because a channel returned by ChannelC or QueryC is full.
+type RouteFullEvent struct {
+	Target  string /* Channel or nick passed to ChannelC or QueryC */
+	Message Message
+}
+
+func (RouteFullEvent) event() {}
+
+// ChannelC returns a channel on which PRIVMSGs sent to channel are sent.  It's made on the first call for channel and returned by subsequent calls, across reconnects, until CloseChannelC is called.  Messages are still sent on i.C and to handlers as well.  The channel is buffered; if it fills, messages are dropped and a RouteFullEvent is sent for each.  Messages from ignored nicks aren't sent.
+func (i *IRC) ChannelC(channel string) <-chan Message {
+	return i.routeC(routeKey{name: fold(channel)})
+}
+
+// QueryC is like ChannelC, but for PRIVMSGs from nick sent directly to us.
+func (i *IRC) QueryC(nick string) <-chan Message {
+	return i.routeC(routeKey{query: true, name: fold(nick)})
+}
+
+// CloseChannelC closes and forgets the channel returned by ChannelC for channel, if there is one.
+func (i *IRC) CloseChannelC(channel string) {
+	i.closeRoute(routeKey{name: fold(channel)})
+}
+
+// CloseQueryC closes and forgets the channel returned by QueryC for nick, if there is one.
+func (i *IRC) CloseQueryC(nick string) {
+	i.closeRoute(routeKey{query: true, name: fold(nick)})
+}
+
+/* routeC returns the channel for k, making it if need be */
+func (i *IRC) routeC(k routeKey) <-chan Message {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if c, ok := i.routes[k]; ok {
+		return c
+	}
+	if nil == i.routes {
+		i.routes = make(map[routeKey]chan Message)
+	}
+	c := make(chan Message, routeBuffer)
+	i.routes[k] = c
+	return c
+}
+
+/* closeRoute closes and removes the channel for k */
+func (i *IRC) closeRoute(k routeKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if c, ok := i.routes[k]; ok {
+		close(c)
+		delete(i.routes, k)
+	}
+}
+
+/* route sends m to the channel for its target, if there is one */
+func (i *IRC) route(m Message) {
+	if "PRIVMSG" != m.Command {
+		return
+	}
+	/* Messages to some of a channel's members are still in the channel */
+	_, t := i.SplitStatus(m.Param(0))
+	k := routeKey{name: fold(t)}
+	if !isChannel(t) {
+		t = m.Nick
+		k = routeKey{query: true, name: fold(t)}
+	}
+	/* Sending under the lock keeps the channel from being closed */
+	i.mu.Lock()
+	c, ok := i.routes[k]
+	sent := true
+	if ok {
+		select {
+		case c <- m:
+		default:
+			sent = false
+		}
+	}
+	i.mu.Unlock()
+	if !sent {
+		i.event(RouteFullEvent{Target: t, Message: m})
+	}
+}
@@ -0,0 +1,266 @@
+package minimalirc
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * sasl.go
+ * Log in with SASL during registration
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* saslChunk is the most base64 sent or received per AUTHENTICATE */
+const saslChunk = 400
+
+// SASLEvent is sent when logging in with a SASL mechanism succeeds or fails.  If i.SASL is set and every mechanism fails, or the server doesn't offer SASL, Auth is called once we've registered, unless the server got SCRAM-SHA-256 wrong.
+type SASLEvent struct {
+	Mechanism string
+	OK        bool
+	Text      string /* What the server said */
+}
+
+func (SASLEvent) event() {}
+
+/* saslState is a SASL login in progress */
+type saslState struct {
+	mechs []string /* Mechanisms left to try */
+	mech  string   /* Mechanism being tried */
+	buf   string   /* Base64 from the server, until it's all here */
+	scram *scram   /* SCRAM-SHA-256 exchange, if it's being tried */
+}
+
+/* wantedCaps returns i.Caps, with sasl if i.SASL is set */
+func (i *IRC) wantedCaps() []string {
+	if !i.SASL {
+		return i.Caps
+	}
+	for _, c := range i.Caps {
+		if "sasl" == c {
+			return i.Caps
+		}
+	}
+	return append(append([]string{}, i.Caps...), "sasl")
+}
+
+// saslMechs returns the mechanisms we're able to use, in order of
+// preference: EXTERNAL if we've a client certificate, then SCRAM-SHA-256 and
+// PLAIN if we've credentials.  If the server listed the mechanisms it
+// supports, others are left out, as is PLAIN if the server supports
+// SCRAM-SHA-256, so the password's never sent if it needn't be.
+func (i *IRC) saslMechs() []string {
+	var ms []string
+	if nil != i.ClientCert {
+		ms = append(ms, "EXTERNAL")
+	}
+	if idNick, idPass := i.creds(); "" != idNick && "" != idPass {
+		ms = append(ms, "SCRAM-SHA-256", "PLAIN")
+	}
+	v, _ := i.CapValue("sasl")
+	if "" == v {
+		return ms
+	}
+	ok := strings.Split(v, ",")
+	if 0 != len(filterMechs([]string{"SCRAM-SHA-256"}, ok)) {
+		ok = filterOut(ok, "PLAIN")
+	}
+	return filterMechs(ms, ok)
+}
+
+/* filterOut returns the mechanisms in ms other than m */
+func filterOut(ms []string, m string) []string {
+	var fs []string
+	for _, n := range ms {
+		if !strings.EqualFold(m, n) {
+			fs = append(fs, n)
+		}
+	}
+	return fs
+}
+
+/* filterMechs returns the mechanisms in ms which are also in ok */
+func filterMechs(ms, ok []string) []string {
+	var fs []string
+	for _, m := range ms {
+		for _, o := range ok {
+			if strings.EqualFold(m, o) {
+				fs = append(fs, m)
+				break
+			}
+		}
+	}
+	return fs
+}
+
+// startSASL starts logging in with SASL, if we're registering, i.SASL is set,
+// and there's a mechanism we can use.  Capability negotiation doesn't end
+// until it's done.
+func (i *IRC) startSASL() error {
+	if !i.SASL || i.PassiveMode || Registered == i.State() {
+		return nil
+	}
+	ms := i.saslMechs()
+	if 0 == len(ms) {
+		return nil
+	}
+	i.mu.Lock()
+	i.sasl = &saslState{mechs: ms}
+	i.mu.Unlock()
+	return i.nextSASL()
+}
+
+// nextSASL tries the next mechanism, or gives up on SASL if there are none
+// left to try
+func (i *IRC) nextSASL() error {
+	i.mu.Lock()
+	s := i.sasl
+	if nil == s {
+		i.mu.Unlock()
+		return nil
+	}
+	if 0 == len(s.mechs) {
+		i.sasl = nil
+		i.mu.Unlock()
+		return i.maybeEndCaps()
+	}
+	s.mech, s.mechs = s.mechs[0], s.mechs[1:]
+	s.buf = ""
+	s.scram = nil
+	i.mu.Unlock()
+	idNick, _ := i.creds()
+	i.audit(AuditAuth, "SASL %v as %v", s.mech, idNick)
+	if err := i.PrintfLine("AUTHENTICATE %v", s.mech); nil != err {
+		return errors.New(fmt.Sprintf("error starting SASL %v: %v",
+			s.mech, err))
+	}
+	return nil
+}
+
+/* trackSASL handles the server's side of a SASL login */
+func (i *IRC) trackSASL(m Message) {
+	i.mu.Lock()
+	s := i.sasl
+	i.mu.Unlock()
+	if nil == s {
+		return
+	}
+	var err error
+	switch m.Command {
+	case "AUTHENTICATE":
+		err = i.answerSASL(s, m.Param(0))
+	case "903": /* RPL_SASLSUCCESS */
+		i.mu.Lock()
+		i.sasl = nil
+		i.mu.Unlock()
+		i.event(SASLEvent{Mechanism: s.mech, OK: true,
+			Text: m.Trailing()})
+		err = i.maybeEndCaps()
+	/* ERR_SASLFAIL, ERR_SASLTOOLONG, ERR_SASLABORTED */
+	case "904", "905", "906":
+		i.event(SASLEvent{Mechanism: s.mech, Text: m.Trailing()})
+		err = i.nextSASL()
+	case "907": /* ERR_SASLALREADY */
+		i.mu.Lock()
+		i.sasl = nil
+		i.mu.Unlock()
+		err = i.maybeEndCaps()
+	case "908": /* RPL_SASLMECHS */
+		i.mu.Lock()
+		s.mechs = filterMechs(s.mechs, strings.Split(m.Param(1), ","))
+		i.mu.Unlock()
+	}
+	if nil != err {
+		i.event(ErrorEvent{Err: err})
+	}
+}
+
+// answerSASL gathers a challenge from the server, which arrives in chunks,
+// and answers it when it's all here
+func (i *IRC) answerSASL(s *saslState, chunk string) error {
+	if "+" != chunk {
+		s.buf += chunk
+	}
+	/* A full chunk means there's more to come */
+	if saslChunk == len(chunk) {
+		return nil
+	}
+	c, err := base64.StdEncoding.DecodeString(s.buf)
+	s.buf = ""
+	if nil != err {
+		i.PrintfLine("AUTHENTICATE *")
+		return errors.New(fmt.Sprintf("bad SASL %v challenge: %v",
+			s.mech, err))
+	}
+	r, err := i.saslResponse(s, c)
+	if nil != err {
+		/* Don't give an impostor anything else */
+		if errors.Is(err, ErrSCRAMServer) {
+			i.mu.Lock()
+			s.mechs = nil
+			i.scramBad = true
+			i.mu.Unlock()
+		}
+		i.PrintfLine("AUTHENTICATE *")
+		return err
+	}
+	return i.sendSASL(r)
+}
+
+/* saslResponse works out the response to the challenge c */
+func (i *IRC) saslResponse(s *saslState, c []byte) ([]byte, error) {
+	idNick, idPass := i.creds()
+	switch s.mech {
+	case "EXTERNAL": /* The server has our certificate */
+		return nil, nil
+	case "PLAIN":
+		return []byte("\x00" + idNick + "\x00" + idPass), nil
+	case "SCRAM-SHA-256":
+		if nil == s.scram {
+			var err error
+			if s.scram, err = newSCRAM(idNick, idPass); nil != err {
+				return nil, err
+			}
+		}
+		return s.scram.step(c)
+	}
+	return nil, errors.New(fmt.Sprintf("unsupported SASL mechanism %v",
+		s.mech))
+}
+
+// sendSASL sends r to the server base64-encoded, in chunks, with an empty
+// chunk if the last one's full
+func (i *IRC) sendSASL(r []byte) error {
+	b := base64.StdEncoding.EncodeToString(r)
+	for {
+		n := len(b)
+		if saslChunk < n {
+			n = saslChunk
+		}
+		c := b[:n]
+		if "" == c {
+			c = "+"
+		}
+		if err := i.PrintfLine("AUTHENTICATE %v", c); nil != err {
+			return errors.New(fmt.Sprintf("error sending SASL "+
+				"response: %v", err))
+		}
+		b = b[n:]
+		if saslChunk != n {
+			return nil
+		}
+	}
+}
+
+// failedSCRAM returns true if the server couldn't prove it knew our password
+// during SCRAM-SHA-256
+func (i *IRC) failedSCRAM() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.scramBad
+}
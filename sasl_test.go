@@ -0,0 +1,442 @@
+package minimalirc
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+ * sasl_test.go
+ * Test logging in with SASL
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* sentLines is a connection which remembers what's sent to the server */
+type sentLines struct {
+	sync.Mutex
+	buf string
+}
+
+/* Read returns io.EOF */
+func (*sentLines) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+/* Write remembers p */
+func (s *sentLines) Write(p []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.buf += string(p)
+	return len(p), nil
+}
+
+/* take returns the lines sent since the last call to take */
+func (s *sentLines) take() []string {
+	s.Lock()
+	defer s.Unlock()
+	ls := strings.Split(strings.TrimSuffix(s.buf, "\r\n"), "\r\n")
+	if "" == s.buf {
+		ls = nil
+	}
+	s.buf = ""
+	return ls
+}
+
+/* saslTest is an IRC struct, set up to log in with SASL */
+type saslTest struct {
+	t      *testing.T
+	i      *IRC
+	sent   *sentLines
+	events []Event
+}
+
+/* newSASLTest returns a saslTest logging in as user with pass */
+func newSASLTest(t *testing.T, user, pass string) *saslTest {
+	st := &saslTest{
+		t:    t,
+		i:    New("irc.example.com", 6697, true, "", "nick", "u", "r"),
+		sent: &sentLines{},
+	}
+	st.i.SASL = true
+	st.i.IdNick = user
+	st.i.IdPass = pass
+	st.i.OnEvent = func(e Event) { st.events = append(st.events, e) }
+	st.i.use(st.sent)
+	return st
+}
+
+// feed feeds line to the library, and checks the lines it sends in reply are
+// want
+func (st *saslTest) feed(line string, want ...string) {
+	st.t.Helper()
+	st.i.Feed(line)
+	got := st.sent.take()
+	if len(got) != len(want) {
+		st.t.Fatalf("after %q, sent %q, not %q", line, got, want)
+	}
+	for n := range got {
+		if got[n] != want[n] {
+			st.t.Fatalf("after %q, sent %q, not %q", line, got, want)
+		}
+	}
+}
+
+/* startSCRAM negotiates sasl and starts SCRAM-SHA-256 */
+func (st *saslTest) startSCRAM() *scram {
+	st.t.Helper()
+	st.feed(":irc.example.com CAP * LS :sasl=SCRAM-SHA-256",
+		"CAP REQ :sasl")
+	st.feed(":irc.example.com CAP * ACK :sasl",
+		"AUTHENTICATE SCRAM-SHA-256")
+	st.i.Feed("AUTHENTICATE +")
+	got := st.sent.take()
+	st.i.mu.Lock()
+	defer st.i.mu.Unlock()
+	if nil == st.i.sasl || nil == st.i.sasl.scram {
+		st.t.Fatalf("SCRAM-SHA-256 not started")
+	}
+	s := st.i.sasl.scram
+	want := "AUTHENTICATE " + b64("n,,n="+s.user+",r="+s.nonce)
+	if 1 != len(got) || want != got[0] {
+		st.t.Fatalf("sent %q, not %q", got, want)
+	}
+	return s
+}
+
+/* event returns the last event of the same type as e, if there is one */
+func (st *saslTest) event(e Event) (Event, bool) {
+	for n := len(st.events) - 1; 0 <= n; n-- {
+		if reflect.TypeOf(e) == reflect.TypeOf(st.events[n]) {
+			return st.events[n], true
+		}
+	}
+	return nil, false
+}
+
+/* b64 base64-encodes s */
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestSCRAMIterationLimit(t *testing.T) {
+	for _, c := range []struct {
+		iter string
+		ok   bool
+	}{
+		{"4096", true},
+		{"65536", true},
+		{"65537", false},
+		{"2147483647", false},
+		{"0", false},
+		{"-1", false},
+	} {
+		st := newSASLTest(t, "user", "pencil")
+		s := st.startSCRAM()
+		first := "r=" + s.nonce + "srv,s=QSXCR+Q6sek8bf92,i=" + c.iter
+		st.i.Feed("AUTHENTICATE " + b64(first))
+		got := st.sent.take()
+		if 1 != len(got) {
+			t.Fatalf("i=%v: sent %q, not one line", c.iter, got)
+		}
+		if aborted := "AUTHENTICATE *" == got[0]; aborted == c.ok {
+			t.Errorf("i=%v: sent %q", c.iter, got[0])
+		}
+	}
+}
+
+/* RFC 7677's example exchange */
+const (
+	rfc7677Nonce  = "rOprNGfwEbeRWgbNEkqO"
+	rfc7677First  = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	rfc7677Proof  = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	rfc7677Final  = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+	rfc7677Client = "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"
+)
+
+/* rfc7677 sets s up to use RFC 7677's nonce */
+func rfc7677(s *scram) {
+	s.nonce = rfc7677Nonce
+	s.bare = "n=" + scramName(s.user) + ",r=" + s.nonce
+}
+
+func TestSCRAMVectors(t *testing.T) {
+	s, err := newSCRAM("user", "pencil")
+	if nil != err {
+		t.Fatalf("newSCRAM: %v", err)
+	}
+	rfc7677(s)
+	for _, c := range []struct {
+		challenge string
+		want      string
+	}{
+		{"", rfc7677Client},
+		{rfc7677First, rfc7677Proof},
+		{rfc7677Final, ""},
+	} {
+		got, err := s.step([]byte(c.challenge))
+		if nil != err {
+			t.Fatalf("challenge %q: %v", c.challenge, err)
+		}
+		if c.want != string(got) {
+			t.Fatalf("challenge %q: got %q, not %q",
+				c.challenge, got, c.want)
+		}
+	}
+}
+
+func TestSCRAMNames(t *testing.T) {
+	if got := scramName("a=b,c"); "a=3Db=2Cc" != got {
+		t.Errorf("escaped to %q", got)
+	}
+}
+
+func TestSASLSCRAM(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	s := st.startSCRAM()
+	st.i.mu.Lock()
+	rfc7677(s)
+	st.i.mu.Unlock()
+	st.feed("AUTHENTICATE "+b64(rfc7677First),
+		"AUTHENTICATE "+b64(rfc7677Proof))
+	st.feed("AUTHENTICATE "+b64(rfc7677Final), "AUTHENTICATE +")
+	st.feed(":irc.example.com 900 nick nick!u@h user :You are now " +
+		"logged in as user")
+	st.feed(":irc.example.com 903 nick :SASL authentication successful",
+		"CAP END")
+	if e, ok := st.event(SASLEvent{}); !ok || !e.(SASLEvent).OK ||
+		"SCRAM-SHA-256" != e.(SASLEvent).Mechanism {
+		t.Errorf("last SASLEvent %#v", e)
+	}
+}
+
+func TestSASLChunkedChallenge(t *testing.T) {
+	/* 600 bytes is exactly two chunks of base64, so "+" ends it */
+	for _, size := range []int{500, 600} {
+		st := newSASLTest(t, "user", "pencil")
+		s := st.startSCRAM()
+		tail := ",s=QSXCR+Q6sek8bf92,i=4096"
+		pad := size - len("r="+s.nonce+tail)
+		first := b64("r=" + s.nonce + strings.Repeat("x", pad) + tail)
+		/* Every chunk but the last is 400 bytes */
+		var chunks []string
+		for saslChunk <= len(first) {
+			chunks = append(chunks, first[:saslChunk])
+			first = first[saslChunk:]
+		}
+		if "" == first {
+			first = "+"
+		}
+		chunks = append(chunks, first)
+		if 600 == size && "+" != first {
+			t.Fatalf("%v bytes didn't end on a full chunk", size)
+		}
+		for _, c := range chunks[:len(chunks)-1] {
+			st.feed("AUTHENTICATE " + c)
+		}
+		st.i.Feed("AUTHENTICATE " + chunks[len(chunks)-1])
+		/* The proof repeats the nonce, so it's chunked too */
+		var proof string
+		got := st.sent.take()
+		for n, l := range got {
+			c := strings.TrimPrefix(l, "AUTHENTICATE ")
+			if n != len(got)-1 && saslChunk != len(c) {
+				t.Fatalf("%v bytes: short chunk %v in %q",
+					size, n, got)
+			}
+			if "+" != c {
+				proof += c
+			}
+		}
+		if p, err := base64.StdEncoding.DecodeString(proof); nil != err ||
+			!strings.HasPrefix(string(p), "c=biws,r="+s.nonce) {
+			t.Fatalf("%v bytes: sent %q", size, got)
+		}
+	}
+}
+
+func TestSASLFallback(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	st.feed(":irc.example.com CAP * LS :sasl", "CAP REQ :sasl")
+	st.feed(":irc.example.com CAP * ACK :sasl",
+		"AUTHENTICATE SCRAM-SHA-256")
+	/* SCRAM's refused, so PLAIN's tried */
+	st.feed(":irc.example.com 904 nick :SASL authentication failed",
+		"AUTHENTICATE PLAIN")
+	if e, ok := st.event(SASLEvent{}); !ok || e.(SASLEvent).OK ||
+		"SCRAM-SHA-256" != e.(SASLEvent).Mechanism {
+		t.Errorf("last SASLEvent %#v", e)
+	}
+	/* And when that fails too, we give up */
+	st.feed(":irc.example.com 904 nick :SASL authentication failed",
+		"CAP END")
+}
+
+func TestSASLMechsList(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	st.i.ClientCert = &tls.Certificate{}
+	st.feed(":irc.example.com CAP * LS :sasl", "CAP REQ :sasl")
+	st.feed(":irc.example.com CAP * ACK :sasl", "AUTHENTICATE EXTERNAL")
+	/* The server only does PLAIN, so SCRAM's skipped */
+	st.feed(":irc.example.com 908 nick PLAIN :are available SASL " +
+		"mechanisms")
+	st.feed(":irc.example.com 904 nick :SASL authentication failed",
+		"AUTHENTICATE PLAIN")
+	/* Nothing's left after PLAIN */
+	st.feed(":irc.example.com 908 nick EXTERNAL :are available SASL " +
+		"mechanisms")
+	st.feed(":irc.example.com 904 nick :SASL authentication failed",
+		"CAP END")
+}
+
+func TestSASLBadServerSignature(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	st.feed(":irc.example.com CAP * LS :sasl", "CAP REQ :sasl")
+	st.feed(":irc.example.com CAP * ACK :sasl",
+		"AUTHENTICATE SCRAM-SHA-256")
+	st.i.Feed("AUTHENTICATE +")
+	st.sent.take()
+	st.i.mu.Lock()
+	rfc7677(st.i.sasl.scram)
+	st.i.mu.Unlock()
+	st.feed("AUTHENTICATE "+b64(rfc7677First),
+		"AUTHENTICATE "+b64(rfc7677Proof))
+	/* The wrong signature stops everything */
+	st.feed("AUTHENTICATE "+b64("v=AAAA"), "AUTHENTICATE *")
+	e, ok := st.event(ErrorEvent{})
+	if !ok || !errors.Is(e.(ErrorEvent).Err, ErrSCRAMServer) {
+		t.Errorf("last ErrorEvent %#v", e)
+	}
+	/* PLAIN isn't tried, and neither is NickServ */
+	st.feed(":irc.example.com 904 nick :SASL authentication aborted",
+		"CAP END")
+	st.i.Feed(":irc.example.com 001 nick :Welcome")
+	for _, l := range st.sent.take() {
+		if strings.Contains(l, "pencil") {
+			t.Errorf("sent password: %q", l)
+		}
+	}
+}
+
+/* saslStep is a line from the server, and what we should send in reply */
+type saslStep struct {
+	recv string
+	sent []string
+}
+
+/* run feeds the steps in ss through st */
+func (st *saslTest) run(ss []saslStep) {
+	st.t.Helper()
+	for _, s := range ss {
+		st.feed(s.recv, s.sent...)
+	}
+}
+
+func TestSASLExternal(t *testing.T) {
+	st := newSASLTest(t, "", "")
+	st.i.ClientCert = &tls.Certificate{}
+	st.run([]saslStep{
+		{":irc.example.com CAP * LS :sasl=EXTERNAL,PLAIN",
+			[]string{"CAP REQ :sasl"}},
+		{":irc.example.com CAP * ACK :sasl",
+			[]string{"AUTHENTICATE EXTERNAL"}},
+		/* The certificate's all the server needs */
+		{"AUTHENTICATE +", []string{"AUTHENTICATE +"}},
+		{":irc.example.com 900 nick nick!u@h acct :You are now " +
+			"logged in as acct", nil},
+		{":irc.example.com 903 nick :SASL authentication successful",
+			[]string{"CAP END"}},
+	})
+	if e, ok := st.event(SASLEvent{}); !ok || !e.(SASLEvent).OK ||
+		"EXTERNAL" != e.(SASLEvent).Mechanism {
+		t.Errorf("last SASLEvent %#v", e)
+	}
+}
+
+func TestSASLPlain(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	st.run([]saslStep{
+		{":irc.example.com CAP * LS :sasl=PLAIN",
+			[]string{"CAP REQ :sasl"}},
+		{":irc.example.com CAP * ACK :sasl",
+			[]string{"AUTHENTICATE PLAIN"}},
+		{"AUTHENTICATE +",
+			[]string{"AUTHENTICATE " + b64("\x00user\x00pencil")}},
+		{":irc.example.com 903 nick :SASL authentication successful",
+			[]string{"CAP END"}},
+	})
+}
+
+func TestSASLPlainFullChunk(t *testing.T) {
+	/* 300 bytes is exactly one chunk of base64 */
+	pass := strings.Repeat("p", 300-len("\x00user\x00"))
+	resp := b64("\x00user\x00" + pass)
+	if saslChunk != len(resp) {
+		t.Fatalf("response is %v bytes, not %v", len(resp), saslChunk)
+	}
+	long := strings.Repeat("q", 400)
+	longResp := b64("\x00user\x00" + long)
+	st := newSASLTest(t, "user", pass)
+	st.run([]saslStep{
+		{":irc.example.com CAP * LS :sasl=PLAIN",
+			[]string{"CAP REQ :sasl"}},
+		{":irc.example.com CAP * ACK :sasl",
+			[]string{"AUTHENTICATE PLAIN"}},
+		/* A full chunk's followed by an empty one */
+		{"AUTHENTICATE +", []string{
+			"AUTHENTICATE " + resp,
+			"AUTHENTICATE +",
+		}},
+	})
+	/* Longer responses are split */
+	st = newSASLTest(t, "user", long)
+	st.run([]saslStep{
+		{":irc.example.com CAP * LS :sasl=PLAIN",
+			[]string{"CAP REQ :sasl"}},
+		{":irc.example.com CAP * ACK :sasl",
+			[]string{"AUTHENTICATE PLAIN"}},
+		{"AUTHENTICATE +", []string{
+			"AUTHENTICATE " + longResp[:saslChunk],
+			"AUTHENTICATE " + longResp[saslChunk:],
+		}},
+	})
+}
+
+func TestSASLFallbackToServices(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	st.run([]saslStep{
+		{":irc.example.com CAP * LS :sasl=PLAIN",
+			[]string{"CAP REQ :sasl"}},
+		{":irc.example.com CAP * ACK :sasl",
+			[]string{"AUTHENTICATE PLAIN"}},
+		{"AUTHENTICATE +",
+			[]string{"AUTHENTICATE " + b64("\x00user\x00pencil")}},
+		{":irc.example.com 904 nick :SASL authentication failed",
+			[]string{"CAP END"}},
+		/* Auth's called once we've registered */
+		{":irc.example.com 001 nick :Welcome", []string{
+			"PRIVMSG NickServ :identify user pencil",
+			"USERHOST nick",
+		}},
+	})
+}
+
+func TestSASLNotOffered(t *testing.T) {
+	st := newSASLTest(t, "user", "pencil")
+	st.run([]saslStep{
+		{":irc.example.com CAP * LS :multi-prefix",
+			[]string{"CAP END"}},
+		{":irc.example.com 001 nick :Welcome", []string{
+			"PRIVMSG NickServ :identify user pencil",
+			"USERHOST nick",
+		}},
+	})
+}
@@ -0,0 +1,97 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+/*
+ * schedule.go
+ * Send messages later
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* scheduled is a message waiting to be sent */
+type scheduled struct {
+	at     time.Time
+	target string
+	msg    string
+}
+
+// SendAt sends msg to target (as with Privmsg) at time t.  If we're not registered with the server at t, the message is sent once we are, even if that's after a reconnect.  Errors sending the message are sent as ErrorEvents, which, like the message, may come from a timer's goroutine; OnEvent still gets them one event at a time.  The returned function cancels the message, and returns true if it hadn't yet been sent.
+func (i *IRC) SendAt(t time.Time, target, msg string) func() bool {
+	s := &scheduled{at: t, target: target, msg: msg}
+	i.mu.Lock()
+	i.sched = append(i.sched, s)
+	i.mu.Unlock()
+	i.runScheduled()
+	return func() bool {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		for n, o := range i.sched {
+			if o == s {
+				i.sched = append(i.sched[:n], i.sched[n+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SendAfter is like SendAt, but sends msg after d has elapsed.
+func (i *IRC) SendAfter(d time.Duration, target, msg string) func() bool {
+	return i.SendAt(time.Now().Add(d), target, msg)
+}
+
+// runScheduled sends the messages which are due, if we're registered, and
+// sets a timer for the next one
+func (i *IRC) runScheduled() {
+	i.mu.Lock()
+	var (
+		due  []*scheduled
+		rest []*scheduled
+		next time.Time
+		now  = time.Now()
+	)
+	for _, s := range i.sched {
+		if Registered == i.state && !s.at.After(now) {
+			due = append(due, s)
+			continue
+		}
+		rest = append(rest, s)
+		if next.IsZero() || s.at.Before(next) {
+			next = s.at
+		}
+	}
+	i.sched = rest
+	/* Nothing will be sent until we're registered, which will call
+	runScheduled again */
+	switch {
+	case Registered != i.state || next.IsZero():
+		if nil != i.schedTimer {
+			i.schedTimer.Stop()
+		}
+	case nil == i.schedTimer:
+		i.schedTimer = time.AfterFunc(next.Sub(now), i.runScheduled)
+	default:
+		i.schedTimer.Reset(next.Sub(now))
+	}
+	i.mu.Unlock()
+
+	/* Send them in order */
+	sort.SliceStable(due, func(a, b int) bool {
+		return due[a].at.Before(due[b].at)
+	})
+	for _, s := range due {
+		if err := i.Privmsg(s.msg, s.target); nil != err {
+			i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+				"error sending scheduled message to %v: %v",
+				s.target, err))})
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package minimalirc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ * scram.go
+ * SASL SCRAM-SHA-256, per RFC 7677
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+const (
+	/* scramNonceLen is the number of random bytes in our nonce */
+	scramNonceLen = 18
+	// scramMaxIter is the most iterations we'll do for a server.  Each is
+	// an HMAC on the goroutine reading from the server, so a server asking
+	// for billions would hang the connection.  Real servers ask for 4096
+	// or a few times that.
+	scramMaxIter = 65536
+)
+
+// ErrSCRAMServer is returned when the server fails to prove it knows our SCRAM-SHA-256 password.  The login is aborted and no other mechanism is tried, as the server may be an impostor.
+var ErrSCRAMServer = errors.New("server's SCRAM signature is wrong")
+
+/* scram is a SCRAM-SHA-256 exchange in progress */
+type scram struct {
+	user    string
+	pass    string
+	nonce   string /* Our nonce */
+	bare    string /* Our first message, sans GS2 header */
+	authMsg string /* What both sides sign */
+	srvSig  []byte /* The server's signature we expect */
+}
+
+/* newSCRAM starts a SCRAM-SHA-256 exchange for user with pass */
+func newSCRAM(user, pass string) (*scram, error) {
+	b := make([]byte, scramNonceLen)
+	if _, err := rand.Read(b); nil != err {
+		return nil, errors.New(fmt.Sprintf("unable to make SCRAM "+
+			"nonce: %v", err))
+	}
+	s := &scram{
+		user:  user,
+		pass:  pass,
+		nonce: base64.RawStdEncoding.EncodeToString(b),
+	}
+	s.bare = "n=" + scramName(user) + ",r=" + s.nonce
+	return s, nil
+}
+
+/* scramName escapes = and , in a SCRAM username */
+func scramName(s string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s)
+}
+
+// step returns our reply to the server's challenge c.  The first, empty,
+// challenge gets our first message, the server's first message gets our
+// proof, and the server's proof gets an empty reply if it checks out.
+func (s *scram) step(c []byte) ([]byte, error) {
+	switch {
+	case 0 == len(c) && "" == s.authMsg:
+		return []byte("n,," + s.bare), nil
+	case "" == s.authMsg:
+		return s.prove(string(c))
+	case nil != s.srvSig:
+		return nil, s.verify(string(c))
+	}
+	return nil, errors.New("unexpected SCRAM challenge")
+}
+
+/* scramAttrs splits a SCRAM message into its attributes */
+func scramAttrs(msg string) map[string]string {
+	as := make(map[string]string)
+	for _, a := range strings.Split(msg, ",") {
+		if 2 < len(a) && '=' == a[1] {
+			as[a[:1]] = a[2:]
+		}
+	}
+	return as
+}
+
+// prove works out our proof from the server's first message, and
+// remembers the signature the server should send back
+func (s *scram) prove(first string) ([]byte, error) {
+	as := scramAttrs(first)
+	if e, ok := as["e"]; ok {
+		return nil, errors.New(fmt.Sprintf("SCRAM error: %v", e))
+	}
+	nonce := as["r"]
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return nil, errors.New("server's SCRAM nonce is wrong")
+	}
+	salt, err := base64.StdEncoding.DecodeString(as["s"])
+	if nil != err || 0 == len(salt) {
+		return nil, errors.New(fmt.Sprintf("bad SCRAM salt %q",
+			as["s"]))
+	}
+	iter, err := strconv.Atoi(as["i"])
+	if nil != err || 1 > iter || scramMaxIter < iter {
+		return nil, errors.New(fmt.Sprintf("bad SCRAM iteration "+
+			"count %q", as["i"]))
+	}
+
+	/* Channel binding's not used, so c is the base64 of n,, */
+	final := "c=biws,r=" + nonce
+	s.authMsg = s.bare + "," + first + "," + final
+	salted := scramHi([]byte(s.pass), salt, iter)
+	ck := scramHMAC(salted, "Client Key")
+	sk := sha256.Sum256(ck)
+	sig := scramHMAC(sk[:], s.authMsg)
+	proof := make([]byte, len(ck))
+	for n := range ck {
+		proof[n] = ck[n] ^ sig[n]
+	}
+	s.srvSig = scramHMAC(scramHMAC(salted, "Server Key"), s.authMsg)
+	return []byte(final + ",p=" +
+		base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+/* verify checks the server's final message has the right signature */
+func (s *scram) verify(final string) error {
+	as := scramAttrs(final)
+	if e, ok := as["e"]; ok {
+		return errors.New(fmt.Sprintf("SCRAM error: %v", e))
+	}
+	v, err := base64.StdEncoding.DecodeString(as["v"])
+	if nil != err || 1 != subtle.ConstantTimeCompare(v, s.srvSig) {
+		return ErrSCRAMServer
+	}
+	return nil
+}
+
+/* scramHMAC returns the HMAC-SHA-256 of msg with key */
+func scramHMAC(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+/* scramHi is PBKDF2 with HMAC-SHA-256, for a single block */
+func scramHi(pass, salt []byte, iter int) []byte {
+	h := hmac.New(sha256.New, pass)
+	h.Write(salt)
+	h.Write([]byte{0, 0, 0, 1})
+	u := h.Sum(nil)
+	out := append([]byte{}, u...)
+	for n := 1; n < iter; n++ {
+		h.Reset()
+		h.Write(u)
+		u = h.Sum(u[:0])
+		for j := range out {
+			out[j] ^= u[j]
+		}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package minimalirc
+
+import (
+	"strings"
+)
+
+/*
+ * secrets.go
+ * Keep passwords out of logs
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* redacted replaces secrets in logged lines */
+const redacted = "<redacted>"
+
+// secretServiceCommands are the services commands whose arguments are
+// secret, mapped to the number of arguments which aren't
+var secretServiceCommands = map[string]int{
+	"IDENTIFY": 0,
+	"ID":       0,
+	"LOGIN":    0,
+	"AUTH":     0,
+	"REGISTER": 0,
+	"GHOST":    1,
+	"RECOVER":  1,
+	"RELEASE":  1,
+	"REGAIN":   1,
+	"SET":      1, /* SET PASSWORD */
+}
+
+// redactLine returns line with anything which looks like a password (server
+// and oper passwords, SASL payloads, channel keys, services logins, account
+// registration passwords and codes, and i.IdPass wherever it appears)
+// replaced by <redacted>.
+func (i *IRC) redactLine(line string) string {
+	if _, pass := i.creds(); "" != pass {
+		line = strings.Replace(line, pass, redacted, -1)
+	}
+	m := ParseMessage(line)
+	p := m.Params
+	switch m.Command {
+	case "PASS":
+		p = redactFrom(p, 0)
+	case "OPER":
+		p = redactFrom(p, 1)
+	case "JOIN":
+		p = redactFrom(p, 1)
+	case "REGISTER":
+		p = redactFrom(p, 2)
+	case "VERIFY":
+		p = redactFrom(p, 1)
+	case "AUTHENTICATE":
+		/* Mechanisms and continuations aren't secret */
+		if a := m.Param(0); "+" != a && "*" != a && !isMechanism(a) {
+			p = redactFrom(p, 0)
+		}
+	case "NICKSERV", "NS", "CHANSERV", "CS":
+		if 0 == len(p) {
+			return line
+		}
+		p = []string{redactServiceCommand(strings.Join(p, " "))}
+	case "PRIVMSG", "NOTICE":
+		if 2 != len(p) || !i.isServiceBot(p[0]) {
+			return line
+		}
+		p = []string{p[0], redactServiceCommand(p[1])}
+	default:
+		return line
+	}
+	m.Params = p
+	return m.String()
+}
+
+/* logLine returns line as it should be logged with i.Txp or i.Rxp */
+func (i *IRC) logLine(line string) string {
+	if _, _, secrets := i.logSettings(); secrets {
+		return line
+	}
+	return i.redactLine(line)
+}
+
+/* redactFrom returns ps with the elements from n on redacted */
+func redactFrom(ps []string, n int) []string {
+	if n >= len(ps) {
+		return ps
+	}
+	ps = append([]string(nil), ps...)
+	ps[n] = redacted
+	return ps[:n+1]
+}
+
+// redactServiceCommand redacts the secret arguments to the services command
+// in text
+func redactServiceCommand(text string) string {
+	ws := strings.Fields(text)
+	if 0 == len(ws) {
+		return text
+	}
+	keep, ok := secretServiceCommands[strings.ToUpper(ws[0])]
+	if !ok {
+		return text
+	}
+	/* Only SET PASSWORD is secret */
+	if "SET" == strings.ToUpper(ws[0]) && (2 > len(ws) ||
+		!strings.HasPrefix(strings.ToUpper(ws[1]), "PASS")) {
+		return text
+	}
+	if 1+keep >= len(ws) {
+		return text
+	}
+	return strings.Join(append(ws[:1+keep], redacted), " ")
+}
+
+// isServiceBot returns true if target looks like a services bot, i.e. it's
+// i.Services' bot, ends in Serv, or is a secure address like Q@CServe
+func (i *IRC) isServiceBot(target string) bool {
+	if strings.Contains(target, "@") {
+		return true
+	}
+	t := fold(target)
+	return t == fold(i.services().Bot()) || strings.HasSuffix(t, "serv")
+}
+
+// isMechanism returns true if s looks like a SASL mechanism name, which is at
+// most 20 upper-case letters, digits, hyphens, and underscores
+func isMechanism(s string) bool {
+	if "" == s || 20 < len(s) {
+		return false
+	}
+	for _, c := range s {
+		if !('A' <= c && 'Z' >= c) && !('0' <= c && '9' >= c) &&
+			'-' != c && '_' != c {
+			return false
+		}
+	}
+	return true
+}
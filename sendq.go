@@ -0,0 +1,204 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/*
+ * sendq.go
+ * Decide who sends next
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Priority says which lines waiting to be sent go first, when they have to wait (e.g. because of i.Limiter).  Lines of the same Priority are sent in the order in which they were given to PrintfLine.
+type Priority int
+
+// Priorities, highest first.
+const (
+	PriorityControl Priority = iota /* PONG, QUIT, MODE, and the like */
+	PriorityReply                   /* PRIVMSG, NOTICE, and TAGMSG */
+	PriorityBulk                    /* Pastes and SendBinary */
+	numPriorities
+)
+
+// ErrCancelled is returned by PrintfLine and everything which uses it when the line was waiting to be sent and CancelPending was called for its target.
+var ErrCancelled = errors.New("cancelled before being sent")
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityControl:
+		return "control"
+	case PriorityReply:
+		return "reply"
+	case PriorityBulk:
+		return "bulk"
+	}
+	return "unknown"
+}
+
+/* sendTicket is a line waiting its turn to be sent */
+type sendTicket struct {
+	turn      chan struct{} /* Closed when it's our turn, or cancelled */
+	targets   []string      /* Folded targets of a PRIVMSG and the like */
+	cancelled bool          /* CancelPending was called */
+	flushed   bool          /* FlushTarget was called */
+}
+
+// sendQueue lets one line be sent at a time, letting higher-priority lines
+// go first
+type sendQueue struct {
+	mu      sync.Mutex
+	busy    bool /* A line's being sent */
+	waiting [numPriorities][]*sendTicket
+}
+
+/* linePriority works out the priority of a line */
+func linePriority(line string) Priority {
+	switch strings.ToUpper(ParseMessage(line).Command) {
+	case "PRIVMSG", "NOTICE", "TAGMSG":
+		return PriorityReply
+	}
+	return PriorityControl
+}
+
+/* lineTargets returns the folded targets of a PRIVMSG, NOTICE, or TAGMSG */
+func lineTargets(line string) []string {
+	m := ParseMessage(line)
+	switch strings.ToUpper(m.Command) {
+	case "PRIVMSG", "NOTICE", "TAGMSG":
+	default:
+		return nil
+	}
+	ts := strings.Split(m.Param(0), ",")
+	for n, t := range ts {
+		ts[n] = fold(t)
+	}
+	return ts
+}
+
+/* hasTarget returns true if t's line is to target, which is folded */
+func (t *sendTicket) hasTarget(target string) bool {
+	for _, tt := range t.targets {
+		if target == tt {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire waits until it's the turn of line, which has priority p.  It
+// returns true if the line's been flushed with FlushTarget, and so shouldn't
+// wait for the Limiter, or ErrCancelled if the line's been cancelled with
+// CancelPending.  Unless there's an error, release must be called when the
+// line's been sent.
+func (q *sendQueue) acquire(p Priority, line string) (bool, error) {
+	if 0 > p || numPriorities <= p {
+		p = PriorityBulk
+	}
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return false, nil
+	}
+	t := &sendTicket{turn: make(chan struct{}), targets: lineTargets(line)}
+	q.waiting[p] = append(q.waiting[p], t)
+	q.mu.Unlock()
+	<-t.turn
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if t.cancelled {
+		return false, ErrCancelled
+	}
+	return t.flushed, nil
+}
+
+/* release gives the next turn to the highest-priority waiting line */
+func (q *sendQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for p, ts := range q.waiting {
+		if 0 == len(ts) {
+			continue
+		}
+		t := ts[0]
+		q.waiting[p] = ts[1:]
+		close(t.turn)
+		return
+	}
+	q.busy = false
+}
+
+// Pending returns the number of lines given to PrintfLine which are waiting for others to be sent first.
+func (i *IRC) Pending() int {
+	i.sq.mu.Lock()
+	defer i.sq.mu.Unlock()
+	n := 0
+	for _, ts := range i.sq.waiting {
+		n += len(ts)
+	}
+	return n
+}
+
+// CancelPending stops lines to target which are waiting to be sent (see Pending) from being sent, e.g. when someone's asked for a long paste to stop.  The calls to PrintfLine (or Privmsg, etc.) which were sending them return ErrCancelled.  The number of lines cancelled is returned.  Lines already sent or buffered by Cork aren't affected.
+func (i *IRC) CancelPending(target string) int {
+	target = fold(target)
+	q := &i.sq
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for p, ts := range q.waiting {
+		var keep []*sendTicket
+		for _, t := range ts {
+			if !t.hasTarget(target) {
+				keep = append(keep, t)
+				continue
+			}
+			t.cancelled = true
+			close(t.turn)
+			n++
+		}
+		q.waiting[p] = keep
+	}
+	return n
+}
+
+// FlushTarget sends lines to target which are waiting to be sent (see Pending) before any others, without waiting for i.Limiter.  Sending too many at once may get us disconnected for flooding.  The number of lines flushed is returned.
+func (i *IRC) FlushTarget(target string) int {
+	target = fold(target)
+	q := &i.sq
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var flushed []*sendTicket
+	for p, ts := range q.waiting {
+		var keep []*sendTicket
+		for _, t := range ts {
+			if !t.hasTarget(target) {
+				keep = append(keep, t)
+				continue
+			}
+			t.flushed = true
+			flushed = append(flushed, t)
+		}
+		q.waiting[p] = keep
+	}
+	/* Flushed lines jump the queue, in the order they'd have gone */
+	q.waiting[PriorityControl] = append(flushed,
+		q.waiting[PriorityControl]...)
+	return len(flushed)
+}
+
+// PrintfLinePriority is like PrintfLine, but the line is sent with priority p rather than the priority PrintfLine would give it.
+func (i *IRC) PrintfLinePriority(
+	p Priority,
+	f string,
+	args ...interface{},
+) error {
+	return i.sendLine(p, fmt.Sprintf(f, args...))
+}
@@ -0,0 +1,192 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ * servicebots.go
+ * Talk to ChanServ, HostServ, and MemoServ
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* serviceQuiet is how long to wait for more of a bot's reply */
+const serviceQuiet = time.Second
+
+// Names of services bots, for ServiceBots.
+const (
+	ChanServ = "ChanServ"
+	HostServ = "HostServ"
+	MemoServ = "MemoServ"
+)
+
+// ServiceBots is implemented by Services which have bots other than the one used to log in, such as NickServ.  The ChanServ, HostServ, and MemoServ functions use the bots it names.
+type ServiceBots interface {
+	/* ServiceBot returns the nick of the bot which does what ChanServ,
+	HostServ, or MemoServ do, or "" if there isn't one */
+	ServiceBot(name string) string
+}
+
+// ServiceBot returns name, as Atheme and Anope use the usual names.
+func (s NickServ) ServiceBot(name string) string {
+	return name
+}
+
+// ServiceError is returned when a services bot's reply looks like it's refused a request.
+type ServiceError struct {
+	Bot  string
+	Text string /* The bot's reply */
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Bot, e.Text)
+}
+
+// serviceFailures are lower-case snippets of bots' replies which mean a
+// request failed
+var serviceFailures = []string{
+	"access denied",
+	"permission denied",
+	"not authorized",
+	"not authorised",
+	"insufficient",
+	"not registered",
+	"isn't registered",
+	"does not exist",
+	"doesn't exist",
+	"invalid",
+	"unknown command",
+	"you must",
+	"not found",
+	"cannot",
+	"can't",
+	"not online",
+}
+
+// ServiceCommand sends command to the services bot named name (ChanServ, HostServ, or MemoServ), as named by i.Services, and returns its reply, one NOTICE per element.  The reply is considered complete when the bot's been quiet for a second.  If the reply looks like a refusal, a *ServiceError is returned as well.  If ctx has no deadline, ServiceCommand gives up with a *TimeoutError if the bot doesn't answer within i.RequestTimeout.
+func (i *IRC) ServiceCommand(ctx context.Context, name, command string) (
+	[]string, error) {
+	return i.serviceCommand(ctx, name, command, nil)
+}
+
+// serviceCommand is ServiceCommand, but also stops waiting if done returns
+// true for a message
+func (i *IRC) serviceCommand(
+	ctx context.Context,
+	name, command string,
+	done func(m Message) bool,
+) ([]string, error) {
+	sb, ok := i.services().(ServiceBots)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no %v on this network",
+			name))
+	}
+	bot := sb.ServiceBot(name)
+	if "" == bot {
+		return nil, errors.New(fmt.Sprintf("no %v on this network",
+			name))
+	}
+	/* Wait until the bot's been quiet for a bit */
+	qctx, quiet := context.WithCancel(ctx)
+	defer quiet()
+	var (
+		lines []string
+		t     *time.Timer
+	)
+	err := i.stream(qctx, func() error {
+		return i.PrintfLine("PRIVMSG %v :%v", bot, command)
+	}, func(m Message) (bool, bool) {
+		if nil != done && done(m) {
+			return false, true
+		}
+		ok := "NOTICE" == m.Command && fold(bot) == fold(m.Nick)
+		return ok, false
+	}, func(m Message) {
+		lines = append(lines, m.Trailing())
+		if nil == t {
+			t = time.AfterFunc(serviceQuiet, quiet)
+		} else {
+			t.Reset(serviceQuiet)
+		}
+	})
+	if nil != t {
+		t.Stop()
+	}
+	/* Being quiet isn't an error */
+	if nil != err && !(errors.Is(err, context.Canceled) &&
+		nil == ctx.Err() && 0 != len(lines)) {
+		return lines, err
+	}
+	for _, l := range lines {
+		ll := strings.ToLower(l)
+		for _, f := range serviceFailures {
+			if strings.Contains(ll, f) {
+				return lines, &ServiceError{Bot: bot, Text: l}
+			}
+		}
+	}
+	return lines, nil
+}
+
+// ChanServOp asks ChanServ to op nick in channel, and waits for the server to say it has, or ChanServ to say why not.
+func (i *IRC) ChanServOp(ctx context.Context, channel, nick string) error {
+	return i.chanServMode(ctx, "OP", channel, nick, "+o")
+}
+
+// ChanServDeop asks ChanServ to deop nick in channel, as ChanServOp.
+func (i *IRC) ChanServDeop(ctx context.Context, channel, nick string) error {
+	return i.chanServMode(ctx, "DEOP", channel, nick, "-o")
+}
+
+/* chanServMode asks ChanServ to change a mode and waits for the MODE */
+func (i *IRC) chanServMode(
+	ctx context.Context,
+	command, channel, nick, mode string,
+) error {
+	_, err := i.serviceCommand(ctx, ChanServ, fmt.Sprintf("%v %v %v",
+		command, channel, nick), func(m Message) bool {
+		return "MODE" == m.Command &&
+			fold(channel) == fold(m.Param(0)) &&
+			mode == m.Param(1) && fold(nick) == fold(m.Param(2))
+	})
+	return err
+}
+
+// ChanServFlags sets nick's access flags (e.g. +AOiortv) in channel with ChanServ's FLAGS command (Atheme), and returns ChanServ's reply.
+func (i *IRC) ChanServFlags(ctx context.Context, channel, nick,
+	flags string) (string, error) {
+	ls, err := i.ServiceCommand(ctx, ChanServ, fmt.Sprintf("FLAGS %v %v %v",
+		channel, nick, flags))
+	return strings.Join(ls, "\n"), err
+}
+
+// RequestVhost asks HostServ for vhost, and returns HostServ's reply.  Network staff usually have to approve it.
+func (i *IRC) RequestVhost(ctx context.Context, vhost string) (string,
+	error) {
+	ls, err := i.ServiceCommand(ctx, HostServ, "REQUEST "+vhost)
+	return strings.Join(ls, "\n"), err
+}
+
+// SendMemo sends a memo to nick with MemoServ, for nick to read when they identify.
+func (i *IRC) SendMemo(ctx context.Context, nick, text string) error {
+	_, err := i.ServiceCommand(ctx, MemoServ, fmt.Sprintf("SEND %v %v",
+		nick, text))
+	return err
+}
+
+// ListMemos returns MemoServ's list of our memos, one line per element.
+func (i *IRC) ListMemos(ctx context.Context) ([]string, error) {
+	return i.ServiceCommand(ctx, MemoServ, "LIST")
+}
+
+// ReadMemo returns memo n (from 1, as in ListMemos), as MemoServ sends it, one line per element.
+func (i *IRC) ReadMemo(ctx context.Context, n int) ([]string, error) {
+	return i.ServiceCommand(ctx, MemoServ, fmt.Sprintf("READ %v", n))
+}
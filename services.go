@@ -0,0 +1,152 @@
+package minimalirc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * services.go
+ * Talk to different networks' services
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Services describes how to talk to a network's services.  Set i.Services to use something other than NickServ.
+type Services interface {
+	/* IdentifyLine returns the line to send to log in */
+	IdentifyLine(account, password string) string
+	/* Bot returns the nick of the services bot which replies to
+	IdentifyLine */
+	Bot() string
+}
+
+// Challenger is implemented by Services which can log in without sending the password, by answering a challenge from Bot.  If i.Services implements Challenger, Auth asks for a challenge in place of sending IdentifyLine, and the library answers it.
+type Challenger interface {
+	Services
+	/* ChallengeLine returns the line which asks for a challenge */
+	ChallengeLine() string
+	/* ChallengeResponse returns the line which answers the challenge
+	in text, a NOTICE from Bot, and true, or false if text isn't a
+	challenge it can answer */
+	ChallengeResponse(text, account, password string) (string, bool)
+}
+
+// NickServ is the Services used by most networks (Atheme, Anope, etc.).
+type NickServ struct {
+	Nick string /* NickServ's nick, "NickServ" if empty */
+}
+
+// Bot returns s.Nick, or NickServ.
+func (s NickServ) Bot() string {
+	if "" == s.Nick {
+		return "NickServ"
+	}
+	return s.Nick
+}
+
+// IdentifyLine returns a PRIVMSG with IDENTIFY.
+func (s NickServ) IdentifyLine(account, password string) string {
+	return fmt.Sprintf("PRIVMSG %v :identify %v %v", s.Bot(), account,
+		password)
+}
+
+// UndernetX is Undernet's X.  X doesn't offer challenge-response login, so UndernetX isn't a Challenger; IdentifyLine sends the password to X's secure address.
+type UndernetX struct{}
+
+// Bot returns X.
+func (UndernetX) Bot() string { return "X" }
+
+// IdentifyLine returns a secure LOGIN message to X.
+func (UndernetX) IdentifyLine(account, password string) string {
+	return fmt.Sprintf("PRIVMSG X@channels.undernet.org :login %v %v",
+		account, password)
+}
+
+// QuakeNetQ is QuakeNet's Q.  It's a Challenger, using CHALLENGEAUTH with HMAC-SHA-256.
+type QuakeNetQ struct{}
+
+/* qAddr is Q's secure address */
+const qAddr = "Q@CServe.quakenet.org"
+
+// Bot returns Q.
+func (QuakeNetQ) Bot() string { return "Q" }
+
+// IdentifyLine returns a secure AUTH message to Q.
+func (QuakeNetQ) IdentifyLine(account, password string) string {
+	return fmt.Sprintf("PRIVMSG %v :auth %v %v", qAddr, account, password)
+}
+
+// ChallengeLine returns a secure CHALLENGE message to Q.
+func (QuakeNetQ) ChallengeLine() string {
+	return fmt.Sprintf("PRIVMSG %v :CHALLENGE", qAddr)
+}
+
+// ChallengeResponse answers Q's CHALLENGE notice with a CHALLENGEAUTH message.
+func (QuakeNetQ) ChallengeResponse(text, account, password string) (
+	string, bool) {
+	/* CHALLENGE <challenge> <algorithms...> */
+	f := strings.Fields(text)
+	if 3 > len(f) || "CHALLENGE" != f[0] {
+		return "", false
+	}
+	supported := false
+	for _, a := range f[2:] {
+		if "HMAC-SHA-256" == a {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return "", false
+	}
+	return fmt.Sprintf("PRIVMSG %v :CHALLENGEAUTH %v %v HMAC-SHA-256",
+		qAddr, account, qResponse(f[1], account, password)), true
+}
+
+// qResponse works out the response to Q's challenge, per
+// https://www.quakenet.org/development/challengeauth.  Q only uses the first
+// ten characters of passwords.
+func qResponse(challenge, account, password string) string {
+	if 10 < len(password) {
+		password = password[:10]
+	}
+	ph := sha256.Sum256([]byte(password))
+	kh := sha256.Sum256([]byte(fold(account) + ":" +
+		hex.EncodeToString(ph[:])))
+	m := hmac.New(sha256.New, []byte(hex.EncodeToString(kh[:])))
+	m.Write([]byte(challenge))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+/* services returns i.Services, or NickServ if it's not set */
+func (i *IRC) services() Services {
+	if nil == i.Services {
+		return NickServ{}
+	}
+	return i.Services
+}
+
+/* handleChallenge answers a challenge from services, if it's one */
+func (i *IRC) handleChallenge(m Message) {
+	c, ok := i.services().(Challenger)
+	idNick, idPass := i.creds()
+	if !ok || "NOTICE" != m.Command || fold(c.Bot()) != fold(m.Nick) ||
+		"" == idNick || "" == idPass || i.PassiveMode {
+		return
+	}
+	l, ok := c.ChallengeResponse(m.Trailing(), idNick, idPass)
+	if !ok {
+		return
+	}
+	if err := i.PrintfLine("%s", l); nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error answering challenge from %v: %v", m.Nick, err))})
+	}
+}
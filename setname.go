@@ -0,0 +1,127 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * setname.go
+ * Change realnames, per the setname capability
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ErrNoSetname is returned by SetRealname if the setname capability isn't enabled.  Add setname to i.Caps to request it.
+var ErrNoSetname = errors.New("setname capability not enabled")
+
+// RealnameEvent is sent when someone, possibly us, changes their realname with SETNAME.
+type RealnameEvent struct {
+	From     string /* nick!user@host */
+	Nick     string
+	Realname string /* The new realname */
+}
+
+func (RealnameEvent) event() {}
+
+// SetRealname asks the server to change our realname to name, and waits for it to accept or refuse.  A refusal is returned as an error.  Once accepted, i.Realname is set to name, so it's used when reconnecting.  The setname capability must be enabled.
+func (i *IRC) SetRealname(ctx context.Context, name string) error {
+	if !i.HasCap("setname") {
+		return ErrNoSetname
+	}
+	ms, err := i.wait(ctx, func() error {
+		return i.PrintfLine("SETNAME :%v", name)
+	}, func(m Message) (bool, bool) {
+		switch m.Command {
+		case "SETNAME":
+			return i.isMe(m.Nick), i.isMe(m.Nick)
+		case "FAIL":
+			return "SETNAME" == m.Param(0), "SETNAME" == m.Param(0)
+		}
+		return false, false
+	})
+	if nil != err {
+		return err
+	}
+	if m := ms[0]; "FAIL" == m.Command {
+		return errors.New(fmt.Sprintf("unable to change realname: "+
+			"%v (%v)", m.Trailing(), m.Param(1)))
+	}
+	i.Realname = name
+	return nil
+}
+
+// RealnameOf returns the realname of nick, if it's been seen in a SETNAME, an extended JOIN, or a reply to WHO or WHOIS.  Realnames are forgotten when their owners QUIT, or leave the last channel they share with us.
+func (i *IRC) RealnameOf(nick string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	r, ok := i.realnames[fold(nick)]
+	return r, ok
+}
+
+/* setRealname notes nick's realname */
+func (i *IRC) setRealname(nick, realname string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if nil == i.realnames {
+		i.realnames = make(map[string]string)
+	}
+	i.realnames[fold(nick)] = realname
+}
+
+/* forgetRealname forgets nick's realname */
+func (i *IRC) forgetRealname(nick string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.realnames, fold(nick))
+}
+
+// trackRealnames keeps track of others' realnames.  It's called after
+// trackChannels, so we know who's left our channels.
+func (i *IRC) trackRealnames(m Message) {
+	switch m.Command {
+	case "SETNAME":
+		i.setRealname(m.Nick, m.Param(0))
+		i.event(RealnameEvent{
+			From:     m.Prefix,
+			Nick:     m.Nick,
+			Realname: m.Param(0),
+		})
+	case "JOIN": /* With extended-join, channel account :realname */
+		if 3 == len(m.Params) {
+			i.setRealname(m.Nick, m.Param(2))
+		}
+	case "311": /* RPL_WHOISUSER, me nick user host * :realname */
+		if 6 == len(m.Params) {
+			i.setRealname(m.Param(1), m.Param(5))
+		}
+	case "352": /* RPL_WHOREPLY, ends with :hopcount realname */
+		if 8 != len(m.Params) {
+			return
+		}
+		if p := strings.SplitN(m.Param(7), " ", 2); 2 == len(p) {
+			i.setRealname(m.Param(5), p[1])
+		}
+	case "NICK":
+		i.mu.Lock()
+		if r, ok := i.realnames[fold(m.Nick)]; ok {
+			delete(i.realnames, fold(m.Nick))
+			i.realnames[fold(m.Param(0))] = r
+		}
+		i.mu.Unlock()
+	case "QUIT":
+		i.forgetRealname(m.Nick)
+	case "PART":
+		if 0 == len(i.CommonChannels(m.Nick)) {
+			i.forgetRealname(m.Nick)
+		}
+	case "KICK":
+		if 0 == len(i.CommonChannels(m.Param(1))) {
+			i.forgetRealname(m.Param(1))
+		}
+	}
+}
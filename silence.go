@@ -0,0 +1,70 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+)
+
+/*
+ * silence.go
+ * Have the server ignore people for us
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ErrNoSilence is returned by the SILENCE functions if the server doesn't advertise SILENCE in its ISUPPORT tokens.
+var ErrNoSilence = errors.New("server doesn't support SILENCE")
+
+// AddSilence asks the server not to send us messages from people matching mask, e.g. *!*@example.com, so they never reach us, unlike AddIgnoreMask.  If the list is full, the server's refusal (numeric 511) is sent to i.OnEvent as an ErrorEvent.  The server must support SILENCE, which isn't known until registration.
+func (i *IRC) AddSilence(mask string) error {
+	return i.silence("+" + mask)
+}
+
+// RemoveSilence removes mask, added with AddSilence, from the server's list.
+func (i *IRC) RemoveSilence(mask string) error {
+	return i.silence("-" + mask)
+}
+
+/* silence sends a SILENCE change, if the server supports it */
+func (i *IRC) silence(change string) error {
+	if _, ok := i.ISupport("SILENCE"); !ok {
+		return ErrNoSilence
+	}
+	return i.PrintfLine("SILENCE %v", change)
+}
+
+// ListSilence asks the server for the masks added with AddSilence (numerics 271 and 272).
+func (i *IRC) ListSilence(ctx context.Context) ([]string, error) {
+	if _, ok := i.ISupport("SILENCE"); !ok {
+		return nil, ErrNoSilence
+	}
+	ms, err := i.wait(ctx, func() error {
+		return i.PrintfLine("SILENCE")
+	}, func(m Message) (bool, bool) {
+		switch m.Command {
+		case "271": /* RPL_SILELIST */
+			return true, false
+		case "272": /* RPL_ENDOFSILELIST */
+			return false, true
+		}
+		return false, false
+	})
+	if nil != err {
+		return nil, err
+	}
+	/* Servers differ on whether our nick comes before the mask */
+	masks := make([]string, 0, len(ms))
+	for _, m := range ms {
+		masks = append(masks, m.Params[len(m.Params)-1])
+	}
+	return masks, nil
+}
+
+/* handleSilence reports the server refusing to add to the SILENCE list */
+func (i *IRC) handleSilence(m Message) {
+	if "511" == m.Command { /* ERR_SILELISTFULL */
+		i.event(ErrorEvent{Err: numericError(m)})
+	}
+}
@@ -0,0 +1,85 @@
+package minimalirc
+
+import (
+	"fmt"
+)
+
+/*
+ * state.go
+ * Connection state machine
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// State is the state of the connection to the server.
+type State int
+
+// States the connection can be in.
+const (
+	Disconnected State = iota /* Not connected, or connection lost */
+	Connecting                /* Dialing the server */
+	Connected                 /* Connected, but not yet registered */
+	Registered                /* The server's sent 001 */
+)
+
+func (s State) String() string {
+	switch s {
+	case Disconnected:
+		return "Disconnected"
+	case Connecting:
+		return "Connecting"
+	case Connected:
+		return "Connected"
+	case Registered:
+		return "Registered"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// StateEvent is sent when the connection changes state.
+type StateEvent struct {
+	Old State
+	New State
+}
+
+func (StateEvent) event() {}
+
+// PanicError is sent on i.E if the library (or a callback) panics while handling a message from the server.  Value is the value passed to panic.
+type PanicError struct {
+	Value interface{}
+	Stack []byte /* Stack trace at the time of the panic */
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while handling message: %v", e.Value)
+}
+
+// State returns the current state of the connection.
+func (i *IRC) State() State {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.state
+}
+
+// setState changes the connection state, and sends a StateEvent if it's
+// changed.  Scheduled messages are sent and
+// tasks started on registration.
+func (i *IRC) setState(s State) {
+	i.mu.Lock()
+	old := i.state
+	i.state = s
+	i.mu.Unlock()
+	if old == s {
+		return
+	}
+	/* Send anything which waited for registration, and start the
+	regular tasks */
+	if Registered == s {
+		go i.runScheduled()
+		i.startTasks()
+	}
+	i.audit(AuditState, "%v -> %v", old, s)
+	i.event(StateEvent{Old: old, New: s})
+}
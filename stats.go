@@ -0,0 +1,221 @@
+package minimalirc
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ * stats.go
+ * Count and throttle traffic
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* rateWindow is the number of seconds over which rates are averaged */
+const rateWindow = 10
+
+// Stats holds traffic counts for the current connection.  Rates are in bytes per second, averaged over the last ten seconds.
+type Stats struct {
+	Since    time.Time /* When the connection was started */
+	BytesIn  uint64
+	BytesOut uint64
+	LinesIn  uint64
+	LinesOut uint64
+	RateIn   float64
+	RateOut  float64
+	LastIn   time.Time /* When the last line was received */
+	LastOut  time.Time /* When the last line was sent */
+
+	/* Time from PrintfLine being called to the line being written to
+	the connection, if i.TrackLatency is set */
+	SendLatency Histogram
+}
+
+/* latencyBounds are the upper bounds of the buckets in a Histogram */
+var latencyBounds = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// Histogram counts durations.  Counts[n] is the number of durations less than Bounds[n] but not less than Bounds[n-1]; the last element of Counts counts the durations at least as long as the last bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64        /* Total durations counted */
+	Sum    time.Duration /* Total of the durations */
+	Max    time.Duration /* Longest duration */
+}
+
+/* add counts d */
+func (h *Histogram) add(d time.Duration) {
+	if nil == h.Counts {
+		h.Bounds = latencyBounds
+		h.Counts = make([]uint64, len(latencyBounds)+1)
+	}
+	n := sort.Search(len(h.Bounds), func(j int) bool {
+		return d < h.Bounds[j]
+	})
+	h.Counts[n]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Mean returns the average duration, or 0 if none have been counted.
+func (h Histogram) Mean() time.Duration {
+	if 0 == h.Count {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+/* copy returns a copy of h which doesn't share its slices */
+func (h Histogram) copy() Histogram {
+	h.Bounds = append([]time.Duration(nil), h.Bounds...)
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+/* counter counts traffic, and throttles writes */
+type counter struct {
+	sync.Mutex
+	s    Stats
+	in   rate
+	out  rate
+	next time.Time /* Earliest time for the next throttled write */
+}
+
+/* rate keeps per-second byte counts for the last rateWindow seconds */
+type rate struct {
+	buckets [rateWindow]uint64
+	last    int64 /* Unix time of the most recent bucket */
+}
+
+/* add adds n bytes to the current second */
+func (r *rate) add(now time.Time, n int) {
+	r.advance(now)
+	r.buckets[now.Unix()%rateWindow] += uint64(n)
+}
+
+/* perSecond returns the average bytes per second */
+func (r *rate) perSecond(now time.Time) float64 {
+	r.advance(now)
+	var t uint64
+	for _, b := range r.buckets {
+		t += b
+	}
+	return float64(t) / rateWindow
+}
+
+/* advance zeros buckets for the seconds since the last one used */
+func (r *rate) advance(now time.Time) {
+	u := now.Unix()
+	for s := r.last + 1; s <= u && s <= r.last+rateWindow; s++ {
+		r.buckets[s%rateWindow] = 0
+	}
+	if u > r.last {
+		r.last = u
+	}
+}
+
+// Stats returns traffic statistics for the current connection.
+func (i *IRC) Stats() Stats {
+	i.counter.Lock()
+	defer i.counter.Unlock()
+	now := time.Now()
+	s := i.counter.s
+	s.RateIn = i.counter.in.perSecond(now)
+	s.RateOut = i.counter.out.perSecond(now)
+	s.SendLatency = s.SendLatency.copy()
+	return s
+}
+
+/* resetStats zeros the stats, for a new connection */
+func (i *IRC) resetStats() {
+	i.counter.Lock()
+	defer i.counter.Unlock()
+	i.counter.s = Stats{Since: time.Now()}
+	i.counter.in = rate{}
+	i.counter.out = rate{}
+	i.counter.next = time.Time{}
+}
+
+// countLatency notes that lines given to PrintfLine at the times in queued
+// were written at sent
+func (i *IRC) countLatency(queued []time.Time, sent time.Time) {
+	i.counter.Lock()
+	defer i.counter.Unlock()
+	for _, q := range queued {
+		i.counter.s.SendLatency.add(sent.Sub(q))
+	}
+}
+
+/* countLine notes a line was sent or received */
+func (i *IRC) countLine(out bool) {
+	i.counter.Lock()
+	defer i.counter.Unlock()
+	if out {
+		i.counter.s.LinesOut++
+		i.counter.s.LastOut = time.Now()
+	} else {
+		i.counter.s.LinesIn++
+		i.counter.s.LastIn = time.Now()
+	}
+}
+
+// meter wraps the connection to the server to count bytes read and
+// written, and to throttle writes to i.MaxBytesPerSec
+type meter struct {
+	rw io.ReadWriter
+	i  *IRC
+}
+
+func (m meter) Read(p []byte) (int, error) {
+	n, err := m.rw.Read(p)
+	c := &m.i.counter
+	c.Lock()
+	c.s.BytesIn += uint64(n)
+	c.in.add(time.Now(), n)
+	c.Unlock()
+	return n, err
+}
+
+func (m meter) Write(p []byte) (int, error) {
+	/* Wait our turn, if we're throttling */
+	c := &m.i.counter
+	if max := m.i.MaxBytesPerSec; 0 < max {
+		c.Lock()
+		now := time.Now()
+		if c.next.Before(now) {
+			c.next = now
+		}
+		wait := c.next.Sub(now)
+		c.next = c.next.Add(time.Duration(len(p)) * time.Second /
+			time.Duration(max))
+		c.Unlock()
+		time.Sleep(wait)
+	}
+	n, err := m.rw.Write(p)
+	c.Lock()
+	c.s.BytesOut += uint64(n)
+	c.out.add(time.Now(), n)
+	c.Unlock()
+	return n, err
+}
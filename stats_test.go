@@ -0,0 +1,60 @@
+package minimalirc
+
+import (
+	"testing"
+)
+
+/*
+ * stats_test.go
+ * Benchmark the send path, with and without what slows it down
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* corkEvery is how many lines the corked benchmarks send per Flush */
+const corkEvery = 100
+
+func BenchmarkPrintfLine(b *testing.B) {
+	for _, c := range []struct {
+		name    string
+		limit   bool /* Set a Limiter which never makes us wait */
+		cork    bool /* Flush every corkEvery lines */
+		latency bool /* Set TrackLatency */
+	}{
+		{"Plain", false, false, false},
+		{"Limiter", true, false, false},
+		{"Cork", false, true, false},
+		{"TrackLatency", false, false, true},
+		{"Cork+TrackLatency", false, true, true},
+		{"All", true, true, true},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			i := New("irc.example.com", 6697, true, "", "me",
+				"user", "Real")
+			i.Feed(":irc.example.com 001 me :Welcome")
+			if c.limit {
+				i.Limiter = NewLimiter(1e12, 1e9)
+			}
+			i.TrackLatency = c.latency
+			if c.cork {
+				i.Cork()
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				err := i.PrintfLine("PRIVMSG #channel :line %v", n)
+				if nil != err {
+					b.Fatalf("error sending: %v", err)
+				}
+				if c.cork && 0 == n%corkEvery {
+					i.Flush()
+				}
+			}
+			if c.cork {
+				i.Uncork()
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package minimalirc
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * statusmsg.go
+ * Messages to some of a channel's members, e.g. @#channel
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// UnsupportedStatusError is returned when sending to a target with a channel membership prefix (e.g. @#channel) which the server's STATUSMSG ISUPPORT token doesn't list.  Most servers would send such a message to the whole channel, or reject it.
+type UnsupportedStatusError struct {
+	Target    string /* Target, prefix and all */
+	Supported string /* The server's STATUSMSG, maybe empty */
+}
+
+func (e *UnsupportedStatusError) Error() string {
+	if "" == e.Supported {
+		return fmt.Sprintf("server doesn't support sending to %v",
+			e.Target)
+	}
+	return fmt.Sprintf("server only supports sending to channel members "+
+		"with prefixes %v, not %v", e.Supported, e.Target)
+}
+
+// StatusPrefixes returns the channel membership prefixes (e.g. @ and +) which may be put before a channel's name to send a PRIVMSG or NOTICE only to the channel's members with that status or higher, per the server's STATUSMSG ISUPPORT token.  It returns the empty string if the server didn't send STATUSMSG.
+func (i *IRC) StatusPrefixes() string {
+	v, _ := i.ISupport("STATUSMSG")
+	return v
+}
+
+// SplitStatus splits target, as in a PRIVMSG or NOTICE, into the membership prefixes in StatusPrefixes and the channel, e.g. "@" and "#ops" for "@#ops".  If target doesn't start with any of StatusPrefixes followed by a channel, prefixes is empty and channel is target.
+func (i *IRC) SplitStatus(target string) (prefixes, channel string) {
+	return splitStatus(target, i.StatusPrefixes())
+}
+
+// splitStatus splits the characters in prefixes from the start of target,
+// if what's left is a channel
+func splitStatus(target, prefixes string) (string, string) {
+	n := 0
+	for n < len(target) && -1 != strings.IndexByte(prefixes, target[n]) {
+		n++
+	}
+	if 0 == n || !isChannel(target[n:]) {
+		return "", target
+	}
+	return target[:n], target[n:]
+}
+
+// checkStatus returns an *UnsupportedStatusError if target looks like a
+// channel with membership prefixes the server hasn't said it supports.  Since
+// & and + start channel names as well, targets like +#channel which might be
+// channels are allowed.
+func (i *IRC) checkStatus(target string) error {
+	sp := i.StatusPrefixes()
+	if p, _ := splitStatus(target, sp); "" != p {
+		return nil
+	}
+	if p, _ := splitStatus(target, memberPrefixes); "" == p ||
+		isChannel(target) {
+		return nil
+	}
+	return &UnsupportedStatusError{Target: target, Supported: sp}
+}
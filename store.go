@@ -0,0 +1,154 @@
+package minimalirc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+/*
+ * store.go
+ * Keep things across restarts
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Store keeps values which should survive the program restarting, like Deliver's queue of messages for offline nicks.  Each part of the library which uses a Store keeps its values in its own namespace, under keys made from i.Name (or i.Host, i.Port, and i.Nick if i.Name is empty), so connections sharing a Store keep separate values as long as they have different Names.  Set i.Store to use one.  FileStore is a simple Store; others may be backed by a database.  Stores may be shared between connections and must be safe for concurrent use.
+type Store interface {
+	/* Get returns the value stored under key in namespace, and
+	whether there is one */
+	Get(namespace, key string) ([]byte, bool, error)
+	/* Put stores value under key in namespace */
+	Put(namespace, key string, value []byte) error
+	/* Delete removes key from namespace, if it's there */
+	Delete(namespace, key string) error
+}
+
+// FileStore is a Store which keeps everything in memory, and writes it all to a JSON file whenever it changes.  It's fine for small amounts of data, like a bot's settings.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	data map[string]map[string][]byte /* namespace -> key -> value */
+}
+
+// NewFileStore returns a FileStore backed by the file at path, loading what's already in the file if it exists.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, data: make(map[string]map[string][]byte)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if nil != err {
+		return nil, errors.New(fmt.Sprintf("unable to read store %v: "+
+			"%v", path, err))
+	}
+	if err := json.Unmarshal(b, &s.data); nil != err {
+		return nil, errors.New(fmt.Sprintf("unable to parse store "+
+			"%v: %v", path, err))
+	}
+	if nil == s.data {
+		s.data = make(map[string]map[string][]byte)
+	}
+	return s, nil
+}
+
+// Get returns the value stored under key in namespace.
+func (s *FileStore) Get(namespace, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[namespace][key]
+	return append([]byte(nil), v...), ok, nil
+}
+
+// Put stores value under key in namespace, and writes the file.
+func (s *FileStore) Put(namespace, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if nil == s.data[namespace] {
+		s.data[namespace] = make(map[string][]byte)
+	}
+	s.data[namespace][key] = append([]byte(nil), value...)
+	return s.save()
+}
+
+// Delete removes key from namespace, and writes the file.
+func (s *FileStore) Delete(namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[namespace][key]; !ok {
+		return nil
+	}
+	delete(s.data[namespace], key)
+	if 0 == len(s.data[namespace]) {
+		delete(s.data, namespace)
+	}
+	return s.save()
+}
+
+// save writes the data to a temporary file and renames it over the store's
+// file, so a crash mid-write doesn't lose everything.  s.mu must be held.
+func (s *FileStore) save() error {
+	b, err := json.Marshal(s.data)
+	if nil != err {
+		return errors.New(fmt.Sprintf("unable to encode store: %v",
+			err))
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); nil != err {
+		return errors.New(fmt.Sprintf("unable to write store %v: %v",
+			tmp, err))
+	}
+	if err := os.Rename(tmp, s.path); nil != err {
+		return errors.New(fmt.Sprintf("unable to replace store %v: "+
+			"%v", s.path, err))
+	}
+	return nil
+}
+
+// storeKey returns key, made specific to this connection so connections
+// sharing a Store don't overwrite each other's values.
+func (i *IRC) storeKey(key string) string {
+	if "" != i.Name {
+		return i.Name + "/" + key
+	}
+	return fmt.Sprintf("%v:%v/%v/%v", i.Host, i.Port, i.configNick(), key)
+}
+
+// storeJSON puts v, as JSON, under key in namespace in i.Store, if it's set.
+// Errors are sent as ErrorEvents.
+func (i *IRC) storeJSON(namespace, key string, v interface{}) {
+	if nil == i.Store {
+		return
+	}
+	b, err := json.Marshal(v)
+	if nil == err {
+		err = i.Store.Put(namespace, i.storeKey(key), b)
+	}
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error storing %v/%v: %v", namespace, key, err))})
+	}
+}
+
+// loadJSON gets the JSON under key in namespace from i.Store, if it's set,
+// into v, and returns whether there was any.  Errors are sent as
+// ErrorEvents.
+func (i *IRC) loadJSON(namespace, key string, v interface{}) bool {
+	if nil == i.Store {
+		return false
+	}
+	b, ok, err := i.Store.Get(namespace, i.storeKey(key))
+	if nil == err && ok {
+		err = json.Unmarshal(b, v)
+	}
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error loading %v/%v: %v", namespace, key, err))})
+		return false
+	}
+	return ok
+}
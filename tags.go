@@ -0,0 +1,115 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+ * tags.go
+ * Send and receive IRCv3 message tags
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ErrNoMessageTags is returned when sending tags without the message-tags capability.  Add "message-tags" to i.Caps to request it.
+var ErrNoMessageTags = errors.New("message-tags capability not enabled")
+
+// TagmsgEvent is sent when a TAGMSG is received, e.g. a typing notification or reaction.
+type TagmsgEvent struct {
+	From   string            /* nick!user@host of the sender */
+	Target string            /* Channel or our nick */
+	Tags   map[string]string /* The message's tags */
+}
+
+func (TagmsgEvent) event() {}
+
+// Tagmsg sends a TAGMSG with the given tags to target (see Privmsg for the meaning of target).  Client-only tag names should start with a +, e.g. +typing.  The message-tags capability must be enabled.
+func (i *IRC) Tagmsg(target string, tags map[string]string) error {
+	t := i.target(target)
+	if "" == t {
+		return nil
+	}
+	return i.printfLineTags(tags, "TAGMSG %v", t)
+}
+
+// PrivmsgTags is like Privmsg, but sends the message with the given tags.  The message-tags capability must be enabled for client-only tags.
+func (i *IRC) PrivmsgTags(msg, target string, tags map[string]string) error {
+	t := i.target(target)
+	if "" == t {
+		return nil
+	}
+	if err := i.guard(t); nil != err {
+		return err
+	}
+	msg, err := i.interceptOut(t, msg)
+	if nil != err {
+		return err
+	}
+	return i.printfLineTags(tags, "PRIVMSG %v :%v", t, msg)
+}
+
+/* printfLineTags is like PrintfLine, but prepends tags */
+func (i *IRC) printfLineTags(tags map[string]string, f string,
+	args ...interface{}) error {
+	if 0 == len(tags) {
+		return i.PrintfLine(f, args...)
+	}
+	if !i.HasCap("message-tags") {
+		return ErrNoMessageTags
+	}
+	return i.PrintfLine("@%v %v", FormatTags(tags),
+		fmt.Sprintf(f, args...))
+}
+
+// FormatTags formats tags for sending, without the leading @.  Tags are sorted by name and values are escaped.
+func FormatTags(tags map[string]string) string {
+	ks := make([]string, 0, len(tags))
+	for k := range tags {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	ts := make([]string, len(ks))
+	for n, k := range ks {
+		if "" == tags[k] {
+			ts[n] = k
+			continue
+		}
+		ts[n] = k + "=" + escapeTag(tags[k])
+	}
+	return strings.Join(ts, ";")
+}
+
+/* tagEscaper escapes tag values */
+var tagEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\:`,
+	" ", `\s`,
+	"\r", `\r`,
+	"\n", `\n`,
+)
+
+/* escapeTag escapes a tag value */
+func escapeTag(v string) string {
+	return tagEscaper.Replace(v)
+}
+
+/* handleTagmsg turns TAGMSGs into events */
+func (i *IRC) handleTagmsg(m Message) {
+	if "TAGMSG" != m.Command {
+		return
+	}
+	i.event(TagmsgEvent{From: m.Prefix, Target: m.Param(0), Tags: m.Tags})
+	if s, ok := m.Tags["+typing"]; ok {
+		i.event(TypingEvent{
+			From:   m.Prefix,
+			Nick:   m.Nick,
+			Target: m.Param(0),
+			State:  s,
+		})
+	}
+}
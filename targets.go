@@ -0,0 +1,139 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ * targets.go
+ * Send to several targets at once
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Broadcast sends msg to each of targets.  If the server's ISUPPORT TARGMAX or MAXTARGETS allow, targets are sent to several at a time with comma-separated lists, as long as the line stays under the server's length limit, otherwise one PRIVMSG is sent per target.  Targets with an Interceptor are always sent to separately.  Channels are guarded as with Privmsg.  The first error encountered is returned, after trying the rest of the targets.
+func (i *IRC) Broadcast(msg string, targets ...string) error {
+	return i.broadcast(PriorityReply, msg, targets)
+}
+
+/* broadcast is Broadcast, sending with priority p */
+func (i *IRC) broadcast(p Priority, msg string, targets []string) error {
+	var (
+		first error
+		batch []string
+	)
+	note := func(err error) {
+		if nil != err && nil == first {
+			first = err
+		}
+	}
+	for _, t := range targets {
+		if "" == t {
+			continue
+		}
+		/* Interceptors may change the message per-target */
+		if _, ok := i.interceptor(t); ok {
+			note(i.privmsg(p, msg, t))
+			continue
+		}
+		if err := i.guard(t); nil != err {
+			note(err)
+			continue
+		}
+		batch = append(batch, t)
+	}
+	/* Long lists of long names can push the line past the server's limit */
+	fits := func(c []string) bool {
+		return len(msg) <= i.PrivmsgSize(strings.Join(c, ","))
+	}
+	for _, c := range chunk(batch, i.maxTargets("PRIVMSG"), fits) {
+		note(i.PrintfLinePriority(p, "PRIVMSG %v :%v",
+			strings.Join(c, ","), msg))
+	}
+	return first
+}
+
+// JoinMany joins several channels, with keys in the same order as the channels (missing keys are treated as empty).  The channels are joined as many at a time as the server's ISUPPORT TARGMAX and the 510-byte line limit allow.
+func (i *IRC) JoinMany(channels []string, keys []string) error {
+	/* Pad keys out to match channels, to keep them lined up */
+	ks := make([]string, len(channels))
+	copy(ks, keys)
+	/* Each chunk starts where the last left off */
+	var off int
+	line := func(c []string) string {
+		l := "JOIN " + strings.Join(c, ",")
+		k := ks[off : off+len(c)]
+		/* Keys are positional, but trailing empty ones aren't needed */
+		for 0 != len(k) && "" == k[len(k)-1] {
+			k = k[:len(k)-1]
+		}
+		if 0 != len(k) {
+			l += " " + strings.Join(k, ",")
+		}
+		return l
+	}
+	fits := func(c []string) bool { return 510 >= len(line(c)) }
+	for _, c := range chunk(channels, i.maxTargets("JOIN"), fits) {
+		if err := i.PrintfLine("%s", line(c)); nil != err {
+			return errors.New(fmt.Sprintf("error joining %v: %v",
+				strings.Join(c, ","), err))
+		}
+		off += len(c)
+	}
+	return nil
+}
+
+// maxTargets returns the number of targets the server allows for command,
+// or 0 if there's no limit.  TARGMAX is used if the server sent it, and
+// otherwise MAXTARGETS for PRIVMSG and NOTICE.  JOIN is unlimited without
+// TARGMAX, and anything else is limited to one target.
+func (i *IRC) maxTargets(command string) int {
+	if v, ok := i.ISupport("TARGMAX"); ok {
+		for _, e := range strings.Split(v, ",") {
+			parts := strings.SplitN(e, ":", 2)
+			if 2 != len(parts) || !strings.EqualFold(parts[0], command) {
+				continue
+			}
+			if "" == parts[1] {
+				return 0
+			}
+			if n, err := strconv.Atoi(parts[1]); nil == err && 0 < n {
+				return n
+			}
+			return 1
+		}
+		return 1
+	}
+	switch command {
+	case "PRIVMSG", "NOTICE":
+		if n := i.isupportInt("MAXTARGETS", 1); 0 < n {
+			return n
+		}
+		return 1
+	case "JOIN":
+		return 0
+	}
+	return 1
+}
+
+// chunk splits ts into slices of at most n, or any number if n is 0, for
+// which fits returns true.  A nil fits accepts anything.  A slice of one is
+// used even if it doesn't fit, as there's no smaller way to send it.
+func chunk(ts []string, n int, fits func([]string) bool) [][]string {
+	var cs [][]string
+	for 0 != len(ts) {
+		e := 1
+		for e < len(ts) && (0 == n || e < n) &&
+			(nil == fits || fits(ts[:e+1])) {
+			e++
+		}
+		cs = append(cs, ts[:e])
+		ts = ts[e:]
+	}
+	return cs
+}
@@ -0,0 +1,116 @@
+package minimalirc
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+ * targets_test.go
+ * Test sending to several targets at once
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* newTargetsTest returns an IRC struct registered with the given ISUPPORT */
+func newTargetsTest(t *testing.T, isupport string) (*IRC, *sentLines) {
+	i := New("irc.example.com", 6697, true, "", "nick", "u", "r")
+	sent := &sentLines{}
+	i.use(sent)
+	i.Feed(":irc.example.com 001 nick :Welcome")
+	i.Feed(":irc.example.com 005 nick " + isupport + " :are supported")
+	sent.take()
+	return i, sent
+}
+
+/* targetCounts returns the number of targets in each of the lines */
+func targetCounts(t *testing.T, ls []string, prefix string) []int {
+	var ns []int
+	for _, l := range ls {
+		if !strings.HasPrefix(l, prefix) {
+			t.Fatalf("unexpected line %q", l)
+		}
+		if 510 < len(l) {
+			t.Errorf("line too long (%v bytes): %q", len(l), l)
+		}
+		ts := strings.SplitN(strings.TrimPrefix(l, prefix), " ", 2)[0]
+		ns = append(ns, len(strings.Split(ts, ",")))
+	}
+	return ns
+}
+
+/* checkCounts fails t if got isn't want */
+func checkCounts(t *testing.T, got []int, want ...int) {
+	if len(got) != len(want) {
+		t.Fatalf("got batches of %v, not %v", got, want)
+	}
+	for n := range got {
+		if got[n] != want[n] {
+			t.Fatalf("got batches of %v, not %v", got, want)
+		}
+	}
+}
+
+func TestBroadcastCount(t *testing.T) {
+	i, sent := newTargetsTest(t, "TARGMAX=PRIVMSG:4")
+	if err := i.Broadcast("hi", "a", "b", "c", "d", "e"); nil != err {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	checkCounts(t, targetCounts(t, sent.take(), "PRIVMSG "), 4, 1)
+}
+
+func TestBroadcastLength(t *testing.T) {
+	i, sent := newTargetsTest(t, "TARGMAX=PRIVMSG:")
+	var ts []string
+	for _, c := range "abcde" {
+		ts = append(ts, strings.Repeat(string(c), 120))
+	}
+	if err := i.Broadcast("hi", ts...); nil != err {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	checkCounts(t, targetCounts(t, sent.take(), "PRIVMSG "), 3, 2)
+}
+
+func TestJoinManyCount(t *testing.T) {
+	i, sent := newTargetsTest(t, "TARGMAX=JOIN:2")
+	err := i.JoinMany([]string{"#a", "#b", "#c"}, []string{"", "k"})
+	if nil != err {
+		t.Fatalf("JoinMany: %v", err)
+	}
+	want := []string{"JOIN #a,#b ,k", "JOIN #c"}
+	got := sent.take()
+	if len(got) != len(want) {
+		t.Fatalf("sent %q, not %q", got, want)
+	}
+	for n := range got {
+		if got[n] != want[n] {
+			t.Fatalf("sent %q, not %q", got, want)
+		}
+	}
+}
+
+func TestJoinManyLength(t *testing.T) {
+	i, sent := newTargetsTest(t, "CHANTYPES=#")
+	var cs, ks []string
+	for _, c := range "abcde" {
+		cs = append(cs, "#"+strings.Repeat(string(c), 80))
+		ks = append(ks, strings.Repeat(string(c), 40))
+	}
+	if err := i.JoinMany(cs, ks); nil != err {
+		t.Fatalf("JoinMany: %v", err)
+	}
+	got := sent.take()
+	checkCounts(t, targetCounts(t, got, "JOIN "), 4, 1)
+	/* The keys should stay with their channels */
+	for _, l := range got {
+		f := strings.Fields(l)
+		cs, ks := strings.Split(f[1], ","), strings.Split(f[2], ",")
+		for n := range cs {
+			if cs[n][1:41] != ks[n] {
+				t.Errorf("key %q sent for %q", ks[n], cs[n])
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package minimalirc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+ * tasks.go
+ * Run things regularly while connected
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+/* task is a function run regularly while we're registered */
+type task struct {
+	every time.Duration
+	f     func(ctx context.Context)
+	ctx   context.Context /* Connection for which it's running */
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// Every calls f every d while we're registered with the server.  The ticking starts at registration and stops when the connection ends, so after a reconnect f is next called d after registration.  f is passed a context which is cancelled when the connection ends.  Calls to f for the same task don't overlap.  The returned function stops the task.  d must be positive.
+func (i *IRC) Every(d time.Duration, f func(ctx context.Context)) func() {
+	if 0 >= d {
+		panic("non-positive interval for Every")
+	}
+	t := &task{every: d, f: f, stop: make(chan struct{})}
+	i.mu.Lock()
+	i.tasks = append(i.tasks, t)
+	i.mu.Unlock()
+	i.startTasks()
+	return func() {
+		t.once.Do(func() { close(t.stop) })
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		for n, o := range i.tasks {
+			if o == t {
+				i.tasks = append(i.tasks[:n], i.tasks[n+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// startTasks starts the tasks and idle functions not already running for
+// this connection, if we're registered
+func (i *IRC) startTasks() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if Registered != i.state || nil == i.ctx {
+		return
+	}
+	for _, t := range i.tasks {
+		if t.ctx == i.ctx {
+			continue
+		}
+		t.ctx = i.ctx
+		go t.run(t.ctx)
+	}
+	for _, t := range i.idles {
+		if t.ctx == i.ctx {
+			continue
+		}
+		t.ctx = i.ctx
+		go t.run(t.ctx, i)
+	}
+}
+
+/* run calls t.f every t.every until ctx is done or t is stopped */
+func (t *task) run(ctx context.Context) {
+	tk := time.NewTicker(t.every)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			t.f(ctx)
+		case <-ctx.Done():
+			return
+		case <-t.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+ * tee.go
+ * Copy received lines somewhere else
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// TeeTo copies every line received from the server to w, one per line, before the library does anything else with it, without needing to read i.C.  If i.TeeTimestamps is set, each line is preceded by the time it was received in RFC3339 format with nanoseconds and a space.  Lines are copied as received, passwords and all.  If writing to w fails, an ErrorEvent is sent and copying stops.  Calling TeeTo with nil stops copying.
+func (i *IRC) TeeTo(w io.Writer) {
+	i.teemu.Lock()
+	defer i.teemu.Unlock()
+	i.tee = w
+}
+
+/* teeLine copies line to the writer given to TeeTo, if there is one */
+func (i *IRC) teeLine(line string) {
+	i.teemu.Lock()
+	if nil == i.tee {
+		i.teemu.Unlock()
+		return
+	}
+	if i.TeeTimestamps {
+		line = time.Now().Format(time.RFC3339Nano) + " " + line
+	}
+	_, err := io.WriteString(i.tee, line+"\n")
+	if nil != err {
+		i.tee = nil
+	}
+	i.teemu.Unlock()
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error copying received lines: %v", err))})
+	}
+}
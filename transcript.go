@@ -0,0 +1,123 @@
+package minimalirc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+ * transcript.go
+ * Record and replay sessions
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// Transcripts are written to i.Transcript, if it's set, one line per line sent to or received from the server.  Each transcript line is the time in RFC3339 format with nanoseconds, a space, a direction, a space, and the line as sent or received, without the CRLF.  The direction is < for lines from the server and > for lines to the server.  For example:
+//
+//	2026-10-16T12:00:00.123456789Z > NICK jbond
+//	2026-10-16T12:00:00.234567891Z < :irc.example.com 001 jbond :Welcome
+//
+// Blank lines and lines starting with # are ignored when reading a transcript.
+const (
+	TranscriptReceived = "<"
+	TranscriptSent     = ">"
+)
+
+// TranscriptEntry is a line from a transcript.
+type TranscriptEntry struct {
+	Time time.Time
+	Sent bool   /* True if sent to the server, false if received */
+	Line string /* Line as sent or received, without the CRLF */
+}
+
+// String returns e in transcript format.
+func (e TranscriptEntry) String() string {
+	d := TranscriptReceived
+	if e.Sent {
+		d = TranscriptSent
+	}
+	return fmt.Sprintf("%v %v %v", e.Time.Format(time.RFC3339Nano), d,
+		e.Line)
+}
+
+// ParseTranscriptEntry parses a line of a transcript.
+func ParseTranscriptEntry(s string) (TranscriptEntry, error) {
+	parts := strings.SplitN(s, " ", 3)
+	if 3 != len(parts) {
+		return TranscriptEntry{}, errors.New(fmt.Sprintf("malformed "+
+			"transcript line %q", s))
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if nil != err {
+		return TranscriptEntry{}, errors.New(fmt.Sprintf("bad time in "+
+			"transcript line %q: %v", s, err))
+	}
+	e := TranscriptEntry{Time: t, Line: parts[2]}
+	switch parts[1] {
+	case TranscriptReceived:
+	case TranscriptSent:
+		e.Sent = true
+	default:
+		return TranscriptEntry{}, errors.New(fmt.Sprintf("bad "+
+			"direction in transcript line %q", s))
+	}
+	return e, nil
+}
+
+// record writes line to the transcript, if there is one.  Errors writing
+// the transcript are ignored, as with logging.
+func (i *IRC) record(sent bool, line string) {
+	w := i.Transcript
+	if nil == w {
+		return
+	}
+	e := TranscriptEntry{Time: time.Now(), Sent: sent, Line: line}
+	i.tmu.Lock()
+	defer i.tmu.Unlock()
+	io.WriteString(w, e.String()+"\n")
+}
+
+// Replay feeds the lines received from the server in the transcript read from r through the library as if they came from a server, as Start does with a connection.  Lines sent to the server are discarded, as are the lines in the transcript which were sent to the server.  Lines are replayed as fast as they're read.  When r is exhausted, i.C is closed and io.EOF sent on i.E; if the transcript is malformed, the parse error is sent instead.
+func (i *IRC) Replay(r io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, 1024*1024)
+		for s.Scan() {
+			l := s.Text()
+			if "" == strings.TrimSpace(l) || strings.HasPrefix(l, "#") {
+				continue
+			}
+			e, err := ParseTranscriptEntry(l)
+			if nil != err {
+				pw.CloseWithError(err)
+				return
+			}
+			if e.Sent {
+				continue
+			}
+			/* Fails if the reader's been closed */
+			if _, err := io.WriteString(pw, e.Line+"\r\n"); nil != err {
+				return
+			}
+		}
+		pw.CloseWithError(s.Err())
+	}()
+	return i.Start(replayConn{pr})
+}
+
+/* replayConn is a connection to a transcript being replayed */
+type replayConn struct {
+	*io.PipeReader
+}
+
+/* Write discards p */
+func (replayConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
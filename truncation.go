@@ -0,0 +1,120 @@
+package minimalirc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ * truncation.go
+ * Notice when the server cuts our messages short
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+const (
+	/* maxEchoWait is how long we wait for a message to be echoed */
+	maxEchoWait = time.Minute
+	/* maxUnechoed is the most messages per target awaiting echoes */
+	maxUnechoed = 32
+)
+
+// TruncatedEvent is sent when the server echoes one of our messages back shorter than it was sent, which means i.Msglen is too high for the network.  The echo-message capability must be in i.Caps.  Only messages sent with Privmsg and Notice are checked.  If i.ResendTruncated is true, the lost text is sent again.
+type TruncatedEvent struct {
+	Command  string /* PRIVMSG or NOTICE */
+	Target   string
+	Sent     string /* Text we sent */
+	Received string /* Text the server echoed */
+	Lost     string /* Text cut off the end */
+}
+
+func (TruncatedEvent) event() {}
+
+/* unechoed is a message we've sent but not yet seen echoed */
+type unechoed struct {
+	command string
+	text    string
+	at      time.Time
+}
+
+// expectEcho notes that we've sent text to target with command, if the
+// server will echo it
+func (i *IRC) expectEcho(command, target, text string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !i.caps["echo-message"] {
+		return
+	}
+	if nil == i.unechoed {
+		i.unechoed = make(map[string][]unechoed)
+	}
+	k := fold(target)
+	/* Forget what we'll never see */
+	us := i.unechoed[k]
+	for 0 != len(us) && (maxUnechoed <= len(us) ||
+		maxEchoWait < time.Since(us[0].at)) {
+		us = us[1:]
+	}
+	i.unechoed[k] = append(us, unechoed{
+		command: command,
+		text:    text,
+		at:      time.Now(),
+	})
+}
+
+// checkEcho compares an echo of one of our messages with what we sent, and
+// reports and optionally resends any text the server cut off
+func (i *IRC) checkEcho(m Message) {
+	if ("PRIVMSG" != m.Command && "NOTICE" != m.Command) ||
+		!i.isMe(m.Nick) || 2 > len(m.Params) {
+		return
+	}
+	/* Work out which message it is */
+	k := fold(m.Param(0))
+	i.mu.Lock()
+	us := i.unechoed[k]
+	if 0 == len(us) {
+		i.mu.Unlock()
+		return
+	}
+	u := us[0]
+	if 1 == len(us) {
+		delete(i.unechoed, k)
+	} else {
+		i.unechoed[k] = us[1:]
+	}
+	i.mu.Unlock()
+
+	/* Cut short? */
+	got := m.Trailing()
+	if u.command != m.Command || len(got) >= len(u.text) ||
+		!strings.HasPrefix(u.text, got) {
+		return
+	}
+	e := TruncatedEvent{
+		Command:  m.Command,
+		Target:   m.Param(0),
+		Sent:     u.text,
+		Received: got,
+		Lost:     u.text[len(got):],
+	}
+	i.event(e)
+	if !i.ResendTruncated || i.PassiveMode {
+		return
+	}
+	var err error
+	if "NOTICE" == e.Command {
+		err = i.Notice(e.Lost, e.Target)
+	} else {
+		err = i.Privmsg(e.Lost, e.Target)
+	}
+	if nil != err {
+		i.event(ErrorEvent{Err: errors.New(fmt.Sprintf(
+			"error resending truncated text to %v: %v", e.Target,
+			err))})
+	}
+}
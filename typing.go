@@ -0,0 +1,120 @@
+package minimalirc
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+ * typing.go
+ * Typing notifications
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// States for the +typing tag.
+const (
+	TypingActive = "active" /* Typing */
+	TypingPaused = "paused" /* Stopped typing, but not given up */
+	TypingDone   = "done"   /* Sent the message, or given up */
+)
+
+/* typingRefresh is how often an active typing notification is resent */
+const typingRefresh = 3 * time.Second
+
+// TypingEvent is sent when a TAGMSG with a +typing tag is received.  Senders resend active notifications every few seconds; one which isn't refreshed for six seconds, or a paused one which isn't for thirty, may be taken to mean done.  A TagmsgEvent is sent as well.
+type TypingEvent struct {
+	From   string /* nick!user@host of the sender */
+	Nick   string /* Sender's nick */
+	Target string /* Channel or our nick */
+	State  string /* TypingActive, TypingPaused, or TypingDone */
+}
+
+func (TypingEvent) event() {}
+
+// Typing sends a typing notification with the given state (e.g. TypingActive) to target (see Privmsg for the meaning of target).  The message-tags capability must be enabled.  Use StartTyping to keep an active notification fresh.
+func (i *IRC) Typing(target, state string) error {
+	return i.Tagmsg(target, map[string]string{"+typing": state})
+}
+
+// Typist keeps a typing notification going to a target while a response is composed.  It's safe to call its methods from multiple goroutines.
+type Typist struct {
+	i      *IRC
+	target string
+	mu     sync.Mutex
+	state  string /* Last state sent */
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// StartTyping tells target we're typing, and keeps telling it every few seconds, except while paused, until the returned Typist's Done method is called or the connection ends.  If the message-tags capability isn't enabled, nothing is sent.  Errors sending notifications are sent as ErrorEvents.  A typical use is defer i.StartTyping(target).Done() before doing something slow.
+func (i *IRC) StartTyping(target string) *Typist {
+	t := &Typist{i: i, target: target, stop: make(chan struct{})}
+	i.mu.Lock()
+	ctx := i.ctx
+	i.mu.Unlock()
+	t.Active()
+	if nil != ctx {
+		go t.refresh(ctx.Done())
+	}
+	return t
+}
+
+// Active says we're typing again after a call to Pause.  It does nothing after Done has been called.
+func (t *Typist) Active() {
+	t.set(TypingActive)
+}
+
+// Pause says we've stopped typing for now.  It does nothing after Done has been called.
+func (t *Typist) Pause() {
+	t.set(TypingPaused)
+}
+
+// Done says we've sent the message or given up, and stops further notifications.  Calls after the first have no effect.
+func (t *Typist) Done() {
+	t.set(TypingDone)
+	t.once.Do(func() { close(t.stop) })
+}
+
+/* set sends state, unless Done's already been sent */
+func (t *Typist) set(state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if TypingDone == t.state {
+		return
+	}
+	t.state = state
+	t.send()
+}
+
+/* send sends the current state.  t.mu must be held. */
+func (t *Typist) send() {
+	if !t.i.HasCap("message-tags") {
+		return
+	}
+	if err := t.i.Typing(t.target, t.state); nil != err {
+		t.i.event(ErrorEvent{Err: err})
+	}
+}
+
+/* refresh resends active notifications until t is done or gone is closed */
+func (t *Typist) refresh(gone <-chan struct{}) {
+	tk := time.NewTicker(typingRefresh)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+		case <-t.stop:
+			return
+		case <-gone:
+			return
+		}
+		t.mu.Lock()
+		if TypingActive == t.state {
+			t.send()
+		}
+		t.mu.Unlock()
+	}
+}
@@ -0,0 +1,317 @@
+// Package urltitle replies to URLs seen on IRC with the titles of the pages to which they point.
+package urltitle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kd5pbo/minimalirc"
+)
+
+/*
+ * urltitle.go
+ * Reply to URLs with page titles
+ * created 20261016
+ * last modified 20261016
+ *
+ * See ../minimalirc.go for license details.
+ */
+
+var (
+	/* urlRE finds URLs in messages */
+	urlRE = regexp.MustCompile(`https?://[^\s<>"]+`)
+	/* titleRE finds a page's title */
+	titleRE = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+/* maxTitle is the longest title which will be sent */
+const maxTitle = 200
+
+/* defaultMaxBytes is used if Titler.MaxBytes isn't set */
+const defaultMaxBytes = 64 * 1024
+
+/* maxRedirects is the number of redirects followed, as with http.Client */
+const maxRedirects = 10
+
+// privateNets are the networks of addresses which aren't fetched unless
+// Titler.AllowPrivate is set: loopback, private, shared (CGNAT), link-local
+// (including cloud metadata services), and unspecified addresses
+var privateNets = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+// Titler watches PRIVMSGs for URLs and replies with the titles of the pages.  Its fields should not be changed after it's attached to an IRC struct.  Since anybody can send a URL, Allow and Deny are checked for every redirect as well as the first URL, and unless AllowPrivate is set, connections to loopback, private, and link-local addresses (e.g. 169.254.169.254) are refused after names are resolved.  To make that check, Client's Transport must be nil or an *http.Transport; its proxy settings are ignored unless AllowPrivate is set.
+type Titler struct {
+	Client       *http.Client  /* Client used to fetch pages */
+	Allow        []string      /* Domains to fetch, all if empty */
+	Deny         []string      /* Domains never to fetch */
+	Interval     time.Duration /* Minimum time between replies per target */
+	MaxBytes     int64         /* Maximum bytes of a page to read, 64k if 0 */
+	AllowPrivate bool          /* Fetch from loopback and private addresses */
+
+	mu     sync.Mutex
+	last   map[string]time.Time /* Last reply time per target */
+	client *http.Client         /* Client, made safe to use */
+}
+
+// New returns a Titler with sensible defaults: a 10-second timeout on fetches, at most one reply per target every 10 seconds, and 64k read from each page.
+func New() *Titler {
+	return &Titler{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Interval: 10 * time.Second,
+		MaxBytes: 64 * 1024,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// Attach registers t as a handler on i, usually an *minimalirc.IRC.
+func (t *Titler) Attach(i minimalirc.Receiver) {
+	i.Handle(t.Handle)
+}
+
+// Handle is a minimalirc.Handler which looks for a URL in PRIVMSGs and replies with the title of the page, if there is one.  Fetches happen in their own goroutine, and are cancelled if the connection closes.  Replies are sent with the Messenger from minimalirc.MessengerFromContext.
+func (t *Titler) Handle(ctx context.Context, m minimalirc.Message) {
+	if "PRIVMSG" != m.Command {
+		return
+	}
+	u := urlRE.FindString(m.Trailing())
+	if "" == u || !t.allowed(u) {
+		return
+	}
+	i, ok := minimalirc.MessengerFromContext(ctx)
+	if !ok {
+		return
+	}
+	/* Reply to the channel, or to the sender if it was sent to us */
+	target := m.Param(0)
+	if "" == target {
+		return
+	}
+	if !strings.ContainsAny(target[:1], "#&+!") {
+		target = m.Nick
+	}
+	if !t.ready(target) {
+		return
+	}
+	go func() {
+		title, err := t.Title(ctx, u)
+		if nil != err || "" == title {
+			return
+		}
+		i.Privmsg("[ "+title+" ]", target)
+	}()
+}
+
+// Title fetches the page at u and returns its title, or the empty string if it's not text/html or has no title.  Only the first MaxBytes of the page are read.
+func (t *Titler) Title(ctx context.Context, u string) (string, error) {
+	if !t.allowed(u) {
+		return "", errors.New(fmt.Sprintf("not allowed to fetch %v", u))
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if nil != err {
+		return "", err
+	}
+	c, err := t.safeClient()
+	if nil != err {
+		return "", err
+	}
+	res, err := c.Do(req.WithContext(ctx))
+	if nil != err {
+		return "", err
+	}
+	defer res.Body.Close()
+	/* Only HTML has titles */
+	mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if "text/html" != mt {
+		return "", nil
+	}
+	max := t.MaxBytes
+	if 0 >= max {
+		max = defaultMaxBytes
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, max))
+	if nil != err {
+		return "", err
+	}
+	g := titleRE.FindSubmatch(b)
+	if nil == g {
+		return "", nil
+	}
+	/* Tidy it up and make it short enough to send */
+	title := strings.Join(strings.Fields(html.UnescapeString(string(g[1]))),
+		" ")
+	if r := []rune(title); len(r) > maxTitle {
+		title = string(r[:maxTitle]) + "..."
+	}
+	return title, nil
+}
+
+// safeClient returns a copy of t.Client (or a client with a 10-second timeout
+// if it's nil) which checks redirects with t.allowed and, unless
+// t.AllowPrivate is set, refuses to connect to private addresses
+func (t *Titler) safeClient() (*http.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if nil != t.client {
+		return t.client, nil
+	}
+	c := http.Client{Timeout: 10 * time.Second}
+	if nil != t.Client {
+		c = *t.Client
+	}
+	/* Check every hop, not just the first */
+	next := c.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !t.allowed(req.URL.String()) {
+			return errors.New(fmt.Sprintf("not allowed to follow "+
+				"redirect to %v", req.URL))
+		}
+		if nil != next {
+			return next(req, via)
+		}
+		if len(via) >= maxRedirects {
+			return errors.New(fmt.Sprintf("stopped after %v redirects",
+				maxRedirects))
+		}
+		return nil
+	}
+	/* Check addresses once they're resolved */
+	if !t.AllowPrivate {
+		var tr *http.Transport
+		switch rt := c.Transport.(type) {
+		case nil:
+			tr = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			tr = rt.Clone()
+		default:
+			return nil, errors.New("can't check the addresses used " +
+				"by Client's Transport")
+		}
+		d := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   refusePrivate,
+		}
+		tr.DialContext = d.DialContext
+		tr.Proxy = nil
+		c.Transport = tr
+	}
+	t.client = &c
+	return t.client, nil
+}
+
+// refusePrivate is a net.Dialer's Control function which refuses to connect
+// to addresses in privateNets
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	h, _, err := net.SplitHostPort(address)
+	if nil != err {
+		return err
+	}
+	ip := net.ParseIP(h)
+	if nil == ip {
+		return errors.New(fmt.Sprintf("unparseable address %v", h))
+	}
+	if ip.IsMulticast() || isPrivate(ip) {
+		return errors.New(fmt.Sprintf("refusing to connect to private "+
+			"address %v", ip))
+	}
+	return nil
+}
+
+/* isPrivate returns true if ip is in one of privateNets */
+func isPrivate(ip net.IP) bool {
+	if v4 := ip.To4(); nil != v4 {
+		ip = v4
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/* parseCIDRs parses networks in CIDR notation, panicking on error */
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	ns := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if nil != err {
+			panic(err)
+		}
+		ns[i] = n
+	}
+	return ns
+}
+
+/* allowed returns true if the host in u passes t.Allow and t.Deny */
+func (t *Titler) allowed(u string) bool {
+	p, err := url.Parse(u)
+	if nil != err {
+		return false
+	}
+	h := strings.ToLower(p.Hostname())
+	for _, d := range t.Deny {
+		if inDomain(h, d) {
+			return false
+		}
+	}
+	if 0 == len(t.Allow) {
+		return true
+	}
+	for _, d := range t.Allow {
+		if inDomain(h, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ready returns true and notes the time if it's been at least t.Interval
+// since the last reply to target
+func (t *Titler) ready(target string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if nil == t.last {
+		t.last = make(map[string]time.Time)
+	}
+	/* Forget targets which could have a reply anyway */
+	for k, v := range t.last {
+		if time.Since(v) >= t.Interval {
+			delete(t.last, k)
+		}
+	}
+	if time.Since(t.last[target]) < t.Interval {
+		return false
+	}
+	t.last[target] = time.Now()
+	return true
+}
+
+/* inDomain returns true if host is domain or a subdomain of it */
+func inDomain(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
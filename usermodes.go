@@ -0,0 +1,142 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+ * usermodes.go
+ * Keep track of and change our user modes
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// UserModes returns the user modes the server has told us we have, sorted, without a leading +, e.g. "iwx".  Modes are learned from MODE messages about us and RPL_UMODEIS (221) replies to MODE queries.
+func (i *IRC) UserModes() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ms := make([]string, 0, len(i.umodes))
+	for m := range i.umodes {
+		ms = append(ms, string(m))
+	}
+	sort.Strings(ms)
+	return strings.Join(ms, "")
+}
+
+// HasUserMode returns true if the server has told us we have user mode c.
+func (i *IRC) HasUserMode(c byte) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.umodes[c]
+}
+
+// SetUserMode asks the server to set the user modes in modes (e.g. "+iw" or "iw") and waits for it to say which modes we have.  An error is returned if any of the modes weren't set, or as a *NumericError if the server refuses.
+func (i *IRC) SetUserMode(ctx context.Context, modes string) error {
+	return i.changeUserModes(ctx, true, strings.TrimPrefix(modes, "+"))
+}
+
+// UnsetUserMode is like SetUserMode, but unsets the modes in modes (e.g. "-iw" or "iw").
+func (i *IRC) UnsetUserMode(ctx context.Context, modes string) error {
+	return i.changeUserModes(ctx, false, strings.TrimPrefix(modes, "-"))
+}
+
+// changeUserModes sets or unsets modes, then asks for our modes, as servers
+// don't always say anything about modes they won't set
+func (i *IRC) changeUserModes(ctx context.Context, set bool,
+	modes string) error {
+	if "" == modes {
+		return nil
+	}
+	sign := "+"
+	if !set {
+		sign = "-"
+	}
+	nick := i.SNick()
+	ms, err := i.wait(ctx, func() error {
+		i.Cork()
+		for _, l := range []string{
+			fmt.Sprintf("MODE %v %v%v", nick, sign, modes),
+			fmt.Sprintf("MODE %v", nick),
+		} {
+			if err := i.PrintfLine("%s", l); nil != err {
+				i.Uncork()
+				return err
+			}
+		}
+		return i.Uncork()
+	}, func(m Message) (bool, bool) {
+		switch m.Command {
+		case "221", /* RPL_UMODEIS */
+			"501", /* ERR_UMODEUNKNOWNFLAG */
+			"502": /* ERR_USERSDONTMATCH */
+			return true, true
+		}
+		return false, false
+	})
+	if nil != err {
+		return err
+	}
+	if m := ms[0]; "221" != m.Command {
+		return numericError(m)
+	}
+	/* Make sure we got what we asked for */
+	var wrong []string
+	for n := 0; n < len(modes); n++ {
+		if i.HasUserMode(modes[n]) != set {
+			wrong = append(wrong, string(modes[n]))
+		}
+	}
+	if 0 != len(wrong) {
+		return errors.New(fmt.Sprintf("server didn't change %v%v",
+			sign, strings.Join(wrong, "")))
+	}
+	return nil
+}
+
+/* trackUserModes keeps i.umodes up to date */
+func (i *IRC) trackUserModes(m Message) {
+	switch m.Command {
+	case "MODE":
+		if !i.isMe(m.Param(0)) || 2 > len(m.Params) {
+			return
+		}
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if nil == i.umodes {
+			i.umodes = make(map[byte]bool)
+		}
+		applyUserModes(i.umodes, m.Param(1))
+	case "221": /* RPL_UMODEIS */
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		i.umodes = make(map[byte]bool)
+		applyUserModes(i.umodes, m.Param(1))
+	}
+}
+
+/* applyUserModes applies a mode string like +ix-w to ms */
+func applyUserModes(ms map[byte]bool, modes string) {
+	adding := true
+	for n := 0; n < len(modes); n++ {
+		switch c := modes[n]; c {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		case ' ':
+			return
+		default:
+			if adding {
+				ms[c] = true
+			} else {
+				delete(ms, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package minimalirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+ * waiter.go
+ * Wait for replies from the server
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// ErrDisconnected is returned when waiting for a reply from the server is cut short by the connection ending.
+var ErrDisconnected = errors.New("disconnected")
+
+// NumericError is returned when the server replies to a request with an error numeric.
+type NumericError struct {
+	Numeric string /* e.g. 482 */
+	Text    string /* Reason from the server */
+}
+
+func (e *NumericError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Numeric, e.Text)
+}
+
+/* defaultRequestTimeout is used if i.RequestTimeout is 0 */
+const defaultRequestTimeout = 30 * time.Second
+
+// TimeoutError is returned when the server doesn't answer a request in time: by the deadline of the context passed in, or, if it has none, within i.RequestTimeout of the request or the last part of the reply.  It unwraps to context.DeadlineExceeded.
+type TimeoutError struct {
+	Waited time.Duration /* Time since the request was sent */
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("no reply from the server after %v", e.Waited)
+}
+
+// Timeout returns true, as with net.Error.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// requestTimeout returns how long to wait for the server to reply to a
+// request, or 0 to wait forever
+func (i *IRC) requestTimeout() time.Duration {
+	switch t := i.RequestTimeout; {
+	case 0 == t:
+		return defaultRequestTimeout
+	case 0 > t:
+		return 0
+	default:
+		return t
+	}
+}
+
+/* numericError makes a *NumericError from m */
+func numericError(m Message) *NumericError {
+	return &NumericError{Numeric: m.Command, Text: m.Trailing()}
+}
+
+// waiter collects messages from the server until it's seen the one it's
+// waiting for
+type waiter struct {
+	/* match is called for every message from the server, and returns
+	whether to keep the message and whether the wait is over */
+	match func(m Message) (keep, done bool)
+	mu    sync.Mutex    /* Held while matching, protects the below */
+	ms    []Message     /* Kept messages not yet taken */
+	gone  bool          /* No longer wants messages */
+	more  chan struct{} /* Poked when a message is kept */
+	done  chan struct{}
+}
+
+// wait sends a request to the server with send, and collects the replies
+// chosen by match until match says to stop.  An error is returned if send
+// fails, ctx is done, or we're disconnected.  If ctx has no deadline, a
+// *TimeoutError is returned if the server's quiet for i.RequestTimeout.
+// match is called in the goroutine reading from the server, and is not
+// called again once wait has returned.
+func (i *IRC) wait(
+	ctx context.Context,
+	send func() error,
+	match func(m Message) (keep, done bool),
+) ([]Message, error) {
+	return i.waitTimeout(ctx, i.requestTimeout(), send, match)
+}
+
+// waitTimeout is like wait, but gives up after the server's been quiet for
+// timeout instead of i.RequestTimeout, or never if timeout is 0
+func (i *IRC) waitTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+	send func() error,
+	match func(m Message) (keep, done bool),
+) ([]Message, error) {
+	var ms []Message
+	if err := i.streamTimeout(ctx, timeout, send, match, func(m Message) {
+		ms = append(ms, m)
+	}); nil != err {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// stream is like wait, but calls f with each reply kept by match as it
+// arrives, rather than returning them all at the end.  f is called in the
+// calling goroutine.
+func (i *IRC) stream(
+	ctx context.Context,
+	send func() error,
+	match func(m Message) (keep, done bool),
+	f func(m Message),
+) error {
+	return i.streamTimeout(ctx, i.requestTimeout(), send, match, f)
+}
+
+// streamTimeout is like stream, but with the timeout used if ctx has no
+// deadline, as with waitTimeout
+func (i *IRC) streamTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+	send func() error,
+	match func(m Message) (keep, done bool),
+	f func(m Message),
+) error {
+	/* Register before sending, so we can't miss the reply */
+	w := &waiter{
+		match: match,
+		more:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	i.mu.Lock()
+	conn := i.ctx
+	i.waiters = append(i.waiters, w)
+	i.mu.Unlock()
+	if nil == conn {
+		i.removeWaiter(w)
+		return ErrDisconnected
+	}
+	if err := send(); nil != err {
+		i.removeWaiter(w)
+		return err
+	}
+	/* Give up if the server goes quiet, unless the caller's said when
+	to give up */
+	var (
+		start = time.Now()
+		t     *time.Timer
+		quiet <-chan time.Time
+	)
+	if _, ok := ctx.Deadline(); !ok && 0 < timeout {
+		t = time.NewTimer(timeout)
+		defer t.Stop()
+		quiet = t.C
+	}
+	/* take passes on the messages kept so far */
+	take := func() {
+		w.mu.Lock()
+		ms := w.ms
+		w.ms = nil
+		w.mu.Unlock()
+		for _, m := range ms {
+			f(m)
+		}
+	}
+	for {
+		select {
+		case <-w.more:
+			take()
+			/* Each reply restarts the clock */
+			if nil != t && t.Stop() {
+				t.Reset(timeout)
+			}
+		case <-w.done:
+			take()
+			return nil
+		case <-quiet:
+			i.removeWaiter(w)
+			return &TimeoutError{Waited: time.Since(start)}
+		case <-ctx.Done():
+			i.removeWaiter(w)
+			if context.DeadlineExceeded == ctx.Err() {
+				return &TimeoutError{Waited: time.Since(start)}
+			}
+			return ctx.Err()
+		case <-conn.Done():
+			i.removeWaiter(w)
+			return ErrDisconnected
+		}
+	}
+}
+
+// removeWaiter stops w from receiving messages, waiting for w.match to
+// return if it's being called
+func (i *IRC) removeWaiter(w *waiter) {
+	w.mu.Lock()
+	w.gone = true
+	w.mu.Unlock()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for n, o := range i.waiters {
+		if o == w {
+			i.waiters = append(i.waiters[:n], i.waiters[n+1:]...)
+			return
+		}
+	}
+}
+
+/* feedWaiters gives m to the waiters, releasing those which are done */
+func (i *IRC) feedWaiters(m Message) {
+	i.mu.Lock()
+	if 0 == len(i.waiters) {
+		i.mu.Unlock()
+		return
+	}
+	ws := append([]*waiter(nil), i.waiters...)
+	i.mu.Unlock()
+	/* Matchers may want i.mu */
+	for _, w := range ws {
+		if i.feedWaiter(w, m) {
+			i.removeWaiter(w)
+		}
+	}
+}
+
+/* feedWaiter gives m to w, and returns true if w is done */
+func (i *IRC) feedWaiter(w *waiter, m Message) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.gone {
+		return false
+	}
+	keep, done := w.match(m)
+	if keep {
+		w.ms = append(w.ms, m)
+		select {
+		case w.more <- struct{}{}:
+		default:
+		}
+	}
+	if done {
+		w.gone = true
+		close(w.done)
+	}
+	return done
+}
+
+// WaitFor waits for a message from the server for which match returns true, and returns it.  An error is returned if ctx is done or the connection ends first.  Unlike the functions which send requests, WaitFor waits as long as ctx allows.  match is called in the goroutine which reads from the server, so it should return quickly and mustn't wait for anything else from the server.  To avoid missing a quick reply to something sent to the server, use WaitForReply.
+func (i *IRC) WaitFor(ctx context.Context, match func(m Message) bool) (
+	Message, error) {
+	return i.WaitForReply(ctx, "", match)
+}
+
+// WaitForReply sends line to the server, unless it's the empty string, and waits for a message for which match returns true, as WaitFor.  The wait starts before line is sent, so the reply can't be missed.  If ctx has no deadline, a *TimeoutError is returned if no reply arrives within i.RequestTimeout.
+func (i *IRC) WaitForReply(
+	ctx context.Context,
+	line string,
+	match func(m Message) bool,
+) (Message, error) {
+	/* There's no request to time out if there's no line */
+	timeout := i.requestTimeout()
+	if "" == line {
+		timeout = 0
+	}
+	ms, err := i.waitTimeout(ctx, timeout, func() error {
+		if "" == line {
+			return nil
+		}
+		return i.PrintfLine("%s", line)
+	}, func(m Message) (bool, bool) {
+		ok := match(m)
+		return ok, ok
+	})
+	if nil != err {
+		return Message{}, err
+	}
+	return ms[0], nil
+}
@@ -0,0 +1,108 @@
+package minimalirc
+
+import (
+	"context"
+	"runtime/debug"
+)
+
+/*
+ * workers.go
+ * Run handlers without holding up reading
+ * created 20261016
+ * last modified 20261016
+ *
+ * See minimalirc.go for license details.
+ */
+
+// OverflowPolicy says what happens to a message when every handler worker is busy and the queue's full.
+type OverflowPolicy int
+
+// What to do when the handler workers can't keep up.
+const (
+	OverflowBlock OverflowPolicy = iota /* Wait, holding up reading */
+	OverflowDrop                        /* Don't pass the message on */
+)
+
+// DroppedEvent is sent when a message isn't passed to a handler because the handler workers are busy, the queue's full, and i.HandlerOverflow is OverflowDrop.
+type DroppedEvent struct {
+	Message Message
+}
+
+func (DroppedEvent) event() {}
+
+/* handlerJob is a call to a handler, waiting for a worker */
+type handlerJob struct {
+	ctx context.Context
+	h   Handler
+	m   Message
+}
+
+// workers returns the queue for the connection's handler workers, or for
+// the single worker for ordered handlers if ordered is true, starting them if
+// need be.  The workers stop when ctx, the connection's context, is
+// cancelled.
+func (i *IRC) workers(ctx context.Context, ordered bool) chan handlerJob {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	q, n := &i.jobs, i.HandlerWorkers
+	if ordered {
+		q, n = &i.ordered, 1
+	}
+	if nil != *q {
+		return *q
+	}
+	size := i.HandlerQueue
+	if 0 > size {
+		size = 0
+	}
+	*q = make(chan handlerJob, size)
+	for w := 0; w < n; w++ {
+		go i.work(ctx, *q)
+	}
+	return *q
+}
+
+/* work runs handlers from jobs until ctx is done */
+func (i *IRC) work(ctx context.Context, jobs chan handlerJob) {
+	for {
+		select {
+		case j := <-jobs:
+			i.runHandler(j)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// queueHandler queues a call to h with m for the handler workers, or drops
+// it if they're busy and i.HandlerOverflow says to
+func (i *IRC) queueHandler(ctx context.Context, h handler, m Message) {
+	j := handlerJob{ctx: ctx, h: h.h, m: m}
+	jobs := i.workers(ctx, h.ordered)
+	if OverflowDrop == i.HandlerOverflow {
+		select {
+		case jobs <- j:
+		default:
+			i.event(DroppedEvent{Message: m})
+		}
+		return
+	}
+	select {
+	case jobs <- j:
+	case <-ctx.Done():
+	}
+}
+
+// runHandler calls a handler, turning a panic into an ErrorEvent so one
+// broken handler can't take the connection down
+func (i *IRC) runHandler(j handlerJob) {
+	defer func() {
+		if r := recover(); nil != r {
+			i.event(ErrorEvent{Err: &PanicError{
+				Value: r,
+				Stack: debug.Stack(),
+			}})
+		}
+	}()
+	j.h(j.ctx, j.m)
+}